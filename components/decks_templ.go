@@ -31,7 +31,7 @@ func Decks() templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"flex justify-center min-h-screen\"><div class=\"container max-w-2/3 flex flex-col border border-gray-300 rounded-lg p-6\" hx-get=\"/api/flashcard/decks\" hx-trigger=\"load\" hx-swap=\"outerHTML\"><div class=\"flex justify-end mb-4\"><button id=\"createButton\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"showCreateDeckForm()\">Create</button> <button class=\"bg-red-500 hover:bg-red-700 text-white font-bold py-2 px-4 rounded\" onclick=\"deleteSelectedDeck()\">Delete</button></div><script>\n                let selectedDeck = null;\n                const container = document.querySelector('.container');\n\n                function fetchDecks() {\n                    // clear container, but leave both buttons\n                    container.innerHTML = container.children[0].outerHTML;\n                    fetch('/api/flashcard/decks')\n                        .then(response => response.json())\n                        .then(decks => {\n                            decks.forEach(deck => {\n                                let deckHTML = `\n                                    <div class=\"deck bg-gray-100 rounded-lg p-6 text-center mb-4 cursor-pointer flex justify-between items-center\" id=\"${deck.id}\" onclick=\"selectDeck(${deck.id})\">\n                                        <h3 class=\"text-lg font-semibold\">Deck ${deck.id}: ${deck.name}</h3>\n                                        <div class=\"flex space-x-2\">\n                                            <a href=\"/projects/flashcard/decks/${deck.id}/study\">\n                                                <button class=\"bg-green-500 hover:bg-green-700 text-white font-bold py-2 px-4 rounded\">\n                                                    Study\n                                                </button>\n                                            </a>\n                                            <button id=\"edit-button-${deck.id}\" class=\"bg-yellow-500 hover:bg-yellow-700 text-white font-bold py-2 px-4 rounded hidden\" onclick=\"window.location.href = '/projects/flashcard/edit/${deck.id}'\">\n                                                Edit Cards\n                                            </button>\n                                        </div>\n                                    </div>\n                                `;\n                                container.innerHTML += deckHTML;\n                            });\n                        })\n                        .catch(error => console.error('Error fetching decks:', error));\n                }\n\n                function selectDeck(deckId) {\n                    const deck = document.getElementById(deckId);\n                    const editButton = document.getElementById(`edit-button-${deckId}`); // Get the edit button\n\n                    if (selectedDeck && selectedDeck.id === deckId.toString()) {\n                        deck.classList.remove('bg-blue-200');\n                        selectedDeck = null;\n                        editButton.classList.add('hidden'); // Hide the edit button when deselecting\n                    } else {\n                        if (selectedDeck) {\n                            selectedDeck.classList.remove('bg-blue-200');\n                            const previousEditButton = document.getElementById(`edit-button-${selectedDeck.id}`);\n                            if (previousEditButton) {\n                                previousEditButton.classList.add('hidden'); // Hide previous button if it exists\n                            }\n                        }\n                        deck.classList.add('bg-blue-200');\n                        selectedDeck = deck;\n                        editButton.classList.remove('hidden'); // Show the edit button when selecting\n                    }\n                }\n\n                function showCreateDeckForm() {\n                    // Check if the form already exists\n                    if (document.getElementById('createDeckForm')) {\n                        return; // Don't create another one\n                    }\n\n                    const createDeckForm = `\n                        <div class=\"deck bg-gray-100 rounded-lg p-6 text-center mb-4\" id=\"createDeckForm\">\n                            <input type=\"text\" id=\"deckName\" placeholder=\"Deck Name\" class=\"border rounded-md p-2 mb-2\" />\n                            <button onclick=\"removeCreateDeckForm()\" class=\"bg-gray-400 hover:bg-gray-600 text-white font-bold py-2 px-4 rounded mr-2\">\n                                Cancel\n                            </button>\n                            <button id=\"btn-submit\" onclick=\"handleCreateDeck()\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded\">\n                                Submit\n                            </button>\n                        </div>\n                    `;\n                    container.innerHTML = createDeckForm + container.innerHTML;\n                    document.getElementById('deckName').focus();\n\t\t\t\t\tdocument.getElementById('deckName').addEventListener('keydown', function(event) {\n\t\t\t\t\t\tif (event.key === 'Enter') {\n\t\t\t\t\t\t\tevent.preventDefault(); // Prevent form submission if inside a form\n\t\t\t\t\t\t\tdocument.getElementById('btn-submit').click();\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n                }\n\n                function removeCreateDeckForm() {\n                    const form = document.getElementById('createDeckForm');\n                    if (form) {\n                        form.remove(); // Remove the form from the DOM\n                    }\n                }\n\n                function handleCreateDeck() {\n                    const deckName = document.getElementById('deckName').value;\n                    if (!deckName) {\n                        alert('Please enter a deck name');\n                        return;\n                    }\n                    console.log('Creating deck:', deckName);\n\n                    fetch('/api/flashcard/decks/', {\n                        method: 'POST',\n                        headers: {\n                            'Content-Type': 'application/json'\n                        },\n                        body: JSON.stringify({ name: deckName })\n                    })\n                        .then(response => response.json())\n                        .then(deck => {\n                            console.log('Deck created:', deck);\n                            removeCreateDeckForm();\n                            fetchDecks(); // Refresh the deck list\n                        })\n                        .catch(error => console.error('Error creating deck:', error));\n                }\n\n                function deleteSelectedDeck() {\n                    if (selectedDeck) {\n                        if (confirm(`Are you sure you want to delete deck ${selectedDeck.id}? This action cannot be undone.`)) {\n                            fetch(`/api/flashcard/decks/${selectedDeck.id}`, {\n                                method: 'DELETE'\n                            })\n                                .then(response => {\n                                    if (response.ok) {\n                                        // Delete was successful\n                                        selectedDeck.remove(); // Remove the deck from the UI\n                                        selectedDeck = null; // Reset the selectedDeck variable\n                                    } else {\n                                        alert(\"Error deleting deck.\");\n                                    }\n                                })\n                                .catch(error => console.error('Error:', error));\n                        }\n                    } else {\n                        alert(\"Please select a deck to delete.\");\n                    }\n                }\n\n                // Initial trigger\n                fetchDecks();\n            </script><style>\n                .deck {\n                    transition: background-color 0.3s ease; /* Smooth transition for visual feedback */\n                }\n            </style></div></div>")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"flex justify-center min-h-screen\"><div class=\"container max-w-2/3 flex flex-col border border-gray-300 rounded-lg p-6\" hx-get=\"/api/flashcard/decks\" hx-trigger=\"load\" hx-swap=\"outerHTML\"><div class=\"flex justify-end mb-4\"><button id=\"createButton\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"showCreateDeckForm()\">Create</button> <button class=\"bg-purple-500 hover:bg-purple-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"document.getElementById(&#39;apkgFile&#39;).click()\">Import Anki</button> <input type=\"file\" id=\"apkgFile\" accept=\".apkg\" class=\"hidden\" onchange=\"importDeck(this, &#39;apkg&#39;)\"> <button class=\"bg-purple-500 hover:bg-purple-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"document.getElementById(&#39;markdownFile&#39;).click()\">Import Markdown</button> <input type=\"file\" id=\"markdownFile\" accept=\".md,.markdown\" class=\"hidden\" onchange=\"importDeck(this, &#39;markdown&#39;)\"> <button class=\"bg-yellow-500 hover:bg-yellow-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"showEditDeckForm()\">Edit</button> <button class=\"bg-red-500 hover:bg-red-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"deleteSelectedDeck()\">Delete</button> <label class=\"flex items-center\"><input type=\"checkbox\" id=\"showArchived\" class=\"mr-1\" onchange=\"showArchived = this.checked; fetchDecks()\"> Show archived</label></div><script>\n                let selectedDeck = null;\n                // Every deck with its path, such as Go::Concurrency, for picking a parent deck\n                let deckPaths = [];\n                // Every deck by ID, for filling in the edit form\n                let decksByID = {};\n                // The list is redrawn from HTML, which drops the checkbox state\n                let showArchived = false;\n                const container = document.querySelector('.container');\n\n                // escapeHTML makes text safe to put in the HTML the deck list is built from\n                function escapeHTML(text) {\n                    const div = document.createElement('div');\n                    div.textContent = text;\n                    return div.innerHTML.replace(/\"/g, '&quot;');\n                }\n\n                function fetchDecks() {\n                    // clear container, but leave both buttons\n                    container.innerHTML = container.children[0].outerHTML;\n                    document.getElementById('showArchived').checked = showArchived;\n                    fetch(`/api/flashcard/decks${showArchived ? '?archived=true' : ''}`)\n                        .then(response => response.json())\n                        .then(decks => {\n                            deckPaths = [];\n                            decksByID = {};\n                            decks.forEach(deck => renderDeck(deck, 0, ''));\n                        })\n                        .catch(error => console.error('Error fetching decks:', error));\n                }\n\n                // renderDeck adds a deck to the list, followed by the decks nested in it,\n                // indented one step further\n                function renderDeck(deck, depth, parentPath) {\n                    const path = parentPath ? `${parentPath}::${deck.name}` : deck.name;\n                    deckPaths.push({ id: deck.id, path: path });\n                    decksByID[deck.id] = deck;\n                    const colour = deck.color ? `border-left: 0.5rem solid ${deck.color};` : '';\n                    let deckHTML = `\n                        <div class=\"deck bg-gray-100 rounded-lg p-6 text-center mb-4 cursor-pointer flex justify-between items-center ${deck.archived ? 'opacity-50' : ''}\" id=\"${deck.id}\" onclick=\"selectDeck(${deck.id})\" style=\"margin-left: ${depth * 2}rem; ${colour}\">\n                            <div class=\"text-left\">\n                                <h3 class=\"text-lg font-semibold\">${escapeHTML(deck.icon)} Deck ${deck.id}: ${escapeHTML(deck.name)}${deck.archived ? ' (archived)' : ''}</h3>\n                                <p class=\"text-sm text-gray-600\">${escapeHTML(deck.description)}</p>\n                            </div>\n                            <div class=\"flex space-x-2\">\n                                <a href=\"/projects/flashcard/decks/${deck.id}/study\">\n                                    <button class=\"bg-green-500 hover:bg-green-700 text-white font-bold py-2 px-4 rounded\">\n                                        Study\n                                    </button>\n                                </a>\n                                <button id=\"edit-button-${deck.id}\" class=\"bg-yellow-500 hover:bg-yellow-700 text-white font-bold py-2 px-4 rounded hidden\" onclick=\"window.location.href = '/projects/flashcard/edit/${deck.id}'\">\n                                    Edit Cards\n                                </button>\n                            </div>\n                        </div>\n                    `;\n                    container.innerHTML += deckHTML;\n                    (deck.children || []).forEach(child => renderDeck(child, depth + 1, path));\n                }\n\n                function selectDeck(deckId) {\n                    const deck = document.getElementById(deckId);\n                    const editButton = document.getElementById(`edit-button-${deckId}`); // Get the edit button\n\n                    if (selectedDeck && selectedDeck.id === deckId.toString()) {\n                        deck.classList.remove('bg-blue-200');\n                        selectedDeck = null;\n                        editButton.classList.add('hidden'); // Hide the edit button when deselecting\n                    } else {\n                        if (selectedDeck) {\n                            selectedDeck.classList.remove('bg-blue-200');\n                            const previousEditButton = document.getElementById(`edit-button-${selectedDeck.id}`);\n                            if (previousEditButton) {\n                                previousEditButton.classList.add('hidden'); // Hide previous button if it exists\n                            }\n                        }\n                        deck.classList.add('bg-blue-200');\n                        selectedDeck = deck;\n                        editButton.classList.remove('hidden'); // Show the edit button when selecting\n                    }\n                }\n\n                function showCreateDeckForm() {\n                    // Check if the form already exists\n                    if (document.getElementById('createDeckForm')) {\n                        return; // Don't create another one\n                    }\n\n                    const createDeckForm = `\n                        <div class=\"deck bg-gray-100 rounded-lg p-6 text-center mb-4\" id=\"createDeckForm\">\n                            <input type=\"text\" id=\"deckName\" placeholder=\"Deck Name\" class=\"border rounded-md p-2 mb-2\" />\n                            <select id=\"deckScheduler\" class=\"border rounded-md p-2 mb-2\">\n                                <option value=\"sm2\">SM-2</option>\n                                <option value=\"fsrs\">FSRS</option>\n                            </select>\n                            <select id=\"deckParent\" class=\"border rounded-md p-2 mb-2\">\n                                <option value=\"\">No parent deck</option>\n                                ${deckPaths.map(deck => `<option value=\"${deck.id}\">${escapeHTML(deck.path)}</option>`).join('')}\n                            </select>\n                            <button onclick=\"removeCreateDeckForm()\" class=\"bg-gray-400 hover:bg-gray-600 text-white font-bold py-2 px-4 rounded mr-2\">\n                                Cancel\n                            </button>\n                            <button id=\"btn-submit\" onclick=\"handleCreateDeck()\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded\">\n                                Submit\n                            </button>\n                        </div>\n                    `;\n                    container.innerHTML = createDeckForm + container.innerHTML;\n                    document.getElementById('deckName').focus();\n\t\t\t\t\tdocument.getElementById('deckName').addEventListener('keydown', function(event) {\n\t\t\t\t\t\tif (event.key === 'Enter') {\n\t\t\t\t\t\t\tevent.preventDefault(); // Prevent form submission if inside a form\n\t\t\t\t\t\t\tdocument.getElementById('btn-submit').click();\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n                }\n\n                function removeCreateDeckForm() {\n                    const form = document.getElementById('createDeckForm');\n                    if (form) {\n                        form.remove(); // Remove the form from the DOM\n                    }\n                }\n\n                function handleCreateDeck() {\n                    const deckName = document.getElementById('deckName').value;\n                    const scheduler = document.getElementById('deckScheduler').value;\n                    const parent = document.getElementById('deckParent').value;\n                    if (!deckName) {\n                        alert('Please enter a deck name');\n                        return;\n                    }\n                    console.log('Creating deck:', deckName);\n\n                    fetch('/api/flashcard/decks/', {\n                        method: 'POST',\n                        headers: {\n                            'Content-Type': 'application/json'\n                        },\n                        body: JSON.stringify({ name: deckName, scheduler: scheduler, parentId: parent ? Number(parent) : null })\n                    })\n                        .then(response => response.json())\n                        .then(deck => {\n                            console.log('Deck created:', deck);\n                            removeCreateDeckForm();\n                            fetchDecks(); // Refresh the deck list\n                        })\n                        .catch(error => console.error('Error creating deck:', error));\n                }\n\n                function showEditDeckForm() {\n                    if (!selectedDeck) {\n                        alert(\"Please select a deck to edit.\");\n                        return;\n                    }\n                    if (document.getElementById('editDeckForm')) {\n                        return;\n                    }\n\n                    const deck = decksByID[selectedDeck.id];\n                    const editDeckForm = `\n                        <div class=\"deck bg-gray-100 rounded-lg p-6 text-center mb-4\" id=\"editDeckForm\">\n                            <input type=\"text\" id=\"editDeckName\" placeholder=\"Deck Name\" class=\"border rounded-md p-2 mb-2\" />\n                            <input type=\"text\" id=\"editDeckIcon\" placeholder=\"Icon\" class=\"border rounded-md p-2 mb-2 w-20\" />\n                            <input type=\"color\" id=\"editDeckColor\" class=\"border rounded-md mb-2 align-middle\" />\n                            <textarea id=\"editDeckDescription\" placeholder=\"Description\" class=\"border rounded-md p-2 mb-2 w-full\"></textarea>\n                            <label class=\"mr-2\"><input type=\"checkbox\" id=\"editDeckArchived\" /> Archived</label>\n                            <button onclick=\"removeEditDeckForm()\" class=\"bg-gray-400 hover:bg-gray-600 text-white font-bold py-2 px-4 rounded mr-2\">\n                                Cancel\n                            </button>\n                            <button onclick=\"handleEditDeck(${deck.id})\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded\">\n                                Save\n                            </button>\n                        </div>\n                    `;\n                    container.innerHTML = editDeckForm + container.innerHTML;\n                    // Set the values here so names and descriptions need no escaping\n                    document.getElementById('editDeckName').value = deck.name;\n                    document.getElementById('editDeckIcon').value = deck.icon;\n                    document.getElementById('editDeckColor').value = deck.color || '#000000';\n                    document.getElementById('editDeckDescription').value = deck.description;\n                    document.getElementById('editDeckArchived').checked = deck.archived;\n                    document.getElementById('editDeckName').focus();\n                }\n\n                function removeEditDeckForm() {\n                    const form = document.getElementById('editDeckForm');\n                    if (form) {\n                        form.remove();\n                    }\n                }\n\n                function handleEditDeck(deckId) {\n                    const color = document.getElementById('editDeckColor').value;\n                    fetch(`/api/flashcard/decks/${deckId}`, {\n                        method: 'PATCH',\n                        headers: {\n                            'Content-Type': 'application/json'\n                        },\n                        body: JSON.stringify({\n                            name: document.getElementById('editDeckName').value,\n                            icon: document.getElementById('editDeckIcon').value,\n                            // The colour picker has no empty value, so black means none\n                            color: color === '#000000' ? '' : color,\n                            description: document.getElementById('editDeckDescription').value,\n                            archived: document.getElementById('editDeckArchived').checked\n                        })\n                    })\n                        .then(response => {\n                            if (!response.ok) {\n                                return response.text().then(text => { throw new Error(text); });\n                            }\n                            return response.json();\n                        })\n                        .then(() => {\n                            selectedDeck = null;\n                            fetchDecks(); // Refresh the deck list\n                        })\n                        .catch(error => alert(`Error updating deck: ${error.message}`));\n                }\n\n                function deleteSelectedDeck() {\n                    if (selectedDeck) {\n                        if (confirm(`Are you sure you want to delete deck ${selectedDeck.id} and the decks nested in it? This action cannot be undone.`)) {\n                            fetch(`/api/flashcard/decks/${selectedDeck.id}`, {\n                                method: 'DELETE'\n                            })\n                                .then(response => {\n                                    if (response.ok) {\n                                        // Delete was successful\n                                        selectedDeck = null; // Reset the selectedDeck variable\n                                        fetchDecks(); // Refresh the deck list, as nested decks went too\n                                    } else {\n                                        alert(\"Error deleting deck.\");\n                                    }\n                                })\n                                .catch(error => console.error('Error:', error));\n                        }\n                    } else {\n                        alert(\"Please select a deck to delete.\");\n                    }\n                }\n\n                function importDeck(input, format) {\n                    const file = input.files[0];\n                    if (!file) {\n                        return;\n                    }\n                    const formData = new FormData();\n                    formData.append('file', file);\n\n                    fetch(`/api/flashcard/import/${format}`, {\n                        method: 'POST',\n                        body: formData\n                    })\n                        .then(response => {\n                            if (!response.ok) {\n                                return response.text().then(text => { throw new Error(text); });\n                            }\n                            return response.json();\n                        })\n                        .then(data => {\n                            const report = data.report;\n                            alert(`Imported ${report.imported} cards, skipped ${report.skipped}, ${report.duplicates} duplicates.`);\n                            fetchDecks(); // Refresh the deck list\n                        })\n                        .catch(error => alert(`Error importing deck: ${error.message}`))\n                        .finally(() => { input.value = ''; });\n                }\n\n                // Initial trigger\n                fetchDecks();\n            </script><style>\n                .deck {\n                    transition: background-color 0.3s ease; /* Smooth transition for visual feedback */\n                }\n            </style></div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
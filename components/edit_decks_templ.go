@@ -31,7 +31,7 @@ func EditDeck() templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"flex justify-center min-h-screen\"><div class=\"container max-w-2/3 flex flex-col border border-gray-300 rounded-lg p-6\" hx-get=\"/api/flashcard/cards/{deck_id}\" hx-trigger=\"load\" hx-swap=\"outerHTML\"><div class=\"flex justify-end mb-4\"><button id=\"editButton\" class=\"hidden bg-green-500 hover:bg-green-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"showEditCardForm()\">Edit</button> <button id=\"createButton\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"showCreateCardForm()\">Create</button> <button class=\"bg-red-500 hover:bg-red-700 text-white font-bold py-2 px-4 rounded\" onclick=\"deleteSelectedCard()\">Delete</button></div><h2 class=\"text-2xl font-semibold mb-4\">Edit Cards</h2><script>\n                let selectedCard = null;\n                const container = document.querySelector('.container');\n                \n                // Extract deck_id from the current URL\n                const currentUrl = window.location.href;\n                const deckIdMatch = currentUrl.match(/\\/edit\\/(\\d+)/);\n                const deckId = deckIdMatch ? deckIdMatch[1] : null;\n\n                if (deckId) {\n                    // Update hx-get attribute with the extracted deck_id\n                    container.setAttribute('hx-get', `/api/flashcard/cards/${deckId}`);\n                } else {\n                    console.error('Deck ID not found in URL');\n                    // Optionally, handle this error (e.g., show a message to the user)\n                }\n\n                function fetchCards() {\n                    container.innerHTML = container.children[0].outerHTML + container.children[1].outerHTML + container.children[2].outerHTML; // Keep the heading and buttons\n                    fetch(`/api/flashcard/cards/${deckId}`)\n                        .then(response => response.json())\n                        .then(cards => {\n                            cards.forEach(card => {\n                                let cardHTML = `\n                                    <div class=\"card bg-gray-100 rounded-lg p-6 mb-4 cursor-pointer\" id=\"card-${card.id}\" onclick=\"selectCard(${card.id})\">\n                                        <p>Front: ${card.front}</p>\n                                        <p>Back: ${card.back}</p>\n                                    </div>\n                                `;\n                                container.innerHTML += cardHTML;\n                            });\n                        })\n                        .catch(error => {\n                            console.error('Error fetching cards:', error);\n                        });\n                    editButton.classList.add('hidden');\n                }\n\n                function selectCard(cardId) {\n                    const card = document.getElementById(`card-${cardId}`);\n                    const editButton = document.getElementById('editButton');\n\n                    if (selectedCard && selectedCard.id === `card-${cardId}`) {\n                        card.classList.remove('bg-blue-200');\n                        editButton.classList.add('hidden');\n                        selectedCard = null; // Deselect if clicking the same card\n                    } else {\n                        if (selectedCard) {\n                            selectedCard.classList.remove('bg-blue-200');\n                            editButton.classList.add('hidden');\n                        }\n                        card.classList.add('bg-blue-200');\n                        selectedCard = card;\n                        editButton.classList.remove('hidden');\n                    }\n                }\n\n                function showEditCardForm() {\n                    if (!selectedCard) return; // Do nothing if no card is selected\n\n                    // Remove existing createCardForm if present\n                    removeCreateCardForm();\n\n                    const cardId = parseInt(selectedCard.id.replace(\"card-\", \"\"));\n                    const front = selectedCard.querySelector('p:first-of-type').textContent.replace('Front: ', '');\n                    const back = selectedCard.querySelector('p:last-of-type').textContent.replace('Back: ', '');\n\n                    const editCardForm = `\n                        <div class=\"card bg-gray-100 rounded-lg p-6 mb-4\" id=\"createCardForm\">\n                            <input type=\"text\" id=\"cardFront\" placeholder=\"Front\" class=\"border rounded-md p-2 mb-2 w-full\" value=\"${front}\"/>\n                            <input type=\"text\" id=\"cardBack\" placeholder=\"Back\" class=\"border rounded-md p-2 mb-2 w-full\" value=\"${back}\"/>\n                            <button onclick=\"removeCreateCardForm()\" class=\"bg-gray-400 hover:bg-gray-600 text-white font-bold py-2 px-4 rounded mr-2\">\n                                Cancel\n                            </button>\n                            <button id=\"btn-card-submit\" onclick=\"handleEditCard(${cardId})\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded\">\n                                Save\n                            </button>\n                        </div>\n                    `;\n                    container.innerHTML = editCardForm + container.innerHTML;\n                    document.getElementById('cardFront').focus();\n                    document.getElementById('createCardForm').addEventListener('keydown', function(event) {\n                        if (event.key === 'Enter') {\n                            event.preventDefault(); // Prevent form submission if inside a form\n                            document.getElementById('btn-card-submit').click();\n                        }\n                    });\n                }\n\n                async function handleEditCard(cardId) {\n                    const front = document.getElementById(\"cardFront\").value;\n                    const back = document.getElementById(\"cardBack\").value;\n\n                    // Basic validation (add more as needed)\n                    if (!front || !back) {\n                        alert(\"Please fill in both the front and back of the card.\");\n                        return;\n                    }\n\n                    const cardData = {\n                        id: cardId,\n                        front: front,\n                        back: back,\n                        recency: 0, // TODO Placeholder for now\n                        prevdifficulty: 0 // TODO Placeholder for now\n                    };\n\n                    try {\n                        const response = await fetch('/api/flashcard/cards', {\n                            method: 'PUT',\n                            headers: {\n                                'Content-Type': 'application/json'\n                            },\n                            body: JSON.stringify(cardData)\n                        });\n\n                        if (!response.ok) {\n                            throw new Error(`HTTP error! Status: ${response.status}`);\n                        }\n\n                        const responseData = await response.json();\n                        console.log(responseData); // Log the response from the server (for debugging)\n\n                        // Update the UI to reflect the changes\n                        fetchCards(); // Or you could directly update the specific card element\n\n                        // Close the form (optional)\n                        removeCreateCardForm();\n                    } catch (error) {\n                        console.error('Error editing card:', error);\n                        // Handle the error appropriately (show a message to the user, etc.)\n                    }\n                }\n\n                function showCreateCardForm() {\n                    // Check if the form already exists\n                    if (document.getElementById('createCardForm')) {\n                        return; \n                    }\n\n                    const createCardForm = `\n                        <div class=\"card bg-gray-100 rounded-lg p-6 mb-4\" id=\"createCardForm\">\n                            <input type=\"text\" id=\"cardFront\" placeholder=\"Front\" class=\"border rounded-md p-2 mb-2 w-full\" />\n                            <input type=\"text\" id=\"cardBack\" placeholder=\"Back\" class=\"border rounded-md p-2 mb-2 w-full\" />\n                            <button onclick=\"removeCreateCardForm()\" class=\"bg-gray-400 hover:bg-gray-600 text-white font-bold py-2 px-4 rounded mr-2\">\n                                Cancel\n                            </button>\n                            <button id=\"btn-card-submit\" onclick=\"handleCreateCard()\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded\">\n                                Submit\n                            </button>\n                        </div>\n                    `;\n                    container.innerHTML = createCardForm + container.innerHTML;\n                    document.getElementById('cardFront').focus();\n                    document.getElementById('createCardForm').addEventListener('keydown', function(event) {\n                        if (event.key === 'Enter') {\n                            event.preventDefault(); // Prevent form submission if inside a form\n                            document.getElementById('btn-card-submit').click();\n                        }\n                    });\n                }\n\n                function removeCreateCardForm() {\n                    const form = document.getElementById('createCardForm');\n                    if (form) {\n                        form.remove();\n                    }\n                }\n\n                async function handleCreateCard() {\n                    const front = document.getElementById(\"cardFront\").value;\n                    const back = document.getElementById(\"cardBack\").value;\n\n                    // Check if both fields are filled\n                    if (!front || !back) {\n                        alert(\"Please fill in both the front and back of the card.\");\n                        return;\n                    }\n\n                    const cardData = { front, back };\n\n                    try {\n                        const response = await fetch('/api/flashcard/cards', {\n                            method: 'POST',\n                            headers: {\n                                'Content-Type': 'application/json'\n                            },\n                            body: JSON.stringify(cardData)\n                        });\n\n                        if (!response.ok) {\n                            throw new Error(`HTTP error! Status: ${response}`);\n                        }\n\n                        const responseData = await response.json();\n\n                        // Update the UI to reflect the new card (e.g., add it to the list of cards)\n                        fetchCards();\n\n                        // Clear the input fields\n                        document.getElementById(\"cardFront\").value = \"\";\n                        document.getElementById(\"cardBack\").value = \"\";\n\n                        // Close the form\n                        removeCreateCardForm();\n                    } catch (error) {\n                        console.error('Error creating card:', error);\n                        // Handle errors gracefully, perhaps display an error message to the user\n                    }\n                }\n\n                async function deleteSelectedCard() {\n                    if (!selectedCard) {\n                        alert(\"No card selected.\");\n                        return;\n                    }\n\n                    const confirmDelete = confirm(\"Are you sure you want to delete this card?\");\n                    if (!confirmDelete) {\n                        return;\n                    }\n\n                    const cardId = parseInt(selectedCard.id.replace(\"card-\", \"\"));\n\n                    try {\n                        const response = await fetch('/api/flashcard/cards', {\n                            method: 'DELETE',\n                            headers: {\n                                'Content-Type': 'application/json'\n                            },\n                            body: JSON.stringify({ id: cardId })\n                        });\n\n                        if (!response.ok) {\n                            throw new Error(`HTTP error! Status: ${response.status}`);\n                        }\n\n                        const responseData = await response.json();\n                        console.log(responseData);\n\n                        // Update the UI to remove the deleted card\n                        selectedCard.remove();\n                        selectedCard = null;\n                        fetchCards(); // Refresh the card list in case of changes\n                    } catch (error) {\n                        console.error('Error deleting card:', error);\n                        // Handle errors gracefully, perhaps display an error message to the user\n                    }\n                }\n                fetchCards(); \n            </script><style>\n                .card {\n                    transition: background-color 0.3s ease;\n                }\n            </style></div></div>")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"flex justify-center min-h-screen\"><div class=\"container max-w-2/3 flex flex-col border border-gray-300 rounded-lg p-6\" hx-get=\"/api/flashcard/cards/{deck_id}\" hx-trigger=\"load\" hx-swap=\"outerHTML\"><div class=\"flex justify-end mb-4\"><button id=\"editButton\" class=\"card-action hidden bg-green-500 hover:bg-green-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"showEditCardForm()\">Edit</button> <button id=\"decksButton\" class=\"card-action hidden bg-yellow-500 hover:bg-yellow-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"showCardDecksForm()\">Decks</button> <button id=\"createButton\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"showCreateCardForm()\">Create</button> <button class=\"bg-purple-500 hover:bg-purple-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"document.getElementById(&#39;csvFile&#39;).click()\">Import CSV</button> <input type=\"file\" id=\"csvFile\" accept=\".csv,.tsv,.txt\" class=\"hidden\" onchange=\"importCSV(this)\"> <button class=\"bg-gray-500 hover:bg-gray-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"window.location.href = `/api/flashcard/decks/${deckId}/export.csv`\">Export CSV</button> <button class=\"bg-gray-500 hover:bg-gray-700 text-white font-bold py-2 px-4 rounded mr-2\" onclick=\"window.location.href = `/api/flashcard/decks/${deckId}/export.md`\">Export Markdown</button> <button class=\"bg-red-500 hover:bg-red-700 text-white font-bold py-2 px-4 rounded\" onclick=\"deleteSelectedCard()\">Delete</button></div><h2 class=\"text-2xl font-semibold mb-4\">Edit Cards</h2><script>\n                let selectedCard = null;\n                let cardsById = {}; // the cards as last fetched, with their raw Markdown for editing\n                const container = document.querySelector('.container');\n                \n                // Extract deck_id from the current URL\n                const currentUrl = window.location.href;\n                const deckIdMatch = currentUrl.match(/\\/edit\\/(\\d+)/);\n                const deckId = deckIdMatch ? deckIdMatch[1] : null;\n\n                if (deckId) {\n                    // Update hx-get attribute with the extracted deck_id\n                    container.setAttribute('hx-get', `/api/flashcard/cards/${deckId}`);\n                } else {\n                    console.error('Deck ID not found in URL');\n                    // Optionally, handle this error (e.g., show a message to the user)\n                }\n\n                function fetchCards() {\n                    container.innerHTML = container.children[0].outerHTML + container.children[1].outerHTML + container.children[2].outerHTML; // Keep the heading and buttons\n                    fetch(`/api/flashcard/cards/${deckId}`)\n                        .then(response => response.json())\n                        .then(cards => {\n                            cardsById = {};\n                            cards.forEach(card => {\n                                cardsById[card.id] = card;\n                                // The sides come rendered from Markdown and sanitised\n                                let cardHTML = `\n                                    <div class=\"card bg-gray-100 rounded-lg p-6 mb-4 cursor-pointer\" id=\"card-${card.id}\" data-type=\"${card.type}\" onclick=\"selectCard(${card.id})\">\n                                        <div class=\"mb-2\"><span class=\"font-bold\">Front:</span> ${card.html.front}</div>\n                                        <div><span class=\"font-bold\">Back:</span> ${card.html.back}</div>\n                                    </div>\n                                `;\n                                container.innerHTML += cardHTML;\n                            });\n                        })\n                        .catch(error => {\n                            console.error('Error fetching cards:', error);\n                        });\n                    showCardActions(false);\n                }\n\n                // showCardActions shows or hides the buttons that act on the selected card\n                function showCardActions(show) {\n                    document.querySelectorAll('.card-action').forEach(button => button.classList.toggle('hidden', !show));\n                }\n\n                function selectCard(cardId) {\n                    const card = document.getElementById(`card-${cardId}`);\n\n                    if (selectedCard && selectedCard.id === `card-${cardId}`) {\n                        card.classList.remove('bg-blue-200');\n                        showCardActions(false);\n                        selectedCard = null; // Deselect if clicking the same card\n                    } else {\n                        if (selectedCard) {\n                            selectedCard.classList.remove('bg-blue-200');\n                        }\n                        card.classList.add('bg-blue-200');\n                        selectedCard = card;\n                        showCardActions(true);\n                    }\n                }\n\n                function showEditCardForm() {\n                    if (!selectedCard) return; // Do nothing if no card is selected\n\n                    // Remove existing createCardForm if present\n                    removeCreateCardForm();\n\n                    const cardId = parseInt(selectedCard.id.replace(\"card-\", \"\"));\n                    const type = selectedCard.dataset.type;\n\n                    const editCardForm = `\n                        <div class=\"card bg-gray-100 rounded-lg p-6 mb-4\" id=\"createCardForm\">\n                            <input type=\"text\" id=\"cardFront\" placeholder=\"Front\" class=\"border rounded-md p-2 mb-2 w-full\" oninput=\"previewCard('${type}')\"/>\n                            <input type=\"text\" id=\"cardBack\" placeholder=\"Back\" class=\"border rounded-md p-2 mb-2 w-full\" oninput=\"previewCard('${type}')\"/>\n                            <label class=\"block mb-2\">\n                                Attach image or audio to the back\n                                <input type=\"file\" accept=\"image/*,audio/*\" class=\"ml-2\" onchange=\"attachMedia(this)\"/>\n                            </label>\n                            <div id=\"cardPreview\" class=\"bg-white rounded-md p-2 mb-2\"></div>\n                            <button onclick=\"removeCreateCardForm()\" class=\"bg-gray-400 hover:bg-gray-600 text-white font-bold py-2 px-4 rounded mr-2\">\n                                Cancel\n                            </button>\n                            <button id=\"btn-card-submit\" onclick=\"handleEditCard(${cardId}, '${type}')\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded\">\n                                Save\n                            </button>\n                        </div>\n                    `;\n                    container.innerHTML = editCardForm + container.innerHTML;\n                    // Set the raw Markdown as values rather than in the markup\n                    document.getElementById('cardFront').value = cardsById[cardId].front;\n                    document.getElementById('cardBack').value = cardsById[cardId].back;\n                    previewCard(type);\n                    document.getElementById('cardFront').focus();\n                    document.getElementById('createCardForm').addEventListener('keydown', function(event) {\n                        if (event.key === 'Enter') {\n                            event.preventDefault(); // Prevent form submission if inside a form\n                            document.getElementById('btn-card-submit').click();\n                        }\n                    });\n                }\n\n                async function handleEditCard(cardId, type) {\n                    const front = document.getElementById(\"cardFront\").value;\n                    const back = document.getElementById(\"cardBack\").value;\n\n                    // Basic validation (add more as needed), the back of a cloze card is optional\n                    if (!front || (!back && type !== 'cloze')) {\n                        alert(\"Please fill in both the front and back of the card.\");\n                        return;\n                    }\n\n                    const cardData = {\n                        type: type,\n                        front: front,\n                        back: back\n                    };\n\n                    try {\n                        const response = await fetch(`/api/flashcard/decks/${deckId}/cards/${cardId}`, {\n                            method: 'PUT',\n                            headers: {\n                                'Content-Type': 'application/json'\n                            },\n                            body: JSON.stringify(cardData)\n                        });\n\n                        if (!response.ok) {\n                            throw new Error(`HTTP error! Status: ${response.status}`);\n                        }\n\n                        const responseData = await response.json();\n                        console.log(responseData); // Log the response from the server (for debugging)\n\n                        // Update the UI to reflect the changes\n                        fetchCards(); // Or you could directly update the specific card element\n\n                        // Close the form (optional)\n                        removeCreateCardForm();\n                    } catch (error) {\n                        console.error('Error editing card:', error);\n                        // Handle the error appropriately (show a message to the user, etc.)\n                    }\n                }\n\n                // escapeHTML makes text, such as a deck's name, safe to put in HTML\n                function escapeHTML(text) {\n                    const div = document.createElement('div');\n                    div.textContent = text;\n                    return div.innerHTML.replace(/\"/g, '&quot;');\n                }\n\n                // deckPaths lists every deck with its path, such as Go::Concurrency\n                function deckPaths(decks, parentPath, list) {\n                    decks.forEach(deck => {\n                        const path = parentPath ? `${parentPath}::${deck.name}` : deck.name;\n                        list.push({ id: deck.id, path: path });\n                        deckPaths(deck.children || [], path, list);\n                    });\n                    return list;\n                }\n\n                // showCardDecksForm lists the decks the selected card is in, and\n                // moves or copies it to another deck\n                async function showCardDecksForm() {\n                    if (!selectedCard) return;\n                    removeCreateCardForm();\n\n                    const cardId = parseInt(selectedCard.id.replace(\"card-\", \"\"));\n                    try {\n                        const [cardDecks, decks] = await Promise.all([\n                            fetch(`/api/flashcard/cards/${cardId}/decks`).then(response => response.json()),\n                            fetch('/api/flashcard/decks').then(response => response.json()),\n                        ]);\n                        const paths = deckPaths(decks, '', []);\n                        const pathOf = id => (paths.find(deck => deck.id === id) || { path: id }).path;\n\n                        const cardDecksForm = `\n                            <div class=\"card bg-gray-100 rounded-lg p-6 mb-4\" id=\"createCardForm\">\n                                <p class=\"font-bold mb-2\">In decks</p>\n                                <ul class=\"mb-4\">\n                                    ${cardDecks.map(deck => `\n                                        <li class=\"flex justify-between items-center mb-1\">\n                                            ${escapeHTML(pathOf(deck.id))}\n                                            <button onclick=\"removeCardFromDeck(${cardId}, ${deck.id})\" class=\"bg-red-400 hover:bg-red-600 text-white py-1 px-2 rounded\">\n                                                Remove\n                                            </button>\n                                        </li>\n                                    `).join('')}\n                                </ul>\n                                <select id=\"cardTargetDeck\" class=\"border rounded-md p-2 mb-2\">\n                                    ${paths.filter(deck => deck.id !== Number(deckId)).map(deck => `<option value=\"${deck.id}\">${escapeHTML(deck.path)}</option>`).join('')}\n                                </select>\n                                <button onclick=\"removeCreateCardForm()\" class=\"bg-gray-400 hover:bg-gray-600 text-white font-bold py-2 px-4 rounded mr-2\">\n                                    Cancel\n                                </button>\n                                <button onclick=\"moveCard(${cardId}, 'move')\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded mr-2\">\n                                    Move\n                                </button>\n                                <button onclick=\"moveCard(${cardId}, 'copy')\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded\">\n                                    Copy\n                                </button>\n                            </div>\n                        `;\n                        container.innerHTML = cardDecksForm + container.innerHTML;\n                    } catch (error) {\n                        console.error('Error fetching decks:', error);\n                    }\n                }\n\n                // moveCard moves the card from this deck to the chosen deck, or\n                // copies it there when action is copy\n                async function moveCard(cardId, action) {\n                    const toDeckId = Number(document.getElementById('cardTargetDeck').value);\n                    if (!toDeckId) {\n                        alert(\"Please choose a deck.\");\n                        return;\n                    }\n\n                    try {\n                        const response = await fetch(`/api/flashcard/cards/${action}`, {\n                            method: 'POST',\n                            headers: {\n                                'Content-Type': 'application/json'\n                            },\n                            body: JSON.stringify({ cardIds: [cardId], fromDeckId: Number(deckId), toDeckId: toDeckId })\n                        });\n                        if (!response.ok) {\n                            alert(await response.text());\n                            return;\n                        }\n\n                        removeCreateCardForm();\n                        selectedCard = null;\n                        fetchCards(); // A moved card is no longer in this deck\n                    } catch (error) {\n                        console.error(`Error ${action === 'copy' ? 'copying' : 'moving'} card:`, error);\n                    }\n                }\n\n                // removeCardFromDeck takes the card out of a deck, keeping it in the others\n                async function removeCardFromDeck(cardId, fromDeckId) {\n                    try {\n                        const response = await fetch(`/api/flashcard/cards/${cardId}/decks/${fromDeckId}`, {\n                            method: 'DELETE'\n                        });\n                        if (!response.ok) {\n                            alert(await response.text());\n                            return;\n                        }\n\n                        removeCreateCardForm();\n                        if (fromDeckId === Number(deckId)) {\n                            selectedCard = null;\n                            fetchCards();\n                        } else {\n                            showCardDecksForm();\n                        }\n                    } catch (error) {\n                        console.error('Error removing card from deck:', error);\n                    }\n                }\n\n                function showCreateCardForm() {\n                    // Check if the form already exists\n                    if (document.getElementById('createCardForm')) {\n                        return; \n                    }\n\n                    const createCardForm = `\n                        <div class=\"card bg-gray-100 rounded-lg p-6 mb-4\" id=\"createCardForm\">\n                            <input type=\"text\" id=\"cardFront\" placeholder=\"Front\" class=\"border rounded-md p-2 mb-2 w-full\" oninput=\"previewCard()\"/>\n                            <input type=\"text\" id=\"cardBack\" placeholder=\"Back\" class=\"border rounded-md p-2 mb-2 w-full\" oninput=\"previewCard()\"/>\n                            <label class=\"block mb-2\">\n                                Attach image or audio to the back\n                                <input type=\"file\" accept=\"image/*,audio/*\" class=\"ml-2\" onchange=\"attachMedia(this)\"/>\n                            </label>\n                            <div id=\"cardPreview\" class=\"bg-white rounded-md p-2 mb-2\"></div>\n                            <label class=\"flex items-center mb-2\">\n                                <input type=\"checkbox\" id=\"cardReverse\" class=\"mr-2\" />\n                                Also create reverse card\n                            </label>\n                            <label class=\"flex items-center mb-2\">\n                                <input type=\"checkbox\" id=\"cardCloze\" class=\"mr-2\" onchange=\"previewCard()\"/>\n                                Cloze deletion, hiding answers marked {{c1::answer}} on the front\n                            </label>\n                            <button onclick=\"removeCreateCardForm()\" class=\"bg-gray-400 hover:bg-gray-600 text-white font-bold py-2 px-4 rounded mr-2\">\n                                Cancel\n                            </button>\n                            <button id=\"btn-card-submit\" onclick=\"handleCreateCard()\" class=\"bg-blue-500 hover:bg-blue-700 text-white font-bold py-2 px-4 rounded\">\n                                Submit\n                            </button>\n                        </div>\n                    `;\n                    container.innerHTML = createCardForm + container.innerHTML;\n                    document.getElementById('cardFront').focus();\n                    document.getElementById('createCardForm').addEventListener('keydown', function(event) {\n                        if (event.key === 'Enter') {\n                            event.preventDefault(); // Prevent form submission if inside a form\n                            document.getElementById('btn-card-submit').click();\n                        }\n                    });\n                }\n\n                let previewTimer;\n\n                // previewCard shows the card being edited as it will be studied,\n                // once typing pauses. type defaults to the cloze checkbox's choice.\n                function previewCard(type) {\n                    clearTimeout(previewTimer);\n                    previewTimer = setTimeout(async () => {\n                        const preview = document.getElementById('cardPreview');\n                        if (!preview) {\n                            return;\n                        }\n                        if (!type) {\n                            type = document.getElementById('cardCloze').checked ? 'cloze' : 'basic';\n                        }\n                        const cardData = {\n                            type: type,\n                            front: document.getElementById('cardFront').value,\n                            back: document.getElementById('cardBack').value\n                        };\n\n                        try {\n                            const response = await fetch('/api/flashcard/preview', {\n                                method: 'POST',\n                                headers: {\n                                    'Content-Type': 'application/json'\n                                },\n                                body: JSON.stringify(cardData)\n                            });\n                            if (!response.ok) {\n                                throw new Error(`HTTP error! Status: ${response.status}`);\n                            }\n                            const html = await response.json();\n                            preview.innerHTML = `${html.front}<hr class=\"my-2\">${html.back}`;\n                        } catch (error) {\n                            console.error('Error previewing card:', error);\n                        }\n                    }, 300);\n                }\n\n                // attachMedia uploads the chosen file and adds it to the end of\n                // the card's back.\n                async function attachMedia(input) {\n                    if (!input.files.length) {\n                        return;\n                    }\n                    const formData = new FormData();\n                    formData.append('file', input.files[0]);\n\n                    try {\n                        const response = await fetch('/api/flashcard/media', {\n                            method: 'POST',\n                            body: formData\n                        });\n                        if (!response.ok) {\n                            alert(await response.text());\n                            return;\n                        }\n                        const uploaded = await response.json();\n                        const back = document.getElementById('cardBack');\n                        back.value = back.value ? `${back.value} ${uploaded.markdown}` : uploaded.markdown;\n                        back.dispatchEvent(new Event('input'));\n                    } catch (error) {\n                        console.error('Error uploading media:', error);\n                    } finally {\n                        input.value = '';\n                    }\n                }\n\n                function removeCreateCardForm() {\n                    const form = document.getElementById('createCardForm');\n                    if (form) {\n                        form.remove();\n                    }\n                }\n\n                async function handleCreateCard() {\n                    const front = document.getElementById(\"cardFront\").value;\n                    const back = document.getElementById(\"cardBack\").value;\n\n                    const cloze = document.getElementById(\"cardCloze\").checked;\n\n                    // Check if both fields are filled, the back of a cloze card is optional\n                    if (!front || (!back && !cloze)) {\n                        alert(\"Please fill in both the front and back of the card.\");\n                        return;\n                    }\n\n                    const reverse = document.getElementById(\"cardReverse\").checked;\n                    const type = cloze ? 'cloze' : 'basic';\n\n                    const cardData = { type, front, back, reverse };\n\n                    try {\n                        const createCard = (query) => fetch(`/api/flashcard/decks/${deckId}/cards${query}`, {\n                            method: 'POST',\n                            headers: {\n                                'Content-Type': 'application/json'\n                            },\n                            body: JSON.stringify(cardData)\n                        });\n\n                        let response = await createCard('');\n\n                        // The deck already has a card with this front\n                        if (response.status === 409) {\n                            const conflict = await response.json();\n                            if (!confirm(`A card with this front already exists:\\n\\n${conflict.card.front}\\n\\nAdd it anyway?`)) {\n                                return;\n                            }\n                            response = await createCard('?onDuplicate=allow');\n                        }\n\n                        if (!response.ok) {\n                            throw new Error(`HTTP error! Status: ${response}`);\n                        }\n\n                        const responseData = await response.json();\n\n                        // Update the UI to reflect the new card (e.g., add it to the list of cards)\n                        fetchCards();\n\n                        // Clear the input fields\n                        document.getElementById(\"cardFront\").value = \"\";\n                        document.getElementById(\"cardBack\").value = \"\";\n\n                        // Close the form\n                        removeCreateCardForm();\n                    } catch (error) {\n                        console.error('Error creating card:', error);\n                        // Handle errors gracefully, perhaps display an error message to the user\n                    }\n                }\n\n                async function deleteSelectedCard() {\n                    if (!selectedCard) {\n                        alert(\"No card selected.\");\n                        return;\n                    }\n\n                    // A card in other decks is only taken out of this one\n                    const confirmDelete = confirm(\"Are you sure you want to remove this card from the deck? A card in no other deck is deleted.\");\n                    if (!confirmDelete) {\n                        return;\n                    }\n\n                    const cardId = parseInt(selectedCard.id.replace(\"card-\", \"\"));\n\n                    try {\n                        const response = await fetch(`/api/flashcard/decks/${deckId}/cards/${cardId}`, {\n                            method: 'DELETE'\n                        });\n\n                        if (!response.ok) {\n                            throw new Error(`HTTP error! Status: ${response.status}`);\n                        }\n\n                        const responseData = await response.json();\n                        console.log(responseData);\n\n                        // Update the UI to remove the deleted card\n                        selectedCard.remove();\n                        selectedCard = null;\n                        fetchCards(); // Refresh the card list in case of changes\n                    } catch (error) {\n                        console.error('Error deleting card:', error);\n                        // Handle errors gracefully, perhaps display an error message to the user\n                    }\n                }\n                async function importCSV(input) {\n                    const file = input.files[0];\n                    if (!file) {\n                        return;\n                    }\n                    const formData = new FormData();\n                    formData.append('file', file);\n\n                    try {\n                        const response = await fetch(`/api/flashcard/decks/${deckId}/import`, {\n                            method: 'POST',\n                            body: formData\n                        });\n\n                        if (!response.ok) {\n                            throw new Error(await response.text());\n                        }\n\n                        const { report } = await response.json();\n                        alert(`Imported ${report.imported} cards, skipped ${report.skipped}, ${report.duplicates} duplicates.`);\n                        fetchCards();\n                    } catch (error) {\n                        alert(`Error importing cards: ${error.message}`);\n                    } finally {\n                        input.value = '';\n                    }\n                }\n                fetchCards(); \n            </script><style>\n                .card {\n                    transition: background-color 0.3s ease;\n                }\n            </style></div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -31,7 +31,7 @@ func Flashcard() templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"lg:w-2/3 mx-auto\"><div class=\"flex justify-center items-center h-screen bg-blue-100\"><div class=\"text-center\"><div id=\"flashcard-content\" class=\"bg-white rounded-md shadow-md h-64 w-96 flex items-center justify-center mb-4\" hx-get=\"/api/flashcard\" hx-trigger=\"load\" hx-target=\"#flashcard-content\"></div><button onclick=\"flipCard()\" class=\"bg-green-400 hover:bg-green-600 text-white px-4 py-2 rounded transition duration-300\">Flip Card</button><div class=\"mt-4\"><div class=\"flex justify-center items-center\"><label for=\"rating1\" class=\"mr-2\">1</label> <input type=\"radio\" id=\"rating1\" name=\"rating\" value=\"1\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating2\" class=\"mx-2\">2</label> <input type=\"radio\" id=\"rating2\" name=\"rating\" value=\"2\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating3\" class=\"mx-2\">3</label> <input type=\"radio\" id=\"rating3\" name=\"rating\" value=\"3\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating4\" class=\"mx-2\">4</label> <input type=\"radio\" id=\"rating4\" name=\"rating\" value=\"4\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating5\" class=\"ml-2\">5</label> <input type=\"radio\" id=\"rating5\" name=\"rating\" value=\"5\" class=\"form-radio h-5 w-5 text-green-600\"></div></div><div class=\"mt-5\"><button class=\"bg-blue-400 hover:bg-blue-600 text-white px-4 py-2 rounded transition duration-300\" hx-post=\"/api/flashcard/rate\" hx-trigger=\"click\" hx-swap=\"none\" id=\"submit-rating\">Submit Rating</button> <button class=\"bg-red-400 hover:bg-red-600 text-white px-4 py-2 rounded transition duration-300\" hx-get=\"/api/flashcard\" hx-trigger=\"click\" hx-target=\"#flashcard-content\" hx-vals=\"\">Skip Card</button></div></div></div></div><script>\n            var frontContent = '';\n            var backContent = '';\n            var showingFront = true;\n            var id;\n\n            document.addEventListener('htmx:afterRequest', function (event) {\n                if (event.detail.target.id === 'flashcard-content') {\n                    var data = event.detail.xhr.response;\n                    try {\n                        var json = JSON.parse(data);\n                        frontContent = json.front;\n                        backContent = json.back;\n                        id = json.id;\n                        document.getElementById('flashcard-content').innerText = frontContent;\n                    } catch (e) {\n                        console.error('Error parsing JSON:', e);\n                    }\n                }\n            });\n\n            function flipCard() {\n                var cardContent = document.getElementById('flashcard-content');\n                cardContent.innerText = showingFront ? backContent : frontContent;\n                showingFront = !showingFront;\n            }\n\n            document.getElementById('submit-rating').addEventListener('click', function () {\n                var selectedRating = document.querySelector('input[name=\"rating\"]:checked').value;\n                this.setAttribute('hx-vals', JSON.stringify({ ID: id, Rating: selectedRating }));\n            });\n        </script></body>")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"lg:w-2/3 mx-auto\"><div class=\"flex justify-center items-center h-screen bg-blue-100\"><div class=\"text-center\"><div id=\"flashcard-content\" class=\"bg-white rounded-md shadow-md h-64 w-96 flex items-center justify-center mb-4\" hx-get=\"/api/flashcard\" hx-trigger=\"load\" hx-target=\"#flashcard-content\" hx-swap=\"none\"></div><button onclick=\"flipCard()\" class=\"bg-green-400 hover:bg-green-600 text-white px-4 py-2 rounded transition duration-300\">Flip Card</button><div class=\"mt-4\"><div class=\"flex justify-center items-center\"><label for=\"rating1\" class=\"mr-2\">1</label> <input type=\"radio\" id=\"rating1\" name=\"rating\" value=\"1\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating2\" class=\"mx-2\">2</label> <input type=\"radio\" id=\"rating2\" name=\"rating\" value=\"2\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating3\" class=\"mx-2\">3</label> <input type=\"radio\" id=\"rating3\" name=\"rating\" value=\"3\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating4\" class=\"mx-2\">4</label> <input type=\"radio\" id=\"rating4\" name=\"rating\" value=\"4\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating5\" class=\"ml-2\">5</label> <input type=\"radio\" id=\"rating5\" name=\"rating\" value=\"5\" class=\"form-radio h-5 w-5 text-green-600\"></div></div><div class=\"mt-5\"><button class=\"bg-blue-400 hover:bg-blue-600 text-white px-4 py-2 rounded transition duration-300\" hx-post=\"/api/flashcard/rate\" hx-trigger=\"click\" hx-swap=\"none\" id=\"submit-rating\">Submit Rating</button> <button class=\"bg-red-400 hover:bg-red-600 text-white px-4 py-2 rounded transition duration-300\" hx-get=\"/api/flashcard\" hx-trigger=\"click\" hx-target=\"#flashcard-content\" hx-swap=\"none\" hx-vals=\"\">Skip Card</button></div><p id=\"next-review\" class=\"mt-4 text-gray-700\"></p></div></div></div><script>\n            var frontContent = '';\n            var backContent = '';\n            var showingFront = true;\n            var id;\n            var shownAt;\n\n            document.addEventListener('htmx:afterRequest', function (event) {\n                if (event.detail.target.id === 'flashcard-content') {\n                    var data = event.detail.xhr.response;\n                    try {\n                        var json = JSON.parse(data);\n                        // The card's sides come rendered from Markdown and sanitised\n                        frontContent = json.html.front;\n                        backContent = json.html.back;\n                        id = json.id;\n                        shownAt = Date.now();\n                        document.getElementById('flashcard-content').innerHTML = frontContent;\n                    } catch (e) {\n                        console.error('Error parsing JSON:', e);\n                    }\n                } else if (event.detail.elt.id === 'submit-rating' && event.detail.successful) {\n                    // Show when the rated card will come up again\n                    var card = JSON.parse(event.detail.xhr.response).card;\n                    document.getElementById('next-review').innerText = nextReviewText(card);\n                }\n            });\n\n            // nextReviewText describes when a rated card is next due. Cards in a\n            // learning step have no interval yet, so their due time is used.\n            function nextReviewText(card) {\n                if (card.state === 'suspended') {\n                    return 'Card suspended';\n                }\n                if (card.interval > 0) {\n                    var days = card.interval === 1 ? 'day' : 'days';\n                    return `Next review in ${card.interval} ${days}`;\n                }\n                var due = new Date(card.due);\n                var minutes = Math.max(1, Math.round((due - Date.now()) / 60000));\n                if (minutes < 60) {\n                    return `Next review in ${minutes} ${minutes === 1 ? 'minute' : 'minutes'}`;\n                }\n                return `Next review at ${due.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' })}`;\n            }\n\n            function flipCard() {\n                var cardContent = document.getElementById('flashcard-content');\n                cardContent.innerHTML = showingFront ? backContent : frontContent;\n                showingFront = !showingFront;\n            }\n\n            document.getElementById('submit-rating').addEventListener('click', function () {\n                var selectedRating = document.querySelector('input[name=\"rating\"]:checked').value;\n                this.setAttribute('hx-vals', JSON.stringify({ ID: id, Rating: selectedRating, Latency: Date.now() - shownAt }));\n            });\n        </script></body>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
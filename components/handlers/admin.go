@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BackupHandler handles GET requests to /api/admin/backup, downloading a
+// JSON backup of the whole collection
+func BackupHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		now := time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "flashcards-"+now.Format("2006-01-02")+".json"))
+		if err := db.WriteBackup(data, w, now); err != nil {
+			// The backup is streamed, so part of it may already be sent
+			log.Print(err)
+		}
+	}
+}
+
+// RestoreHandler handles POST requests to /api/admin/restore, loading a
+// backup uploaded as the file form value or sent as the request body. The
+// mode form value is "merge" (the default) to add the backup to the
+// collection, or "replace" to replace the collection with it.
+func RestoreHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+		var body io.Reader = r.Body
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "Missing or too large file", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		mode := r.FormValue("mode")
+		if mode == "" {
+			mode = db.RestoreMerge
+		}
+
+		backup, err := db.ReadBackup(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		report, err := db.Restore(data, backup, mode)
+		if errors.Is(err, db.ErrInvalidBackup) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error restoring backup", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message string          `json:"message"`
+			Report  db.ImportReport `json:"report"`
+		}{
+			Message: "Backup restored successfully",
+			Report:  *report,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// CardBatchHandler handles POST requests to /api/flashcard/cards/batch,
+// running the body's operations, such as
+//
+//	{"operations": [
+//	    {"op": "create", "deckId": 1, "front": "hablar", "back": "to speak"},
+//	    {"op": "update", "id": 7, "front": "comer", "back": "to eat"},
+//	    {"op": "delete", "id": 8}
+//	]}
+//
+// in a single transaction. It responds with a result for each operation, or
+// with the operation that failed, in which case none of them are kept. A
+// created card that duplicates one in its deck fails with 409 Conflict,
+// unless the operation's "onDuplicate" and "duplicateScope" say otherwise.
+func CardBatchHandler(data *sql.DB, media *db.MediaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body struct {
+			Operations []db.BatchOp `json:"operations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		results, err := db.RunCardBatch(data, body.Operations)
+		if writeDuplicateConflict(w, err) {
+			return
+		} else if errors.Is(err, db.ErrInvalidBatch) || errors.Is(err, db.ErrInvalidCloze) || errors.Is(err, db.ErrGeneratedCard) || errors.Is(err, db.ErrInvalidDuplicates) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error running batch", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		// Deleted cards may have been the last to use some media
+		if slices.ContainsFunc(body.Operations, func(op db.BatchOp) bool { return op.Op == db.BatchDelete }) {
+			collectMedia(data, media)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message string           `json:"message"`
+			Results []db.BatchResult `json:"results"`
+		}{
+			Message: "Batch completed successfully",
+			Results: results,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
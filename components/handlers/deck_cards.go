@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CardDecksHandler handles requests to /api/flashcard/cards/{id}/decks:
+//   - GET lists the decks the card is in
+//   - POST adds the card to the body's deckId deck as well
+//   - DELETE .../decks/{deckId} takes the card out of a deck without deleting it
+//
+// Each responds with the decks the card is in.
+func CardDecksHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		cardID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid card ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := db.GetCardByID(data, cardID); errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Card not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching card", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		switch {
+		case len(parts) == 6 && r.Method == http.MethodGet:
+			// Nothing to change, only the decks to list
+
+		case len(parts) == 6 && r.Method == http.MethodPost:
+			var body struct {
+				DeckID int `json:"deckId"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			_, err = db.CopyCardsToDeck(data, []int{cardID}, body.DeckID)
+
+		case len(parts) == 7 && r.Method == http.MethodDelete:
+			deckID, err := strconv.Atoi(parts[6])
+			if err != nil {
+				http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+				return
+			}
+			if err := db.RemoveCardFromDeck(data, cardID, deckID); errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Card is not in the deck", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, "Error removing card from deck", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error adding card to deck", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		decks, err := db.GetCardDecks(data, cardID)
+		if err != nil {
+			http.Error(w, "Error fetching decks", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(decks); err != nil {
+			http.Error(w, "Error encoding decks", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// MoveCardsHandler handles POST requests to /api/flashcard/cards/move and
+// /api/flashcard/cards/copy. Moving takes the body's cardIds out of the
+// fromDeckId deck and puts them in the toDeckId deck; copying puts them in
+// the toDeckId deck as well as the decks they are in.
+func MoveCardsHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body struct {
+			CardIDs    []int `json:"cardIds"`
+			FromDeckID int   `json:"fromDeckId"` // only used when moving
+			ToDeckID   int   `json:"toDeckId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.CardIDs) == 0 {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		var n int
+		var err error
+		copying := strings.HasSuffix(r.URL.Path, "/copy")
+		if copying {
+			n, err = db.CopyCardsToDeck(data, body.CardIDs, body.ToDeckID)
+		} else {
+			n, err = db.MoveCardsToDeck(data, body.CardIDs, body.FromDeckID, body.ToDeckID)
+		}
+		if errors.Is(err, db.ErrInvalidMove) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error moving cards", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message string `json:"message"`
+			Cards   int    `json:"cards"` // how many cards were moved or copied
+		}{
+			Message: "Cards moved successfully",
+			Cards:   n,
+		}
+		if copying {
+			response.Message = "Cards copied successfully"
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeckCardsHandler handles requests to /api/flashcard/decks/{id}/cards:
+//   - GET lists the deck's cards, as GetCardsForDeckHandler does
+//   - POST creates a card in the deck
+//   - GET, PUT and DELETE .../cards/{cardID} get, edit and remove a card in the
+//     deck; a card removed from its last deck is deleted
+func DeckCardsHandler(data *sql.DB, media *db.MediaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := db.GetDeckByID(data, deckID); errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching deck", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		if len(parts) == 6 {
+			switch r.Method {
+			case http.MethodGet:
+				GetCardsForDeckHandler(data)(w, r)
+			case http.MethodPost:
+				createDeckCards(data, w, r, deckID)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		cardID, err := strconv.Atoi(parts[6])
+		if err != nil {
+			http.Error(w, "Invalid card ID", http.StatusBadRequest)
+			return
+		}
+		card, err := db.GetDeckCard(data, deckID, cardID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Card not found in deck", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching card", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		var response any
+		switch r.Method {
+		case http.MethodGet:
+			response = card.WithHTML()
+
+		case http.MethodPut:
+			var cardData struct {
+				Type  string `json:"type"`
+				Front string `json:"front"`
+				Back  string `json:"back"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&cardData); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if cardData.Type == "" {
+				cardData.Type = card.Type
+			}
+
+			_, err := db.RunCardBatch(data, []db.BatchOp{{Op: db.BatchUpdate, ID: cardID, Type: cardData.Type, Front: cardData.Front, Back: cardData.Back}})
+			if errors.Is(err, db.ErrInvalidBatch) || errors.Is(err, db.ErrInvalidCloze) || errors.Is(err, db.ErrGeneratedCard) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else if err != nil {
+				http.Error(w, "Error updating card", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+
+			if card, err = db.GetCardByID(data, cardID); err != nil {
+				http.Error(w, "Error fetching card", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			response = struct {
+				Message string  `json:"message"`
+				Card    db.Card `json:"card"`
+			}{
+				Message: "Card updated successfully",
+				Card:    card.WithHTML(),
+			}
+
+		case http.MethodDelete:
+			// Only this deck's copy goes, unless the card is in no other deck
+			deleted, err := db.DeleteCardFromDeck(data, cardID, deckID)
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Card not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, "Error deleting card", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			message := "Card removed from deck successfully"
+			if deleted {
+				collectMedia(data, media)
+				message = "Card deleted successfully"
+			}
+			response = struct {
+				Message string `json:"message"`
+				Deleted bool   `json:"deleted"` // whether the card was in no other deck, so was deleted
+			}{
+				Message: message,
+				Deleted: deleted,
+			}
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// createDeckCards creates the card in the request body in a deck, one per
+// cloze number or with its reverse if asked for, and responds with the
+// cards as they were saved. A card that duplicates one in the deck gets a
+// 409 Conflict with the saved card, unless the request's duplicatePolicy
+// says otherwise.
+func createDeckCards(data *sql.DB, w http.ResponseWriter, r *http.Request, deckID int) {
+	var cardData struct {
+		Type    string `json:"type"` // db.CardTypeBasic (the default) or db.CardTypeCloze
+		Front   string `json:"front"`
+		Back    string `json:"back"`
+		Reverse bool   `json:"reverse"` // also create a reverse card
+	}
+	if err := json.NewDecoder(r.Body).Decode(&cardData); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// A cloze card's back is optional extra text
+	if cardData.Front == "" || (cardData.Back == "" && cardData.Type != db.CardTypeCloze) {
+		http.Error(w, "Front and back content cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	results, err := db.RunCardBatch(data, []db.BatchOp{{
+		Op:      db.BatchCreate,
+		DeckID:  deckID,
+		Type:    cardData.Type,
+		Front:   cardData.Front,
+		Back:    cardData.Back,
+		Reverse: cardData.Reverse,
+
+		DuplicatePolicy: duplicatePolicy(r),
+	}})
+	if writeDuplicateConflict(w, err) {
+		return
+	} else if errors.Is(err, db.ErrInvalidBatch) || errors.Is(err, db.ErrInvalidCloze) || errors.Is(err, db.ErrInvalidDuplicates) || errors.Is(err, db.ErrGeneratedCard) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error inserting card", http.StatusInternalServerError)
+		log.Print(err)
+		return
+	}
+
+	// A skipped duplicate leaves only the saved card to respond with
+	result := results[0]
+	ids, status, message := result.IDs, http.StatusCreated, "Card created and added to deck successfully"
+	if result.DuplicateOf != 0 {
+		ids, status, message = []int{result.DuplicateOf}, http.StatusOK, "Duplicate card skipped"
+		if len(result.IDs) > 0 {
+			message = "Duplicate card updated"
+		}
+	}
+
+	cards := []db.Card{}
+	for _, id := range ids {
+		card, err := db.GetCardByID(data, id)
+		if err != nil {
+			http.Error(w, "Error fetching card", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+		cards = append(cards, card.WithHTML())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	response := struct {
+		Message     string    `json:"message"`
+		Card        db.Card   `json:"card"`                  // the first card created, or the duplicated card
+		Cards       []db.Card `json:"cards"`                 // every card created, such as one per cloze number
+		DuplicateOf int       `json:"duplicateOf,omitempty"` // the saved card the new card duplicated
+	}{
+		Message:     message,
+		Card:        cards[0],
+		Cards:       cards,
+		DuplicateOf: result.DuplicateOf,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// duplicatePolicy reads how to handle new cards that duplicate saved cards
+// from a request's ?onDuplicate=reject|skip|update|allow and
+// ?duplicateScope=deck|collection. The policy is validated where it is used.
+func duplicatePolicy(r *http.Request) db.DuplicatePolicy {
+	return db.DuplicatePolicy{
+		Scope:       r.URL.Query().Get("duplicateScope"),
+		OnDuplicate: r.URL.Query().Get("onDuplicate"),
+	}
+}
+
+// writeDuplicateConflict responds with 409 Conflict and the saved card that
+// a new card duplicates, if err is a db.DuplicateCardError. It reports
+// whether it did.
+func writeDuplicateConflict(w http.ResponseWriter, err error) bool {
+	var duplicate *db.DuplicateCardError
+	if !errors.As(err, &duplicate) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	response := struct {
+		Message string  `json:"message"`
+		Card    db.Card `json:"card"` // the saved card
+	}{
+		Message: err.Error(),
+		Card:    duplicate.Card.WithHTML(),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Print(err)
+	}
+	return true
+}
+
+// DuplicatesHandler handles GET requests to /api/flashcard/decks/{id}/duplicates,
+// listing the deck's cards whose fronts are the same once normalised, in
+// groups. With ?duplicateScope=collection, as when creating cards, cards are
+// grouped with their duplicates in any deck.
+func DuplicatesHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := db.GetDeckByID(data, deckID); errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching deck", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		groups, err := db.GetDuplicateCards(data, deckID, duplicatePolicy(r).Scope)
+		if errors.Is(err, db.ErrInvalidDuplicates) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error finding duplicate cards", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+		for _, group := range *groups {
+			for i, card := range group.Cards {
+				group.Cards[i] = card.WithHTML()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(groups); err != nil {
+			http.Error(w, "Error encoding duplicate cards", http.StatusInternalServerError)
+			return
+		}
+	}
+}
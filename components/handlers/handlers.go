@@ -3,7 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"learn_go/db"
 	"log"
 	"net/http"
@@ -21,15 +21,28 @@ func RandomFlashcardHandler(data *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		card, err := db.GetRandomCard(data)
+		// ?tag= narrows the cards to pick from, see db.TagFilter
+		var opts []db.CardOption
+		tagOption, err := tagFilterOption(r)
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if tagOption != nil {
+			opts = append(opts, tagOption)
+		}
+
+		card, err := db.GetRandomCard(data, opts...)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No cards to study", http.StatusNotFound)
+			return
+		} else if err != nil {
 			http.Error(w, "Error fetching card", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json") // Set JSON content type
 
-		if err := json.NewEncoder(w).Encode(card); err != nil { // Encode card as JSON
+		if err := json.NewEncoder(w).Encode(card.Rendered()); err != nil { // Encode card as JSON
 			http.Error(w, "Error encoding card", http.StatusInternalServerError)
 			return
 		}
@@ -51,11 +64,28 @@ func GetCardsForDeckHandler(data *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		cards, err := db.GetCardsFromDeck(data, deckID)
+		// Suspended and buried cards are included for editing unless ?visible=true,
+		// and ?tag= filters the cards by their tags, see db.TagFilter
+		var opts []db.CardOption
+		if r.URL.Query().Get("visible") == "true" {
+			opts = append(opts, db.VisibleOnly())
+		}
+		tagOption, err := tagFilterOption(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if tagOption != nil {
+			opts = append(opts, tagOption)
+		}
+
+		cards, err := db.GetCardsFromDeck(data, deckID, opts...)
 		if err != nil {
 			http.Error(w, "Error fetching cards", http.StatusInternalServerError)
 			return
 		}
+		for i, card := range *cards {
+			(*cards)[i] = card.WithHTML()
+		}
 
 		w.Header().Set("Content-Type", "application/json") // Set JSON content type
 
@@ -73,7 +103,13 @@ func GetDecksHandler(data *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		decks, err := db.GetDecksData(data)
+		// Archived decks are only listed with ?archived=true
+		var opts []db.DeckOption
+		if r.URL.Query().Get("archived") == "true" {
+			opts = append(opts, db.IncludeArchived())
+		}
+
+		decks, err := db.GetDecksData(data, opts...)
 		if err != nil {
 			http.Error(w, "Error fetching decks", http.StatusInternalServerError)
 			return
@@ -114,42 +150,314 @@ func RateFlashcardHandler(data *sql.DB) http.HandlerFunc {
 		}
 
 		rating, err := strconv.Atoi(ratingStr)
-		if err != nil {
+		if err != nil || rating < db.MinRating || rating > db.MaxRating {
 			http.Error(w, "Invalid Rating", http.StatusBadRequest)
 			return
 		}
 
-		// Print form values
-		fmt.Printf("ID: %d, Rating: %d\n", id, rating)
+		// The deck being studied, study session and answer latency (in ms) are optional
+		review := db.Review{CardID: id, Rating: rating}
+		if deckStr := r.FormValue("DeckID"); deckStr != "" {
+			if review.DeckID, err = strconv.Atoi(deckStr); err != nil {
+				http.Error(w, "Invalid DeckID", http.StatusBadRequest)
+				return
+			}
+		}
+		if sessionStr := r.FormValue("SessionID"); sessionStr != "" {
+			if review.SessionID, err = strconv.Atoi(sessionStr); err != nil {
+				http.Error(w, "Invalid SessionID", http.StatusBadRequest)
+				return
+			}
+		}
+		if latencyStr := r.FormValue("Latency"); latencyStr != "" {
+			latency, err := strconv.Atoi(latencyStr)
+			if err != nil || latency < 0 {
+				http.Error(w, "Invalid Latency", http.StatusBadRequest)
+				return
+			}
+			review.Latency = time.Duration(latency) * time.Millisecond
+		}
+
+		// Reschedule the card based on the rating
+		card, err := db.ReviewCard(data, review)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Card not found", http.StatusNotFound)
+			return
+		} else if errors.Is(err, db.ErrSessionClosed) {
+			http.Error(w, "Study session is closed", http.StatusConflict)
+			return
+		} else if errors.Is(err, db.ErrCardSuspended) {
+			http.Error(w, "Card is suspended", http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, "Error rating card", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		// Respond with the card's new schedule
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message string  `json:"message"`
+			Card    db.Card `json:"card"`
+		}{
+			Message: "Card rated successfully",
+			Card:    card.WithHTML(),
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// CardReviewsHandler handles GET requests to /api/flashcard/cards/{id}/reviews,
+// returning the card's full review history
+func CardReviewsHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		cardID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid card ID", http.StatusBadRequest)
+			return
+		}
+
+		reviews, err := db.GetReviewsForCard(data, cardID)
+		if err != nil {
+			http.Error(w, "Error fetching reviews", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(reviews); err != nil {
+			http.Error(w, "Error encoding reviews", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DueCardsHandler handles GET requests to /api/flashcard/decks/{id}/due,
+// returning the deck's due cards in the order they should be studied.
+// Each kind of card is capped by what is left of the deck's daily limits,
+// unless overridden with the new, learning and review query parameters.
+func DueCardsHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		// Limits default to what is left of the deck's daily limits
+		limits, err := db.GetDueLimits(data, deckID, time.Now())
+		if err != nil {
+			http.Error(w, "Error fetching deck settings", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+		query := r.URL.Query()
+		for name, limit := range map[string]*int{"new": &limits.New, "learning": &limits.Learning, "review": &limits.Review} {
+			if value := query.Get(name); value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					http.Error(w, "Invalid "+name+" limit", http.StatusBadRequest)
+					return
+				}
+				*limit = n
+			}
+		}
+
+		cards, err := db.GetDueCards(data, deckID, limits, time.Now())
+		if err != nil {
+			http.Error(w, "Error fetching due cards", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+		for i, card := range *cards {
+			(*cards)[i] = card.Rendered()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(cards); err != nil {
+			http.Error(w, "Error encoding cards", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// LeechesHandler handles GET requests to /api/flashcard/decks/{id}/leeches,
+// returning the deck's leeches, most lapsed first
+func LeechesHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		cards, err := db.GetLeeches(data, deckID)
+		if err != nil {
+			http.Error(w, "Error fetching leeches", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+		for i, card := range *cards {
+			(*cards)[i] = card.WithHTML()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(cards); err != nil {
+			http.Error(w, "Error encoding cards", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// deckSettingsHandler handles GET and PUT requests to /api/flashcard/decks/{id}/settings
+func deckSettingsHandler(data *sql.DB, w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	deckID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	// Decks without settings of their own use the defaults, so check the deck is there
+	if _, err := db.GetDeckByID(data, deckID); errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error fetching deck", http.StatusInternalServerError)
+		log.Print(err)
+		return
+	}
+
+	var settings db.DeckSettings
+	if r.Method == http.MethodGet {
+		settings, err = db.GetDeckSettings(data, deckID)
+		if err != nil {
+			http.Error(w, "Error fetching deck settings", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+	} else if r.Method == http.MethodPut {
+		// Fields missing from the body keep their current values
+		settings, err = db.GetDeckSettings(data, deckID)
+		if err != nil {
+			http.Error(w, "Error fetching deck settings", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		settings.DeckID = deckID
+
+		err = db.SaveDeckSettings(data, settings)
+		if errors.Is(err, db.ErrInvalidDeckSettings) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error saving deck settings", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		http.Error(w, "Error encoding deck settings", http.StatusInternalServerError)
+		return
 	}
 }
 
 func DeckHandler(data *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Per-deck settings live under /api/flashcard/decks/{id}/settings
+		if parts := strings.Split(r.URL.Path, "/"); len(parts) > 5 && parts[5] == "settings" {
+			deckSettingsHandler(data, w, r)
+			return
+		}
+
 		if r.Method == http.MethodPost {
 			// Decode the deck name from the request body
 			var deckName struct {
-				Name string `json:"name"`
+				Name      string `json:"name"`
+				Scheduler string `json:"scheduler"`
+				ParentID  *int   `json:"parentId"` // the deck to nest the new deck in, if any
 			}
 			if err := json.NewDecoder(r.Body).Decode(&deckName); err != nil {
 				http.Error(w, "Invalid request body", http.StatusBadRequest)
 				return
 			}
 
-			// Check if the deck name is empty (add validation as needed)
-			if deckName.Name == "" {
-				http.Error(w, "Deck name cannot be empty", http.StatusBadRequest)
+			if err := (db.Deck{Name: deckName.Name}).Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if _, err := db.GetScheduler(deckName.Scheduler); err != nil {
+				http.Error(w, "Unknown scheduler", http.StatusBadRequest)
 				return
 			}
 
+			if deckName.ParentID != nil {
+				if _, err := db.GetDeckByID(data, *deckName.ParentID); errors.Is(err, sql.ErrNoRows) {
+					http.Error(w, "Parent deck not found", http.StatusBadRequest)
+					return
+				} else if err != nil {
+					http.Error(w, "Error fetching parent deck", http.StatusInternalServerError)
+					log.Print(err)
+					return
+				}
+			}
+
 			// Insert the deck into the database
-			_, err := db.InsertDeck(data, deckName.Name)
+			var deckID int64
+			var err error
+			if deckName.ParentID != nil {
+				deckID, err = db.InsertSubdeck(data, deckName.Name, *deckName.ParentID)
+			} else {
+				deckID, err = db.InsertDeck(data, deckName.Name)
+			}
 			if err != nil {
 				http.Error(w, "Error creating deck", http.StatusInternalServerError)
 				return
 			}
 
+			// New decks use the default scheduler unless asked otherwise
+			if deckName.Scheduler != "" && deckName.Scheduler != db.DefaultScheduler {
+				if err := db.SetDeckScheduler(data, int(deckID), deckName.Scheduler); err != nil {
+					http.Error(w, "Error setting deck scheduler", http.StatusInternalServerError)
+					log.Print(err)
+					return
+				}
+			}
+
 			// Optionally: You could return the ID of the newly created deck
 			response := struct {
 				DeckName string
@@ -172,7 +480,7 @@ func DeckHandler(data *sql.DB) http.HandlerFunc {
 				return
 			}
 
-			// Delete the deck from the database
+			// Delete the deck, and the decks nested in it, from the database
 			err = db.DeleteDeckByID(data, deckID)
 			if err != nil {
 				http.Error(w, "Error deleting deck", http.StatusInternalServerError)
@@ -181,83 +489,256 @@ func DeckHandler(data *sql.DB) http.HandlerFunc {
 
 			// Respond with a success message or status
 			w.WriteHeader(http.StatusNoContent) // 204 No Content is common for successful DELETE
-		}
-	}
-}
-
-func CardHandler(data *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
-			// ... (Decoding and validation from the previous implementation)
-			var cardData struct {
-				Front string `json:"front"`
-				Back  string `json:"back"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&cardData); err != nil {
-				http.Error(w, "Invalid request body", http.StatusConflict)
-				fmt.Printf("Error decoding request body: %v\n", err)
+		} else if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+			parts := strings.Split(r.URL.Path, "/")
+			deckID, err := strconv.Atoi(parts[4])
+			if err != nil {
+				http.Error(w, "Invalid deck ID", http.StatusBadRequest)
 				return
 			}
 
-			// Validate card data (add more checks as needed)
-			if cardData.Front == "" || cardData.Back == "" {
-				http.Error(w, "Front and back content cannot be empty", http.StatusBadRequest)
-				fmt.Printf("Front and back content cannot be empty\n")
+			deck, err := db.GetDeckByID(data, deckID)
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Deck not found", http.StatusNotFound)
 				return
-			}
-
-			// Create the Card object
-			newCard, err := db.CreateCard(0, cardData.Front, cardData.Back, int64(time.Now().Nanosecond()), 0)
-			if err != nil {
-				http.Error(w, "Error creating card", http.StatusInternalServerError)
+			} else if err != nil {
+				http.Error(w, "Error fetching deck", http.StatusInternalServerError)
+				log.Print(err)
 				return
 			}
 
-			// Insert the card and get the ID
-			insertedIDs, err := db.InsertCards(data, []db.Card{newCard})
-			if err != nil {
-				http.Error(w, "Error inserting card", http.StatusInternalServerError)
-				return
+			// PUT replaces all of the fields, PATCH only those in the body
+			type deckFields struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+				Color       string `json:"color"`
+				Icon        string `json:"icon"`
+				Archived    bool   `json:"archived"`
 			}
-
-			// Get the deck ID from the URL path (assuming the path is /projects/flashcard/edit/{deck_id})
-			parts := strings.Split(r.Header.Get("Referer"), "/")
-			deckID, err := strconv.Atoi(parts[6])
-
-			if err != nil {
-				http.Error(w, "Invalid deck ID in URL", http.StatusBadRequest)
+			var fields deckFields
+			if r.Method == http.MethodPatch {
+				fields = deckFields{deck.Name, deck.Description, deck.Color, deck.Icon, deck.Archived}
+			}
+			if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
 				return
 			}
+			deck.Name, deck.Description, deck.Color, deck.Icon, deck.Archived = fields.Name, fields.Description, fields.Color, fields.Icon, fields.Archived
 
-			// Add the card to the deck
-			if len(insertedIDs) > 0 { // Check if we got an ID back
-				cardID := insertedIDs[0]
-				log.Printf("Adding card with ID %d to deck %d\n", cardID, deckID)
-				err = db.AddCardToDeck(data, int(cardID), deckID)
-				if err != nil {
-					http.Error(w, "Error adding card to deck", http.StatusInternalServerError)
-					log.Print(err)
-					return
-				}
-			} else {
-				// Handle the case where no ID was returned (this shouldn't happen if InsertCards is working correctly)
-				http.Error(w, "Card created but ID not found", http.StatusInternalServerError)
+			updated, err := db.UpdateDeck(data, *deck)
+			if errors.Is(err, db.ErrInvalidDeck) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Deck not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, "Error updating deck", http.StatusInternalServerError)
+				log.Print(err)
 				return
 			}
 
-			// Respond with success
 			w.Header().Set("Content-Type", "application/json")
 			response := struct {
 				Message string  `json:"message"`
-				Card    db.Card `json:"card"` // Optional: Include card details
+				Deck    db.Deck `json:"deck"`
 			}{
-				Message: "Card created and added to deck successfully",
-				Card:    newCard,
+				Message: "Deck updated successfully",
+				Deck:    *updated,
 			}
 			if err := json.NewEncoder(w).Encode(response); err != nil {
 				http.Error(w, "Error encoding response", http.StatusInternalServerError)
 				return
 			}
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// DeckSchedulerHandler handles PUT requests to /api/flashcard/decks/{id}/scheduler,
+// switching the scheduling algorithm used for the deck's cards
+func DeckSchedulerHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		var body struct {
+			Scheduler string `json:"scheduler"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		err = db.SetDeckScheduler(data, deckID, body.Scheduler)
+		if errors.Is(err, db.ErrUnknownScheduler) {
+			http.Error(w, "Unknown scheduler", http.StatusBadRequest)
+			return
+		} else if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error setting deck scheduler", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message   string `json:"message"`
+			Scheduler string `json:"scheduler"`
+		}{
+			Message:   "Deck scheduler updated successfully",
+			Scheduler: body.Scheduler,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeckParentHandler handles PUT requests to /api/flashcard/decks/{id}/parent,
+// nesting the deck in the body's parentId deck, or moving it to the top
+// level when parentId is null. The decks nested in it move along with it.
+func DeckParentHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		var body struct {
+			ParentID *int `json:"parentId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		err = db.MoveDeck(data, deckID, body.ParentID)
+		if errors.Is(err, db.ErrInvalidParentDeck) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error moving deck", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message  string `json:"message"`
+			ParentID *int   `json:"parentId"`
+		}{
+			Message:  "Deck moved successfully",
+			ParentID: body.ParentID,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// cardVisibilityHandler handles POST requests to /api/flashcard/cards/{id}/{action},
+// where action is one of suspend, unsuspend, bury or unbury. Suspended and
+// buried cards are not studied but can still be edited.
+func cardVisibilityHandler(data *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	cardID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	var message string
+	switch parts[5] {
+	case "suspend":
+		err = db.SuspendCard(data, cardID)
+		message = "Card suspended successfully"
+	case "unsuspend":
+		err = db.UnsuspendCard(data, cardID)
+		message = "Card unsuspended successfully"
+	case "bury":
+		err = db.BuryCard(data, cardID, time.Now())
+		message = "Card buried until tomorrow"
+	case "unbury":
+		err = db.UnburyCard(data, cardID)
+		message = "Card unburied successfully"
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error updating card", http.StatusInternalServerError)
+		log.Print(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Message string `json:"message"`
+	}{
+		Message: message,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func CardHandler(data *sql.DB, media *db.MediaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if parts := strings.Split(r.URL.Path, "/"); len(parts) > 5 {
+			cardVisibilityHandler(data, w, r)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			// Get the deck ID from the page the request came from, such as
+			// /projects/flashcard/edit/{deck_id}. Clients other than the
+			// edit page should POST to /api/flashcard/decks/{id}/cards.
+			parts := strings.Split(r.Header.Get("Referer"), "/")
+			if len(parts) < 7 {
+				http.Error(w, "Invalid deck ID in URL", http.StatusBadRequest)
+				return
+			}
+			deckID, err := strconv.Atoi(parts[6])
+			if err != nil {
+				http.Error(w, "Invalid deck ID in URL", http.StatusBadRequest)
+				return
+			}
+
+			createDeckCards(data, w, r, deckID)
 		} else if r.Method == http.MethodDelete {
             // Decode the card ID from the request body
             var cardData struct {
@@ -276,10 +757,14 @@ func CardHandler(data *sql.DB) http.HandlerFunc {
 
             // Delete the card from the database
             err := db.DeleteCardByID(data, cardData.ID)
-            if err != nil {
+            if errors.Is(err, sql.ErrNoRows) {
+                http.Error(w, "Card not found", http.StatusNotFound)
+                return
+            } else if err != nil {
                 http.Error(w, "Error deleting card", http.StatusInternalServerError)
                 return
             }
+            collectMedia(data, media)
 
             // Respond with success
             w.Header().Set("Content-Type", "application/json")
@@ -300,27 +785,61 @@ func CardHandler(data *sql.DB) http.HandlerFunc {
                 return
             }
 
+            if updatedCard.ID == 0 {
+                http.Error(w, "Front, back, and ID are required", http.StatusBadRequest)
+                return
+            }
+
+            // A card keeps its type, so validate against the stored one
+            saved, err := db.GetCardByID(data, updatedCard.ID)
+            if errors.Is(err, sql.ErrNoRows) {
+                http.Error(w, "Card not found", http.StatusNotFound)
+                return
+            } else if err != nil {
+                http.Error(w, "Error fetching card", http.StatusInternalServerError)
+                log.Print(err)
+                return
+            }
+            updatedCard.Type = saved.Type
+
             // Validate card data (similar to how you validate in POST)
-            if updatedCard.Front == "" || updatedCard.Back == "" || updatedCard.ID == 0 {
+            if updatedCard.Front == "" || (updatedCard.Back == "" && updatedCard.Type != db.CardTypeCloze) {
                 http.Error(w, "Front, back, and ID are required", http.StatusBadRequest)
                 return
             }
+            if updatedCard.Type == db.CardTypeCloze && len(db.ClozeNumbers(updatedCard.Front)) == 0 {
+                http.Error(w, "Cloze text has no deletions", http.StatusBadRequest)
+                return
+            }
 
             // Update the card in the database
-            err := db.UpdateCard(data, updatedCard)
-            if err != nil {
+            err = db.UpdateCard(data, updatedCard)
+            if errors.Is(err, db.ErrGeneratedCard) {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            } else if errors.Is(err, sql.ErrNoRows) {
+                http.Error(w, "Card not found", http.StatusNotFound)
+                return
+            } else if err != nil {
                 http.Error(w, "Error updating card", http.StatusInternalServerError)
+                log.Print(err)
                 return
             }
 
-            // Respond with success
+            // Respond with the card as it was saved
+            card, err := db.GetCardByID(data, updatedCard.ID)
+            if err != nil {
+                http.Error(w, "Error fetching card", http.StatusInternalServerError)
+                log.Print(err)
+                return
+            }
             w.Header().Set("Content-Type", "application/json")
             response := struct {
                 Message string  `json:"message"`
                 Card    db.Card `json:"card"`
             }{
                 Message: "Card updated successfully",
-                Card:    updatedCard,
+                Card:    card.WithHTML(),
             }
             if err := json.NewEncoder(w).Encode(response); err != nil {
                 http.Error(w, "Error encoding response", http.StatusInternalServerError)
@@ -329,3 +848,30 @@ func CardHandler(data *sql.DB) http.HandlerFunc {
         }
     }
 }
+
+// PreviewHandler handles POST requests to /api/flashcard/preview, rendering
+// a card's front and back as they would be studied without saving it. A
+// cloze card is previewed for its first cloze number.
+func PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var card db.Card
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if card.Type == db.CardTypeCloze && card.Cloze == 0 {
+		if numbers := db.ClozeNumbers(card.Front); len(numbers) > 0 {
+			card.Cloze = numbers[0]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(card.Rendered().HTML); err != nil {
+		http.Error(w, "Error encoding preview", http.StatusInternalServerError)
+		return
+	}
+}
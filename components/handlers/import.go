@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxImportSize is the largest upload accepted by the import handlers.
+const maxImportSize = 100 << 20
+
+// ImportAPKGHandler handles POST requests to /api/flashcard/import/apkg,
+// importing the Anki package uploaded as the file form value
+func ImportAPKGHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Missing or too large .apkg file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		report, err := db.ImportAPKG(data, file, header.Size, time.Now(), db.WithDuplicates(duplicatePolicy(r)))
+		if errors.Is(err, db.ErrInvalidAPKG) || errors.Is(err, db.ErrInvalidDuplicates) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error importing deck", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message string          `json:"message"`
+			Report  db.ImportReport `json:"report"`
+		}{
+			Message: "Deck imported successfully",
+			Report:  *report,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ImportDeckHandler handles POST requests to /api/flashcard/decks/{id}/import,
+// adding the cards in a CSV or TSV file to the deck. The file is uploaded as
+// the file form value or sent as the request body. Its layout is described by
+// the optional query parameters, or form values alongside an uploaded file:
+//   - delimiter: the separator, "tab" or a single character; defaults to a tab
+//     for .tsv files and a comma otherwise
+//   - header: "false" if the first row holds a card rather than column names
+//   - front, back, tags: the header names of the columns holding each field
+//   - quotes: "false" to treat quotes as ordinary text
+func ImportDeckHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		// Options are only read from the body alongside an uploaded file, as
+		// parsing any other body as a form would consume the cards in it
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+		var body io.Reader = r.Body
+		options := r.URL.Query()
+		filename := ""
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "Missing or too large file", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			body, filename, options = file, header.Filename, r.Form
+		}
+
+		delimiter, err := parseDelimiter(options.Get("delimiter"), filename)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts := db.CSVOptions{
+			Delimiter: delimiter,
+			Header:    options.Get("header") != "false",
+			Columns: map[string]string{
+				db.CSVFront: options.Get(db.CSVFront),
+				db.CSVBack:  options.Get(db.CSVBack),
+				db.CSVTags:  options.Get(db.CSVTags),
+			},
+			NoQuotes: options.Get("quotes") == "false",
+		}
+
+		report, err := db.ImportCSV(data, deckID, body, opts, db.WithDuplicates(duplicatePolicy(r)))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if errors.Is(err, db.ErrInvalidCSV) || errors.Is(err, db.ErrInvalidDuplicates) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error importing cards", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message string          `json:"message"`
+			Report  db.ImportReport `json:"report"`
+		}{
+			Message: "Cards imported successfully",
+			Report:  *report,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ExportDeckHandler handles GET requests to /api/flashcard/decks/{id}/export.csv,
+// downloading the deck's cards as CSV, or as TSV with ?delimiter=tab
+func ExportDeckHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		delimiter, err := parseDelimiter(r.URL.Query().Get("delimiter"), "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		deck, err := db.GetDeckByID(data, deckID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error getting deck", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		contentType, extension := "text/csv", "csv"
+		if delimiter == '\t' {
+			contentType, extension = "text/tab-separated-values", "tsv"
+		}
+		w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", deck.Name+"."+extension))
+		if err := db.ExportCSV(data, deckID, w, delimiter); err != nil {
+			// The headers may already be sent, so all we can do is log it
+			log.Print(err)
+		}
+	}
+}
+
+// parseDelimiter reads a delimiter form value, guessing from the uploaded
+// file's name when it is empty
+func parseDelimiter(value string, filename string) (rune, error) {
+	switch {
+	case value == "":
+		if strings.HasSuffix(strings.ToLower(filename), ".tsv") {
+			return '\t', nil
+		}
+		return ',', nil
+	case value == "tab" || value == "\\t":
+		return '\t', nil
+	case utf8.RuneCountInString(value) == 1:
+		delimiter, _ := utf8.DecodeRuneInString(value)
+		if delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+			return 0, fmt.Errorf("invalid delimiter %q", value)
+		}
+		return delimiter, nil
+	default:
+		return 0, fmt.Errorf("invalid delimiter %q", value)
+	}
+}
+
+// ImportMarkdownHandler handles POST requests to /api/flashcard/import/markdown,
+// importing the Markdown deck uploaded as the file form value or sent as the
+// request body. Files without a top heading go in the deck named by the deck
+// query parameter, or form value alongside an uploaded file, or by the file's
+// name.
+func ImportMarkdownHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// As with CSV, a body that isn't an upload is the deck itself
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+		var body io.Reader = r.Body
+		deckName := r.URL.Query().Get("deck")
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "Missing or too large file", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			body, deckName = file, r.FormValue("deck")
+			if deckName == "" {
+				deckName = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+			}
+		}
+
+		report, err := db.ImportMarkdown(data, body, deckName, db.WithDuplicates(duplicatePolicy(r)))
+		if errors.Is(err, db.ErrInvalidMarkdown) || errors.Is(err, db.ErrInvalidDuplicates) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error importing deck", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message string          `json:"message"`
+			Report  db.ImportReport `json:"report"`
+		}{
+			Message: "Deck imported successfully",
+			Report:  *report,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ExportMarkdownHandler handles GET requests to /api/flashcard/decks/{id}/export.md,
+// downloading the deck as a Markdown deck
+func ExportMarkdownHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		deck, err := db.GetDeckByID(data, deckID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error getting deck", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", deck.Name+".md"))
+		if err := db.ExportMarkdown(data, deckID, w); err != nil {
+			// The headers may already be sent, so all we can do is log it
+			log.Print(err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// MediaUploadHandler handles POST requests to /api/flashcard/media, storing
+// the image or audio clip in the multipart form's file field. The response
+// includes the Markdown that shows it on a card.
+func MediaUploadHandler(data *sql.DB, media *db.MediaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Leave room for the rest of the form around the file
+		r.Body = http.MaxBytesReader(w, r.Body, media.MaxSize+1<<20)
+		file, header, err := r.FormFile("file")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, "Missing file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		saved, err := media.Save(data, header.Filename, header.Header.Get("Content-Type"), file)
+		if errors.Is(err, db.ErrInvalidMedia) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error saving media", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		url := "/media/" + saved.Hash
+		markdown := fmt.Sprintf("![%s](%s)", strings.NewReplacer("[", "", "]", "").Replace(saved.Filename), url)
+		if !strings.HasPrefix(saved.ContentType, "image/") {
+			markdown = fmt.Sprintf(`<audio controls src="%s"></audio>`, url)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		response := struct {
+			Media    db.Media `json:"media"`
+			URL      string   `json:"url"`
+			Markdown string   `json:"markdown"` // to paste into a card's text
+		}{
+			Media:    *saved,
+			URL:      url,
+			Markdown: markdown,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// MediaHandler handles GET requests to /media/{hash}. Media never changes
+// under its hash, so it can be cached for good.
+func MediaHandler(data *sql.DB, media *db.MediaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		hash := strings.TrimPrefix(r.URL.Path, "/media/")
+		if !db.ValidMediaHash(hash) {
+			http.Error(w, "Media not found", http.StatusNotFound)
+			return
+		}
+
+		saved, err := db.GetMedia(data, hash)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Media not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching media", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		file, err := os.Open(media.Path(hash))
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "Media not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error reading media", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", saved.ContentType)
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("ETag", `"`+hash+`"`)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, "", saved.CreatedAt, file)
+	}
+}
+
+// collectMedia deletes media that deleted cards have left unused. Failing
+// to is logged rather than failing the deletion.
+func collectMedia(data *sql.DB, media *db.MediaStore) {
+	hashes, err := media.CollectGarbage(data, time.Now())
+	if err != nil {
+		log.Print(err)
+	}
+	if len(hashes) > 0 {
+		log.Printf("Deleted %d unused media files\n", len(hashes))
+	}
+}
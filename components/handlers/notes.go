@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NoteTypesHandler handles requests to /api/flashcard/note-types:
+//   - GET lists the note types, built-in ones first
+//   - POST adds a note type from its name, card type, fields and templates
+func NoteTypesHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var response any
+		if r.Method == http.MethodGet {
+			noteTypes, err := db.GetNoteTypes(data)
+			if err != nil {
+				http.Error(w, "Error fetching note types", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			response = noteTypes
+		} else if r.Method == http.MethodPost {
+			var noteType db.NoteType
+			if err := json.NewDecoder(r.Body).Decode(&noteType); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if noteType.CardType == "" {
+				noteType.CardType = db.CardTypeBasic
+			}
+
+			id, err := db.InsertNoteType(data, noteType)
+			if errors.Is(err, db.ErrInvalidNoteType) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else if err != nil {
+				http.Error(w, "Error creating note type", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			noteType.ID = id
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			response = struct {
+				Message  string      `json:"message"`
+				NoteType db.NoteType `json:"noteType"`
+			}{
+				Message:  "Note type created successfully",
+				NoteType: noteType,
+			}
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// NoteTypeHandler handles GET requests to /api/flashcard/note-types/{id}
+func NoteTypeHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		noteTypeID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid note type ID", http.StatusBadRequest)
+			return
+		}
+
+		noteType, err := db.GetNoteType(data, noteTypeID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Note type not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching note type", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(noteType); err != nil {
+			http.Error(w, "Error encoding note type", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// NotesHandler handles POST requests to /api/flashcard/notes, adding a note
+// and the cards it generates to a deck
+func NotesHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body struct {
+			NoteTypeID int               `json:"noteTypeId"`
+			Fields     map[string]string `json:"fields"`
+			DeckID     int               `json:"deckId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if body.DeckID <= 0 {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		note := db.Note{NoteTypeID: body.NoteTypeID, Fields: body.Fields}
+		cardIDs, err := db.InsertNote(data, &note)
+		if errors.Is(err, db.ErrInvalidNote) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error creating note", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		for _, cardID := range cardIDs {
+			if err := db.AddCardToDeck(data, cardID, body.DeckID); err != nil {
+				http.Error(w, "Error adding card to deck", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		response := struct {
+			Message string  `json:"message"`
+			Note    db.Note `json:"note"`
+			CardIDs []int   `json:"cardIds"`
+		}{
+			Message: "Note created successfully",
+			Note:    note,
+			CardIDs: cardIDs,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// NoteHandler handles requests to /api/flashcard/notes/{id}:
+//   - GET returns the note
+//   - PUT saves the note's fields and generates its cards again
+//   - DELETE deletes the note along with its cards
+func NoteHandler(data *sql.DB, media *db.MediaStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		noteID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid note ID", http.StatusBadRequest)
+			return
+		}
+
+		var response any
+		switch r.Method {
+		case http.MethodGet:
+			note, err := db.GetNote(data, noteID)
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Note not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, "Error fetching note", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			response = note
+
+		case http.MethodPut:
+			var body struct {
+				Fields map[string]string `json:"fields"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			err := db.UpdateNote(data, db.Note{ID: noteID, Fields: body.Fields})
+			if errors.Is(err, db.ErrInvalidNote) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Note not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, "Error updating note", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			response = struct {
+				Message string `json:"message"`
+			}{
+				Message: "Note updated successfully",
+			}
+
+		case http.MethodDelete:
+			if err := db.DeleteNote(data, noteID); err != nil {
+				http.Error(w, "Error deleting note", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			collectMedia(data, media)
+			w.WriteHeader(http.StatusNoContent)
+			return
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
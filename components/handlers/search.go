@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// SearchHandler handles GET requests to /api/flashcard/search?q=, returning
+// the cards whose front or back match, best matches first. ?limit= and
+// ?offset= page through the results.
+func SearchHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		limit, offset := db.DefaultSearchLimit, 0
+		for name, n := range map[string]*int{"limit": &limit, "offset": &offset} {
+			if value := query.Get(name); value != "" {
+				var err error
+				if *n, err = strconv.Atoi(value); err != nil {
+					http.Error(w, "Invalid "+name, http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		results, err := db.SearchCards(data, query.Get("q"), limit, offset)
+		if errors.Is(err, db.ErrInvalidSearch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error searching cards", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+		for i, result := range *results {
+			(*results)[i].Card = result.Card.WithHTML()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			http.Error(w, "Error encoding results", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StudySessionsHandler handles POST requests to /api/flashcard/decks/{id}/sessions,
+// starting a study session over the deck's due cards
+func StudySessionsHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+
+		session, err := db.CreateStudySession(data, deckID, time.Now())
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deck not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error creating study session", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		response := struct {
+			Message string          `json:"message"`
+			Session db.StudySession `json:"session"`
+		}{
+			Message: "Study session created successfully",
+			Session: *session,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// StudySessionHandler handles requests to /api/flashcard/decks/{id}/sessions/{sid}:
+//   - GET returns the session's progress
+//   - GET .../next returns the next card to study, or a null card once the queue is empty
+//   - POST .../skip moves the card given by the ID form value to the back of the queue
+//   - POST .../close ends the session and returns its summary
+func StudySessionHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 7 {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		deckID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+			return
+		}
+		sessionID, err := strconv.Atoi(parts[6])
+		if err != nil {
+			http.Error(w, "Invalid session ID", http.StatusBadRequest)
+			return
+		}
+		action := ""
+		if len(parts) > 7 {
+			action = parts[7]
+		}
+
+		session, err := db.GetStudySession(data, sessionID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && session.DeckID != deckID) {
+			http.Error(w, "Study session not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching study session", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		var response any
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			response = session
+
+		case action == "next" && r.Method == http.MethodGet:
+			card, waitUntil, err := db.NextSessionCard(data, sessionID, time.Now())
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Error fetching next card", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			if card != nil {
+				rendered := card.Rendered()
+				card = &rendered
+			}
+			response = struct {
+				Session   db.StudySession `json:"session"`
+				Card      *db.Card        `json:"card"`
+				WaitUntil *time.Time      `json:"waitUntil,omitempty"` // when the next learning card is due, if none is yet
+			}{
+				Session:   *session,
+				Card:      card,
+				WaitUntil: waitUntil,
+			}
+
+		case action == "skip" && r.Method == http.MethodPost:
+			cardID, err := strconv.Atoi(r.FormValue("ID"))
+			if err != nil {
+				http.Error(w, "Invalid ID", http.StatusBadRequest)
+				return
+			}
+			if err := db.SkipSessionCard(data, sessionID, cardID); err != nil {
+				http.Error(w, "Error skipping card", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			response = struct {
+				Message string `json:"message"`
+			}{
+				Message: "Card skipped successfully",
+			}
+
+		case action == "close" && r.Method == http.MethodPost:
+			summary, err := db.CloseStudySession(data, sessionID, time.Now())
+			if err != nil {
+				http.Error(w, "Error closing study session", http.StatusInternalServerError)
+				log.Print(err)
+				return
+			}
+			response = summary
+
+		case action == "" || action == "next" || action == "skip" || action == "close":
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
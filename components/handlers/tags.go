@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"learn_go/db"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TagsHandler handles GET requests to /api/flashcard/tags, listing every tag
+// with the number of cards that have it
+func TagsHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		tags, err := db.GetTags(data)
+		if err != nil {
+			http.Error(w, "Error fetching tags", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tags); err != nil {
+			http.Error(w, "Error encoding tags", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// CardTagsHandler handles requests to /api/flashcard/cards/{id}/tags:
+//   - POST adds the tags in the body's tags list to the card
+//   - PUT replaces the card's tags with them
+//   - DELETE .../tags/{tag} takes a tag off the card
+//
+// Each responds with the card's tags.
+func CardTagsHandler(data *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		cardID, err := strconv.Atoi(parts[4])
+		if err != nil {
+			http.Error(w, "Invalid card ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := db.GetCardByID(data, cardID); errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Card not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Error fetching card", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		switch {
+		case len(parts) == 6 && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+			var body struct {
+				Tags []string `json:"tags"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if r.Method == http.MethodPost {
+				err = db.AddCardTags(data, cardID, body.Tags)
+			} else {
+				err = db.SetCardTags(data, cardID, body.Tags)
+			}
+
+		case len(parts) == 7 && r.Method == http.MethodDelete:
+			err = db.RemoveCardTag(data, cardID, parts[6])
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if errors.Is(err, db.ErrInvalidTag) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Error saving tags", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		card, err := db.GetCardByID(data, cardID)
+		if err != nil {
+			http.Error(w, "Error fetching card", http.StatusInternalServerError)
+			log.Print(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := struct {
+			Message string   `json:"message"`
+			Tags    []string `json:"tags"`
+		}{
+			Message: "Card tags updated successfully",
+			Tags:    card.Tags,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// tagFilterOption reads the ?tag= filters of a request, such as
+// ?tag=verbs AND NOT mastered. Repeated filters must all match. It returns
+// nil when there are none.
+func tagFilterOption(r *http.Request) (db.CardOption, error) {
+	filters := r.URL.Query()["tag"]
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	filter, err := db.ParseTagFilter(filters...)
+	if err != nil {
+		return nil, err
+	}
+	return db.WithTags(filter), nil
+}
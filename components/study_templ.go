@@ -31,7 +31,7 @@ func Study() templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"lg:w-2/3 mx-auto\"><div class=\"flex justify-center items-center h-screen bg-blue-100\"><div class=\"text-center\"><div id=\"flashcard-content\" class=\"bg-white rounded-md shadow-md h-64 w-96 flex items-center justify-center mb-4\" hx-get=\"/api/flashcard/cards/{deck_id}\" hx-trigger=\"load\" hx-target=\"#flashcard-content\"></div><button onclick=\"flipCard()\" class=\"bg-green-400 hover:bg-green-600 text-white px-4 py-2 rounded transition duration-300\">Flip Card</button><div class=\"mt-4\"><div class=\"flex justify-center items-center\"><label for=\"rating1\" class=\"mr-2\">1</label> <input type=\"radio\" id=\"rating1\" name=\"rating\" value=\"1\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating2\" class=\"mx-2\">2</label> <input type=\"radio\" id=\"rating2\" name=\"rating\" value=\"2\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating3\" class=\"mx-2\">3</label> <input type=\"radio\" id=\"rating3\" name=\"rating\" value=\"3\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating4\" class=\"mx-2\">4</label> <input type=\"radio\" id=\"rating4\" name=\"rating\" value=\"4\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating5\" class=\"ml-2\">5</label> <input type=\"radio\" id=\"rating5\" name=\"rating\" value=\"5\" class=\"form-radio h-5 w-5 text-green-600\"></div></div><div class=\"mt-5\"><button class=\"bg-blue-400 hover:bg-blue-600 text-white px-4 py-2 rounded transition duration-300\" hx-post=\"/api/flashcard/rate\" hx-trigger=\"click\" hx-swap=\"none\" id=\"submit-rating\">Submit Rating</button> <button class=\"bg-red-400 hover:bg-red-600 text-white px-4 py-2 rounded transition duration-300\" hx-get=\"/api/flashcard/cards/{deck_id}\" hx-trigger=\"click\" hx-target=\"#flashcard-content\" hx-vals=\"\">Skip Card</button></div></div></div></div><script>\n            var frontContent = '';\n            var backContent = '';\n            var showingFront = true;\n            var id;\n            \n            // Extract deck_id from the current URL\n            const currentUrl = window.location.href;\n            const deckIdMatch = currentUrl.match(/\\/decks\\/(\\d+)\\/study/);\n            const deckId = deckIdMatch ? deckIdMatch[1] : null; // Default to null if not found\n\n            if (deckId) {\n                // Update hx-get attributes with the extracted deck_id\n                const flashcardContent = document.getElementById('flashcard-content');\n                flashcardContent.setAttribute('hx-get', `/api/flashcard/cards/${deckId}`);\n                document.querySelector('.bg-red-400').setAttribute('hx-get', `/api/flashcard/cards/${deckId}`);\n            } else {\n                console.error('Deck ID not found in URL');\n                // Optionally, handle this error (e.g., show a message to the user)\n            }\n\n            document.addEventListener('htmx:afterRequest', function (event) {\n                if (event.detail.target.id === 'flashcard-content') {\n                    var data = event.detail.xhr.response;\n                    try {\n                        var json = JSON.parse(data);\n                        // Select a random card from the JSON array\n                        var randomIndex = Math.floor(Math.random() * json.length);\n                        frontContent = json[randomIndex].front;\n                        backContent = json[randomIndex].back;\n                        id = json[randomIndex].id;\n                        document.getElementById('flashcard-content').innerText = frontContent;\n                    } catch (e) {\n                        console.error('Error parsing JSON:', e);\n                    }\n                }\n            });\n\n            function flipCard() {\n                var cardContent = document.getElementById('flashcard-content');\n                cardContent.innerText = showingFront ? backContent : frontContent;\n                showingFront = !showingFront;\n            }\n\n            document.getElementById('submit-rating').addEventListener('click', function () {\n                var selectedRating = document.querySelector('input[name=\"rating\"]:checked').value;\n                this.setAttribute('hx-vals', JSON.stringify({ ID: id, Rating: selectedRating }));\n            });\n        </script></body>")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"lg:w-2/3 mx-auto\"><div class=\"flex justify-center items-center h-screen bg-blue-100\"><div class=\"text-center\"><div id=\"flashcard-content\" class=\"bg-white rounded-md shadow-md h-64 w-96 flex items-center justify-center mb-4\"></div><p id=\"session-progress\" class=\"mb-4 text-gray-700\"></p><button onclick=\"flipCard()\" class=\"bg-green-400 hover:bg-green-600 text-white px-4 py-2 rounded transition duration-300\">Flip Card</button><div class=\"mt-4\"><div class=\"flex justify-center items-center\"><label for=\"rating1\" class=\"mr-2\">1</label> <input type=\"radio\" id=\"rating1\" name=\"rating\" value=\"1\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating2\" class=\"mx-2\">2</label> <input type=\"radio\" id=\"rating2\" name=\"rating\" value=\"2\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating3\" class=\"mx-2\">3</label> <input type=\"radio\" id=\"rating3\" name=\"rating\" value=\"3\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating4\" class=\"mx-2\">4</label> <input type=\"radio\" id=\"rating4\" name=\"rating\" value=\"4\" class=\"form-radio h-5 w-5 text-green-600\"> <label for=\"rating5\" class=\"ml-2\">5</label> <input type=\"radio\" id=\"rating5\" name=\"rating\" value=\"5\" class=\"form-radio h-5 w-5 text-green-600\"></div></div><div class=\"mt-5\"><button class=\"bg-blue-400 hover:bg-blue-600 text-white px-4 py-2 rounded transition duration-300\" hx-post=\"/api/flashcard/rate\" hx-trigger=\"click\" hx-swap=\"none\" id=\"submit-rating\">Submit Rating</button> <button onclick=\"skipCard()\" class=\"bg-red-400 hover:bg-red-600 text-white px-4 py-2 rounded transition duration-300\">Skip Card</button> <button onclick=\"endSession()\" class=\"bg-gray-500 hover:bg-gray-700 text-white px-4 py-2 rounded transition duration-300\">End Session</button></div><p id=\"next-review\" class=\"mt-4 text-gray-700\"></p><p id=\"session-summary\" class=\"mt-2 text-gray-700\"></p></div></div></div><script>\n            var frontContent = '';\n            var backContent = '';\n            var showingFront = true;\n            var id;\n            var shownAt;\n            var sessionId;\n\n            // Extract deck_id from the current URL\n            const currentUrl = window.location.href;\n            const deckIdMatch = currentUrl.match(/\\/decks\\/(\\d+)\\/study/);\n            const deckId = deckIdMatch ? deckIdMatch[1] : null; // Default to null if not found\n\n            // The study session lives on the server; only its id is kept here so\n            // that a refresh picks up where it left off\n            const sessionKey = `study-session-${deckId}`;\n\n            if (deckId) {\n                resumeSession();\n            } else {\n                console.error('Deck ID not found in URL');\n                // Optionally, handle this error (e.g., show a message to the user)\n            }\n\n            document.addEventListener('htmx:afterRequest', function (event) {\n                if (event.detail.elt.id === 'submit-rating' && event.detail.successful) {\n                    // Show when the rated card will come up again\n                    var card = JSON.parse(event.detail.xhr.response).card;\n                    document.getElementById('next-review').innerText = nextReviewText(card);\n                    fetchNextCard();\n                }\n            });\n\n            // nextReviewText describes when a rated card is next due. Cards in a\n            // learning step have no interval yet, so their due time is used.\n            function nextReviewText(card) {\n                if (card.state === 'suspended') {\n                    return 'Card suspended';\n                }\n                if (card.interval > 0) {\n                    var days = card.interval === 1 ? 'day' : 'days';\n                    return `Next review in ${card.interval} ${days}`;\n                }\n                var due = new Date(card.due);\n                var minutes = Math.max(1, Math.round((due - Date.now()) / 60000));\n                if (minutes < 60) {\n                    return `Next review in ${minutes} ${minutes === 1 ? 'minute' : 'minutes'}`;\n                }\n                return `Next review at ${due.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' })}`;\n            }\n\n            function sessionUrl(path) {\n                return `/api/flashcard/decks/${deckId}/sessions/${sessionId}${path}`;\n            }\n\n            function resumeSession() {\n                sessionId = localStorage.getItem(sessionKey);\n                if (!sessionId) {\n                    startSession();\n                    return;\n                }\n                fetch(sessionUrl(''))\n                    .then(response => response.ok ? response.json() : null)\n                    .then(session => {\n                        if (session && !session.endedAt) {\n                            fetchNextCard();\n                        } else {\n                            startSession();\n                        }\n                    })\n                    .catch(error => console.error('Error resuming session:', error));\n            }\n\n            function startSession() {\n                fetch(`/api/flashcard/decks/${deckId}/sessions`, { method: 'POST' })\n                    .then(response => response.json())\n                    .then(data => {\n                        sessionId = data.session.id;\n                        localStorage.setItem(sessionKey, sessionId);\n                        document.getElementById('session-summary').innerText = '';\n                        fetchNextCard();\n                    })\n                    .catch(error => console.error('Error starting session:', error));\n            }\n\n            function fetchNextCard() {\n                fetch(sessionUrl('/next'))\n                    .then(response => response.json())\n                    .then(data => {\n                        document.getElementById('session-progress').innerText =\n                            `${data.session.total - data.session.remaining} / ${data.session.total} cards`;\n                        if (data.card) {\n                            showCard(data.card);\n                        } else if (data.waitUntil) {\n                            waitForCard(new Date(data.waitUntil));\n                        } else {\n                            endSession();\n                        }\n                    })\n                    .catch(error => console.error('Error fetching next card:', error));\n            }\n\n            function showCard(card) {\n                // The card's sides come rendered from Markdown and sanitised\n                frontContent = card.html.front;\n                backContent = card.html.back;\n                id = card.id;\n                showingFront = true;\n                shownAt = Date.now();\n                document.getElementById('flashcard-content').innerHTML = frontContent;\n            }\n\n            function waitForCard(dueAt) {\n                // Only cards in a learning step are left, wait until the first is due\n                id = undefined;\n                var wait = Math.max(0, dueAt - Date.now());\n                var minutes = Math.max(1, Math.round(wait / 60000));\n                document.getElementById('flashcard-content').innerText =\n                    `Next card in ${minutes} ${minutes === 1 ? 'minute' : 'minutes'}`;\n                setTimeout(fetchNextCard, wait + 500);\n            }\n\n            function skipCard() {\n                // Move the current card to the back of the session's queue\n                if (id === undefined) {\n                    return;\n                }\n                fetch(sessionUrl('/skip'), {\n                    method: 'POST',\n                    headers: { 'Content-Type': 'application/x-www-form-urlencoded' },\n                    body: new URLSearchParams({ ID: id }),\n                })\n                    .then(() => fetchNextCard())\n                    .catch(error => console.error('Error skipping card:', error));\n            }\n\n            function endSession() {\n                fetch(sessionUrl('/close'), { method: 'POST' })\n                    .then(response => response.json())\n                    .then(summary => {\n                        localStorage.removeItem(sessionKey);\n                        id = undefined;\n                        document.getElementById('flashcard-content').innerText = 'Session complete!';\n                        var minutes = Math.round(summary.timeSpentMs / 60000);\n                        var ratings = Object.entries(summary.ratings).map(([rating, count]) => `${rating}: ${count}`).join(', ');\n                        document.getElementById('session-summary').innerText =\n                            `Cards seen: ${summary.cardsSeen}, accuracy: ${Math.round(summary.accuracy * 100)}%, ` +\n                            `time spent: ${minutes} min` + (ratings ? `, ratings: ${ratings}` : '');\n                    })\n                    .catch(error => console.error('Error closing session:', error));\n            }\n\n            function flipCard() {\n                var cardContent = document.getElementById('flashcard-content');\n                cardContent.innerHTML = showingFront ? backContent : frontContent;\n                showingFront = !showingFront;\n            }\n\n            document.getElementById('submit-rating').addEventListener('click', function () {\n                var selectedRating = document.querySelector('input[name=\"rating\"]:checked').value;\n                this.setAttribute('hx-vals', JSON.stringify({ ID: id, Rating: selectedRating, SessionID: sessionId, Latency: Date.now() - shownAt }));\n            });\n        </script></body>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -0,0 +1,344 @@
+package db
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var ErrInvalidAPKG = errors.New("invalid .apkg file")
+
+// ankiModel is the part of an Anki note type needed to pick a card's front
+// and back out of its note's fields.
+type ankiModel struct {
+	Type   int `json:"type"` // 0 for standard note types, 1 for cloze
+	Fields []struct {
+		Name string `json:"name"`
+		Ord  int    `json:"ord"`
+	} `json:"flds"`
+	Templates []struct {
+		Ord   int    `json:"ord"`
+		Front string `json:"qfmt"`
+		Back  string `json:"afmt"`
+	} `json:"tmpls"`
+}
+
+const ankiClozeModel = 1
+
+// ImportAPKG imports the cards in an Anki package into decks of the same
+// name, keeping the review schedule of cards that have been studied in Anki.
+// Media in the package is not imported.
+func ImportAPKG(db *sql.DB, r io.ReaderAt, size int64, now time.Time, opts ...ImportOption) (*ImportReport, error) {
+	cards, skipped, err := readAPKG(r, size, now)
+	if err != nil {
+		return nil, err
+	}
+
+	report := ImportReport{Skipped: skipped}
+	if err := importCards(db, cards, &report, opts...); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// readAPKG reads the cards out of an Anki package, returning them along
+// with the number of cards that could not be converted.
+func readAPKG(r io.ReaderAt, size int64, now time.Time) ([]importedCard, int, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidAPKG, err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	// Newer packages keep a copy of the collection in the older format
+	// alongside the current one, so prefer whichever we can read.
+	collection := files["collection.anki21"]
+	if collection == nil {
+		collection = files["collection.anki2"]
+	}
+	if collection == nil {
+		if files["collection.anki21b"] != nil {
+			return nil, 0, fmt.Errorf("%w: re-export it with \"Support older Anki versions\" ticked", ErrInvalidAPKG)
+		}
+		return nil, 0, fmt.Errorf("%w: no collection found", ErrInvalidAPKG)
+	}
+
+	path, err := extractTemp(collection)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.Remove(path)
+
+	anki, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, 0, fmt.Errorf("error opening Anki collection: %w", err)
+	}
+	defer anki.Close()
+
+	return readAnkiCollection(anki, now)
+}
+
+func extractTemp(file *zip.File) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAPKG, err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "collection-*.anki2")
+	if err != nil {
+		return "", fmt.Errorf("error extracting Anki collection: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("error extracting Anki collection: %w", err)
+	}
+
+	return dst.Name(), nil
+}
+
+func readAnkiCollection(anki *sql.DB, now time.Time) ([]importedCard, int, error) {
+	var created int64
+	var decksJSON, modelsJSON string
+	err := anki.QueryRow("SELECT crt, decks, models FROM col").Scan(&created, &decksJSON, &modelsJSON)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidAPKG, err)
+	}
+
+	deckNames, err := readAnkiDecks(anki, decksJSON)
+	if err != nil {
+		return nil, 0, err
+	}
+	models := map[string]ankiModel{}
+	if modelsJSON != "" {
+		if err := json.Unmarshal([]byte(modelsJSON), &models); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidAPKG, err)
+		}
+	}
+
+	rows, err := anki.Query(`
+        SELECT c.did, c.ord, c.type, c.queue, c.due, c.ivl, c.factor, c.reps, c.lapses, n.mid, n.flds, n.tags
+        FROM cards c
+        JOIN notes n ON c.nid = n.id
+        ORDER BY c.id
+    `)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidAPKG, err)
+	}
+	defer rows.Close()
+
+	var cards []importedCard
+	skipped := 0
+
+	for rows.Next() {
+		var deckID, modelID string
+		var ord, cardType, queue, due, interval, factor, reps, lapses int
+		var fields, tags string
+		if err := rows.Scan(&deckID, &ord, &cardType, &queue, &due, &interval, &factor, &reps, &lapses, &modelID, &fields, &tags); err != nil {
+			return nil, 0, fmt.Errorf("error scanning Anki card: %v", err)
+		}
+
+		var model *ankiModel
+		if m, ok := models[modelID]; ok {
+			model = &m
+		}
+		front, back, ok := ankiFrontBack(model, ord, strings.Split(fields, "\x1f"))
+		if !ok {
+			skipped++
+			continue
+		}
+
+		deck, ok := deckNames[deckID]
+		if !ok {
+			deck = "Default"
+		}
+
+		card := Card{Front: front, Back: back}
+		card.MemoryState, card.Due = ankiSchedule(cardType, queue, due, interval, factor, reps, lapses, time.Unix(created, 0).In(now.Location()), now)
+		cards = append(cards, importedCard{Deck: deck, Card: card, Tags: strings.Fields(tags)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading Anki cards: %v", err)
+	}
+
+	return cards, skipped, nil
+}
+
+// readAnkiDecks maps Anki deck IDs to deck names. Older collections keep
+// decks as JSON in the col table, newer ones in a decks table with the
+// parts of nested deck names separated by \x1f.
+func readAnkiDecks(anki *sql.DB, decksJSON string) (map[string]string, error) {
+	names := map[string]string{}
+
+	var decks map[string]struct {
+		Name string `json:"name"`
+	}
+	if decksJSON != "" {
+		if err := json.Unmarshal([]byte(decksJSON), &decks); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAPKG, err)
+		}
+	}
+	for id, deck := range decks {
+		names[id] = deck.Name
+	}
+	if len(names) > 0 {
+		return names, nil
+	}
+
+	rows, err := anki.Query("SELECT id, name FROM decks")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAPKG, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("error scanning Anki deck: %v", err)
+		}
+		names[strconv.FormatInt(id, 10)] = strings.ReplaceAll(name, "\x1f", "::")
+	}
+
+	return names, nil
+}
+
+var ankiFieldRef = regexp.MustCompile(`\{\{([^#^/!}][^}]*)\}\}`)
+
+// ankiFrontBack works out the front and back of a card from its note's
+// fields, using the fields the card's template shows on each side. Without
+// a template the first two fields are used. Cloze cards are not supported.
+func ankiFrontBack(model *ankiModel, ord int, fields []string) (string, string, bool) {
+	frontField, backField := 0, 1
+
+	if model != nil {
+		if model.Type == ankiClozeModel {
+			return "", "", false
+		}
+
+		fieldOrds := map[string]int{}
+		for _, field := range model.Fields {
+			fieldOrds[field.Name] = field.Ord
+		}
+		for _, tmpl := range model.Templates {
+			if tmpl.Ord != ord {
+				continue
+			}
+			if refs := ankiFieldRefs(tmpl.Front, fieldOrds); len(refs) > 0 {
+				frontField = refs[0]
+			}
+			for _, ref := range ankiFieldRefs(tmpl.Back, fieldOrds) {
+				if ref != frontField {
+					backField = ref
+					break
+				}
+			}
+		}
+	}
+
+	if frontField >= len(fields) || backField >= len(fields) {
+		return "", "", false
+	}
+	front, back := ankiText(fields[frontField]), ankiText(fields[backField])
+	if front == "" || back == "" {
+		return "", "", false
+	}
+
+	return front, back, true
+}
+
+// ankiFieldRefs returns the ords of the note fields a template refers to,
+// in order. Special fields such as {{FrontSide}} are left out.
+func ankiFieldRefs(template string, fieldOrds map[string]int) []int {
+	var refs []int
+	for _, match := range ankiFieldRef.FindAllStringSubmatch(template, -1) {
+		name := strings.TrimSpace(match[1])
+		// Drop filters such as {{text:Back}} or {{type:Back}}
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		if ord, ok := fieldOrds[name]; ok {
+			refs = append(refs, ord)
+		}
+	}
+	return refs
+}
+
+var (
+	ankiLineBreak = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</p>`)
+	ankiTag       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ankiText turns the HTML of an Anki field into plain text.
+func ankiText(field string) string {
+	text := ankiLineBreak.ReplaceAllString(field, "\n")
+	text = ankiTag.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+// Anki card types, from cards.type.
+const (
+	ankiTypeNew        = 0
+	ankiTypeLearning   = 1
+	ankiTypeReview     = 2
+	ankiTypeRelearning = 3
+)
+
+const ankiQueueSuspended = -1
+
+// ankiSchedule converts an Anki card's schedule. Review cards keep their
+// interval, ease and lapses, and are due on the same day as in Anki; cards
+// still (re)learning are due now and start their steps again.
+func ankiSchedule(cardType, queue, due, interval, factor, reps, lapses int, created time.Time, now time.Time) (MemoryState, time.Time) {
+	state := MemoryState{EaseFactor: DefaultEaseFactor, State: CardStateNew, Lapses: lapses}
+	if factor > 0 {
+		state.EaseFactor = max(float64(factor)/1000, MinEaseFactor)
+	}
+	dueAt := now
+
+	switch cardType {
+	case ankiTypeLearning:
+		state.State = CardStateLearning
+	case ankiTypeRelearning:
+		state.State = CardStateRelearning
+	case ankiTypeReview:
+		state.State = CardStateReview
+		state.Interval = max(interval, 1)
+		state.Repetitions = reps
+		// At FSRS's default retention the interval is the card's stability
+		state.Stability = float64(state.Interval)
+		state.Difficulty = 5
+		// Review cards are due a number of days after the collection was created
+		dueAt = created.AddDate(0, 0, due)
+		lastReview := dueAt.AddDate(0, 0, -state.Interval)
+		state.LastReview = &lastReview
+	}
+	if cardType == ankiTypeLearning || cardType == ankiTypeRelearning {
+		lastReview := now
+		state.LastReview = &lastReview
+	}
+
+	if queue == ankiQueueSuspended {
+		state.State = CardStateSuspended
+	}
+
+	return state, dueAt
+}
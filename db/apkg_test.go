@@ -0,0 +1,271 @@
+package db
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAnkiModels = `{
+    "100": {"type": 0, "flds": [{"name": "Front", "ord": 0}, {"name": "Back", "ord": 1}],
+            "tmpls": [{"ord": 0, "qfmt": "{{Front}}", "afmt": "{{FrontSide}}<hr id=answer>{{Back}}"},
+                      {"ord": 1, "qfmt": "{{Back}}", "afmt": "{{FrontSide}}<hr id=answer>{{text:Front}}"}]},
+    "200": {"type": 1, "flds": [{"name": "Text", "ord": 0}],
+            "tmpls": [{"ord": 0, "qfmt": "{{cloze:Text}}", "afmt": "{{cloze:Text}}"}]}
+}`
+
+type testAnkiCard struct {
+	deck, model                                               int
+	ord, cardType, queue, due, interval, factor, reps, lapses int
+	fields, tags                                              string
+}
+
+// buildAPKG writes an Anki collection holding cards to a package, the way
+// Anki exports one.
+func buildAPKG(t *testing.T, created time.Time, cards ...testAnkiCard) []byte {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	anki, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("error creating collection: %v", err)
+	}
+	defer anki.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE col (crt INTEGER, decks TEXT, models TEXT)",
+		"CREATE TABLE notes (id INTEGER PRIMARY KEY, mid INTEGER, flds TEXT, tags TEXT)",
+		"CREATE TABLE cards (id INTEGER PRIMARY KEY, nid INTEGER, did INTEGER, ord INTEGER, type INTEGER, queue INTEGER, due INTEGER, ivl INTEGER, factor INTEGER, reps INTEGER, lapses INTEGER)",
+	} {
+		if _, err := anki.Exec(stmt); err != nil {
+			t.Fatalf("error creating collection: %v", err)
+		}
+	}
+	decks := `{"1": {"name": "Default"}, "2": {"name": "Spanish::Verbs"}}`
+	if _, err := anki.Exec("INSERT INTO col VALUES (?, ?, ?)", created.Unix(), decks, testAnkiModels); err != nil {
+		t.Fatalf("error creating collection: %v", err)
+	}
+	for i, card := range cards {
+		if _, err := anki.Exec("INSERT INTO notes VALUES (?, ?, ?, ?)", i+1, card.model, card.fields, card.tags); err != nil {
+			t.Fatalf("error adding note: %v", err)
+		}
+		if _, err := anki.Exec("INSERT INTO cards VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", i+1, i+1, card.deck,
+			card.ord, card.cardType, card.queue, card.due, card.interval, card.factor, card.reps, card.lapses); err != nil {
+			t.Fatalf("error adding card: %v", err)
+		}
+	}
+	anki.Close()
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading collection: %v", err)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	f, _ := archive.Create("collection.anki2")
+	f.Write(contents)
+	f, _ = archive.Create("media")
+	f.Write([]byte("{}"))
+	archive.Close()
+
+	return buf.Bytes()
+}
+
+func TestReadAPKG(t *testing.T) {
+	created := time.Date(2024, time.January, 1, 4, 0, 0, 0, time.UTC)
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("New cards", func(t *testing.T) {
+		pkg := buildAPKG(t, created,
+			testAnkiCard{deck: 2, model: 100, fields: "hablar\x1fto <b>speak</b>", tags: " verbs ar-verbs "},
+			testAnkiCard{deck: 2, model: 100, ord: 1, fields: "hablar\x1fto <b>speak</b>", tags: " verbs ar-verbs "},
+			testAnkiCard{deck: 9, model: 100, fields: "a &amp; b<br>c\x1f<div>d</div>"},
+		)
+
+		cards, skipped, err := readAPKG(bytes.NewReader(pkg), int64(len(pkg)), now)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, skipped)
+		assert.Len(t, cards, 3)
+
+		assert.Equal(t, "Spanish::Verbs", cards[0].Deck)
+		assert.Equal(t, "hablar", cards[0].Card.Front)
+		assert.Equal(t, "to speak", cards[0].Card.Back)
+		assert.Equal(t, CardStateNew, cards[0].Card.State)
+		assert.Equal(t, now, cards[0].Card.Due)
+		assert.Equal(t, []string{"verbs", "ar-verbs"}, cards[0].Tags)
+
+		// The reverse template swaps the fields
+		assert.Equal(t, "to speak", cards[1].Card.Front)
+		assert.Equal(t, "hablar", cards[1].Card.Back)
+
+		// Cards in a deck missing from the collection go in Default
+		assert.Equal(t, "Default", cards[2].Deck)
+		assert.Equal(t, "a & b\nc", cards[2].Card.Front)
+		assert.Equal(t, "d", cards[2].Card.Back)
+	})
+
+	t.Run("Review intervals are preserved", func(t *testing.T) {
+		pkg := buildAPKG(t, created,
+			testAnkiCard{deck: 1, model: 100, cardType: 2, queue: 2, due: 130, interval: 15, factor: 2300, reps: 6, lapses: 1, fields: "q\x1fa"},
+		)
+
+		cards, _, err := readAPKG(bytes.NewReader(pkg), int64(len(pkg)), now)
+		assert.NoError(t, err)
+		assert.Len(t, cards, 1)
+
+		card := cards[0].Card
+		assert.Equal(t, CardStateReview, card.State)
+		assert.Equal(t, 15, card.Interval)
+		assert.Equal(t, 2.3, card.EaseFactor)
+		assert.Equal(t, 6, card.Repetitions)
+		assert.Equal(t, 1, card.Lapses)
+		assert.Equal(t, 15.0, card.Stability)
+		assert.Equal(t, created.AddDate(0, 0, 130), card.Due)
+		assert.Equal(t, created.AddDate(0, 0, 115), *card.LastReview)
+	})
+
+	t.Run("Suspended and learning cards", func(t *testing.T) {
+		pkg := buildAPKG(t, created,
+			testAnkiCard{deck: 1, model: 100, cardType: 2, queue: -1, due: 130, interval: 15, factor: 2500, fields: "q\x1fa"},
+			testAnkiCard{deck: 1, model: 100, cardType: 3, queue: 1, due: 1714560000, factor: 2000, lapses: 2, fields: "q2\x1fa2"},
+		)
+
+		cards, _, err := readAPKG(bytes.NewReader(pkg), int64(len(pkg)), now)
+		assert.NoError(t, err)
+		assert.Len(t, cards, 2)
+
+		assert.Equal(t, CardStateSuspended, cards[0].Card.State)
+		assert.Equal(t, 15, cards[0].Card.Interval)
+
+		assert.Equal(t, CardStateRelearning, cards[1].Card.State)
+		assert.Equal(t, 0, cards[1].Card.Interval)
+		assert.Equal(t, 2, cards[1].Card.Lapses)
+		assert.Equal(t, now, cards[1].Card.Due)
+	})
+
+	t.Run("Cloze and empty cards are skipped", func(t *testing.T) {
+		pkg := buildAPKG(t, created,
+			testAnkiCard{deck: 1, model: 200, fields: "{{c1::Paris}} is the capital of France"},
+			testAnkiCard{deck: 1, model: 100, fields: "front\x1f<br>"},
+			testAnkiCard{deck: 1, model: 100, fields: "front\x1fback"},
+		)
+
+		cards, skipped, err := readAPKG(bytes.NewReader(pkg), int64(len(pkg)), now)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, skipped)
+		assert.Len(t, cards, 1)
+	})
+
+	t.Run("Not a package", func(t *testing.T) {
+		data := []byte("not a zip file")
+		_, _, err := readAPKG(bytes.NewReader(data), int64(len(data)), now)
+		assert.True(t, errors.Is(err, ErrInvalidAPKG))
+	})
+
+	t.Run("Collection in the new format only", func(t *testing.T) {
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		archive.Create("collection.anki21b")
+		archive.Close()
+
+		_, _, err := readAPKG(bytes.NewReader(buf.Bytes()), int64(buf.Len()), now)
+		assert.True(t, errors.Is(err, ErrInvalidAPKG))
+		assert.Contains(t, err.Error(), "Support older Anki versions")
+	})
+}
+
+func TestImportCards(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).
+			WillReturnRows(deckRows(Deck{ID: 1, Name: "Spanish", Scheduler: SchedulerSM2}))
+		mock.ExpectBegin()
+		expectDuplicate(mock, "hablar", 1, Card{ID: 5, Front: "Hablar", Back: "to speak", Due: due})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		expectDuplicate(mock, "comer", 1)
+		expectBuiltinNote(mock, NoteTypeBasic, 1)
+		mock.ExpectQuery("INSERT INTO cards").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectExec("INSERT INTO deck_cards").WithArgs(6, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("INSERT INTO decks").WithArgs("French").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectBegin()
+		expectDuplicate(mock, "parler", 2)
+		expectBuiltinNote(mock, NoteTypeBasic, 1)
+		mock.ExpectQuery("INSERT INTO cards").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec("INSERT INTO deck_cards").WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		report := ImportReport{}
+		err = importCards(db, []importedCard{
+			{Deck: "Spanish", Card: Card{Front: "hablar", Back: "to speak"}},
+			{Deck: "Spanish", Card: Card{Front: "comer", Back: "to eat"}},
+			{Deck: "French", Card: Card{Front: "parler", Back: "to speak"}},
+		}, &report)
+
+		assert.NoError(t, err)
+		assert.Equal(t, ImportReport{Imported: 2, Duplicates: 1, Decks: []string{"Spanish", "French"}}, report)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update duplicates in the collection", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		saved := Card{ID: 5, Type: CardTypeBasic, Front: "hablar", Back: "to talk", Due: due}
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).
+			WillReturnRows(deckRows(Deck{ID: 1, Name: "Spanish", Scheduler: SchedulerSM2}))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards WHERE card_front_key(front) = card_front_key($1) ORDER BY id")).
+			WithArgs("Hablar").WillReturnRows(cardRows(saved))
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(5).WillReturnRows(cardRows(saved))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE cards SET front = $1, back = $2 WHERE id = $3")).WithArgs("Hablar", "to speak", 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO deck_cards").WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		report := ImportReport{}
+		err = importCards(db, []importedCard{{Deck: "Spanish", Card: Card{Front: "Hablar", Back: "to speak"}}}, &report,
+			WithDuplicates(DuplicatePolicy{Scope: DuplicatesInCollection, OnDuplicate: OnDuplicateUpdate}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, ImportReport{Updated: 1, Decks: []string{"Spanish"}}, report)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error creating deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).
+			WillReturnRows(deckRows())
+		mock.ExpectQuery("INSERT INTO decks").WillReturnError(errors.New("insert error"))
+
+		report := ImportReport{}
+		err = importCards(db, []importedCard{{Deck: "French", Card: Card{Front: "parler", Back: "to speak"}}}, &report)
+
+		assert.EqualError(t, err, `error creating deck "French": insert error`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
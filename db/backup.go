@@ -0,0 +1,676 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BackupVersion is the version of the backup format written by WriteBackup.
+// Restore accepts backups of this version or older. Version 2 added notes.
+const BackupVersion = 2
+
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// How Restore treats the collection already in the database.
+const (
+	RestoreMerge   = "merge"   // add the backup to it, merging decks with the same path
+	RestoreReplace = "replace" // delete it, then restore the backup with its original IDs
+)
+
+// Backup is a copy of the whole collection: every deck with its settings,
+// every note with its note type, every card with its schedule and tags, and
+// the decks each card is in. Review history and study sessions are not
+// included.
+type Backup struct {
+	Version      int            `json:"version"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	Decks        []Deck         `json:"decks"`
+	DeckSettings []DeckSettings `json:"deckSettings"` // only decks whose settings were saved
+	NoteTypes    []NoteType     `json:"noteTypes"`
+	Notes        []Note         `json:"notes"`
+	Cards        []Card         `json:"cards"`
+	DeckCards    []DeckCard     `json:"deckCards"`
+}
+
+// DeckCard records that a card is in a deck.
+type DeckCard struct {
+	CardID int `json:"cardId"`
+	DeckID int `json:"deckId"`
+}
+
+const backupDeckSettingsColumns = "deck_id, new_per_day, reviews_per_day, learning_steps, graduating_interval, maximum_interval, relearning_steps, leech_threshold, leech_action"
+
+func scanDeckSettings(row rowScanner, settings *DeckSettings) error {
+	var steps, relearningSteps pq.Int64Array
+	err := row.Scan(&settings.DeckID, &settings.NewPerDay, &settings.ReviewsPerDay, &steps, &settings.GraduatingInterval,
+		&settings.MaximumInterval, &relearningSteps, &settings.LeechThreshold, &settings.LeechAction)
+	settings.LearningSteps = fromInt64Array(steps)
+	settings.RelearningSteps = fromInt64Array(relearningSteps)
+	return err
+}
+
+func scanDeckCard(row rowScanner, deckCard *DeckCard) error {
+	return row.Scan(&deckCard.CardID, &deckCard.DeckID)
+}
+
+// WriteBackup writes a Backup of the collection to w as JSON. Rows are
+// written as they are read rather than held in memory, from a single
+// snapshot of the database.
+func WriteBackup(db *sql.DB, w io.Writer, now time.Time) error {
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("error starting backup: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	createdAt, err := json.Marshal(now)
+	if err != nil {
+		return fmt.Errorf("error starting backup: %w", err)
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `{"version":%d,"createdAt":%s`, BackupVersion, createdAt)
+
+	if err := writeBackupSection(out, tx, "decks", "SELECT "+deckColumns+" FROM decks ORDER BY id", scanDeck); err != nil {
+		return err
+	}
+	if err := writeBackupSection(out, tx, "deckSettings", "SELECT "+backupDeckSettingsColumns+" FROM deck_settings ORDER BY deck_id", scanDeckSettings); err != nil {
+		return err
+	}
+	if err := writeBackupSection(out, tx, "noteTypes", "SELECT "+noteTypeColumns+" FROM note_types ORDER BY id", scanNoteType); err != nil {
+		return err
+	}
+	if err := writeBackupSection(out, tx, "notes", "SELECT "+noteColumns+" FROM notes ORDER BY id", scanNote); err != nil {
+		return err
+	}
+	if err := writeBackupSection(out, tx, "cards", "SELECT "+cardColumns+" FROM cards ORDER BY id", scanCard); err != nil {
+		return err
+	}
+	if err := writeBackupSection(out, tx, "deckCards", "SELECT card_id, deck_id FROM deck_cards ORDER BY card_id, deck_id", scanDeckCard); err != nil {
+		return err
+	}
+
+	out.WriteString("}\n")
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("error writing backup: %w", err)
+	}
+
+	return nil
+}
+
+// writeBackupSection writes the rows returned by query as a JSON array
+// named name.
+func writeBackupSection[T any](out *bufio.Writer, tx *sql.Tx, name string, query string, scan func(rowScanner, *T) error) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return fmt.Errorf("error backing up %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	fmt.Fprintf(out, `,%q:[`, name)
+	for i := 0; rows.Next(); i++ {
+		var item T
+		if err := scan(rows, &item); err != nil {
+			return fmt.Errorf("error backing up %s: %w", name, err)
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("error backing up %s: %w", name, err)
+		}
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.Write(data)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error backing up %s: %w", name, err)
+	}
+	out.WriteByte(']')
+
+	return nil
+}
+
+// ReadBackup reads a Backup written by WriteBackup.
+func ReadBackup(r io.Reader) (*Backup, error) {
+	var backup Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	backup.upgrade()
+	if err := backup.validate(); err != nil {
+		return nil, err
+	}
+
+	return &backup, nil
+}
+
+func (b *Backup) validate() error {
+	if b.Version < 1 || b.Version > BackupVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, b.Version)
+	}
+
+	decks := map[int]bool{}
+	parents := map[int]*int{}
+	for _, deck := range b.Decks {
+		decks[deck.ID] = true
+		parents[deck.ID] = deck.ParentID
+	}
+	for _, deck := range b.Decks {
+		if deck.ParentID != nil && !decks[*deck.ParentID] {
+			return fmt.Errorf("%w: deck %d has missing parent deck %d", ErrInvalidBackup, deck.ID, *deck.ParentID)
+		}
+		// Following the parents up from a deck must reach the top level
+		id := deck.ID
+		for depth := 0; parents[id] != nil; depth++ {
+			if depth == len(b.Decks) {
+				return fmt.Errorf("%w: deck %d is nested in itself", ErrInvalidBackup, deck.ID)
+			}
+			id = *parents[id]
+		}
+	}
+	noteTypes := map[int]bool{}
+	for _, noteType := range b.NoteTypes {
+		if err := noteType.Validate(); err != nil {
+			return fmt.Errorf("%w: note type %d: %v", ErrInvalidBackup, noteType.ID, err)
+		}
+		noteTypes[noteType.ID] = true
+	}
+	notes := map[int]bool{}
+	for _, note := range b.Notes {
+		if !noteTypes[note.NoteTypeID] {
+			return fmt.Errorf("%w: note %d has missing note type %d", ErrInvalidBackup, note.ID, note.NoteTypeID)
+		}
+		notes[note.ID] = true
+	}
+	cards := map[int]bool{}
+	for _, card := range b.Cards {
+		if card.NoteID != nil && !notes[*card.NoteID] {
+			return fmt.Errorf("%w: card %d has missing note %d", ErrInvalidBackup, card.ID, *card.NoteID)
+		}
+		for _, tag := range card.Tags {
+			if name, err := NormalizeTag(tag); err != nil || name != tag {
+				return fmt.Errorf("%w: card %d has invalid tag %q", ErrInvalidBackup, card.ID, tag)
+			}
+		}
+		cards[card.ID] = true
+	}
+
+	for _, settings := range b.DeckSettings {
+		if !decks[settings.DeckID] {
+			return fmt.Errorf("%w: settings for missing deck %d", ErrInvalidBackup, settings.DeckID)
+		}
+		if err := settings.Validate(); err != nil {
+			return fmt.Errorf("%w: deck %d: %v", ErrInvalidBackup, settings.DeckID, err)
+		}
+	}
+	for _, deckCard := range b.DeckCards {
+		if !decks[deckCard.DeckID] || !cards[deckCard.CardID] {
+			return fmt.Errorf("%w: card %d in deck %d is missing", ErrInvalidBackup, deckCard.CardID, deckCard.DeckID)
+		}
+	}
+
+	return nil
+}
+
+// upgrade brings a backup from before notes up to date, putting its cards
+// on notes of the built-in note types the way NotesTable does.
+func (b *Backup) upgrade() {
+	if b.Version != 1 {
+		return
+	}
+	b.Version = BackupVersion
+
+	b.NoteTypes = nil
+	noteTypeIDs := map[string]int{}
+	for i, noteType := range builtinNoteTypes {
+		noteType.ID = i + 1
+		b.NoteTypes = append(b.NoteTypes, noteType)
+		noteTypeIDs[noteType.Name] = noteType.ID
+	}
+
+	// Siblings shared a note ID, which was the ID of one of them
+	group := func(card Card) int {
+		if card.NoteID != nil {
+			return *card.NoteID
+		}
+		return card.ID
+	}
+	firstIDs, sizes := map[int]int{}, map[int]int{}
+	for _, card := range b.Cards {
+		key := group(card)
+		if first, ok := firstIDs[key]; !ok || card.ID < first {
+			firstIDs[key] = card.ID
+		}
+		sizes[key]++
+	}
+
+	b.Notes = nil
+	for i, card := range b.Cards {
+		key := group(card)
+		noteID := firstIDs[key]
+		b.Cards[i].NoteID = &noteID
+		b.Cards[i].Template = 0
+		if card.ID != noteID {
+			if card.Type != CardTypeCloze {
+				b.Cards[i].Template = 1
+			}
+			continue
+		}
+
+		note := Note{ID: noteID, NoteTypeID: noteTypeIDs[NoteTypeBasic], Fields: map[string]string{"Front": card.Front, "Back": card.Back}}
+		if card.Type == CardTypeCloze {
+			note.NoteTypeID, note.Fields = noteTypeIDs[NoteTypeCloze], map[string]string{"Text": card.Front, "Extra": card.Back}
+		} else if sizes[key] > 1 {
+			note.NoteTypeID = noteTypeIDs[NoteTypeReversed]
+		}
+		b.Notes = append(b.Notes, note)
+	}
+}
+
+// Restore loads a backup into the database in a single transaction. With
+// RestoreReplace the existing collection, including its review history, is
+// deleted first. With RestoreMerge the backup is added alongside it: decks
+// are matched by path and note types by name, and a card already in one of
+// its decks with the same front and back is counted as a duplicate rather
+// than added again.
+func Restore(db *sql.DB, backup *Backup, mode string) (*ImportReport, error) {
+	if mode != RestoreMerge && mode != RestoreReplace {
+		return nil, fmt.Errorf("%w: unknown restore mode %q", ErrInvalidBackup, mode)
+	}
+	backup.upgrade()
+	if err := backup.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting restore: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	report := ImportReport{}
+	if mode == RestoreReplace {
+		err = restoreReplace(tx, backup, &report)
+	} else {
+		err = restoreMerge(tx, backup, &report)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error restoring backup: %w", err)
+	}
+
+	return &report, nil
+}
+
+func restoreReplace(tx *sql.Tx, backup *Backup, report *ImportReport) error {
+	// Everything else in the collection cascades from these
+	for _, table := range []string{"cards", "decks", "notes", "note_types", "tags"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("error clearing %s: %w", table, err)
+		}
+	}
+
+	for _, deck := range backup.Decks {
+		createdAt, updatedAt := deckTimes(deck)
+		_, err := tx.Exec(`INSERT INTO decks (id, name, scheduler, description, color, icon, archived, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, NOW()), COALESCE($9, NOW()))`,
+			deck.ID, deck.Name, deck.Scheduler, deck.Description, deck.Color, deck.Icon, deck.Archived, createdAt, updatedAt)
+		if err != nil {
+			return fmt.Errorf("error restoring deck %d: %w", deck.ID, err)
+		}
+		report.Decks = append(report.Decks, deck.Name)
+	}
+	// Parents are set once every deck exists, as they can come after their
+	// children
+	for _, deck := range backup.Decks {
+		if deck.ParentID == nil {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE decks SET parent_id = $1 WHERE id = $2", *deck.ParentID, deck.ID); err != nil {
+			return fmt.Errorf("error restoring deck %d: %w", deck.ID, err)
+		}
+	}
+	for _, settings := range backup.DeckSettings {
+		if err := saveDeckSettings(tx, settings); err != nil {
+			return err
+		}
+	}
+	for _, noteType := range backup.NoteTypes {
+		if _, err := restoreNoteType(tx, noteType, true); err != nil {
+			return err
+		}
+	}
+	for _, note := range backup.Notes {
+		if _, err := restoreNote(tx, note, true); err != nil {
+			return err
+		}
+	}
+	for _, card := range backup.Cards {
+		if _, err := restoreCard(tx, card, true); err != nil {
+			return err
+		}
+		report.Imported++
+	}
+	for _, deckCard := range backup.DeckCards {
+		if err := restoreDeckCard(tx, deckCard); err != nil {
+			return err
+		}
+	}
+
+	// Carry on numbering after the restored rows
+	for _, table := range []string{"cards", "decks", "notes", "note_types"} {
+		_, err := tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %[1]s", table))
+		if err != nil {
+			return fmt.Errorf("error resetting %s IDs: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+func restoreMerge(tx *sql.Tx, backup *Backup, report *ImportReport) error {
+	var existing []Deck
+	rows, err := tx.Query("SELECT " + deckColumns + " FROM decks ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("error getting decks: %w", err)
+	}
+	for rows.Next() {
+		var deck Deck
+		if err := scanDeck(rows, &deck); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning deck: %w", err)
+		}
+		existing = append(existing, deck)
+	}
+	rows.Close()
+
+	// Decks are matched by their path, so that decks with the same name
+	// nested in different decks are kept apart
+	existingDecks := map[string]int{}
+	existingPaths := deckPaths(existing)
+	for _, deck := range existing {
+		if _, ok := existingDecks[existingPaths[deck.ID]]; !ok {
+			existingDecks[existingPaths[deck.ID]] = deck.ID
+		}
+	}
+	backupDecks := map[int]Deck{}
+	for _, deck := range backup.Decks {
+		backupDecks[deck.ID] = deck
+	}
+	backupPaths := deckPaths(backup.Decks)
+
+	// Backup deck IDs to the IDs of the decks they were merged into
+	deckIDs := map[int]int{}
+	newDecks := map[int]bool{}
+	var mergeDeck func(deck Deck) (int, error)
+	mergeDeck = func(deck Deck) (int, error) {
+		if id, ok := deckIDs[deck.ID]; ok {
+			return id, nil
+		}
+		path := backupPaths[deck.ID]
+		id, ok := existingDecks[path]
+		if !ok {
+			// validate made sure parents exist and decks are not nested in
+			// themselves
+			var parentID *int
+			if deck.ParentID != nil {
+				id, err := mergeDeck(backupDecks[*deck.ParentID])
+				if err != nil {
+					return 0, err
+				}
+				parentID = &id
+			}
+			createdAt, updatedAt := deckTimes(deck)
+			err := tx.QueryRow(`INSERT INTO decks (name, scheduler, parent_id, description, color, icon, archived, created_at, updated_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, NOW()), COALESCE($9, NOW())) RETURNING id`,
+				deck.Name, deck.Scheduler, parentID, deck.Description, deck.Color, deck.Icon, deck.Archived, createdAt, updatedAt).Scan(&id)
+			if err != nil {
+				return 0, fmt.Errorf("error restoring deck %q: %w", path, err)
+			}
+			existingDecks[path] = id
+			newDecks[deck.ID] = true
+		}
+		deckIDs[deck.ID] = id
+		report.Decks = append(report.Decks, path)
+		return id, nil
+	}
+	for _, deck := range backup.Decks {
+		if _, err := mergeDeck(deck); err != nil {
+			return err
+		}
+	}
+
+	// Decks that already existed keep their own settings
+	for _, settings := range backup.DeckSettings {
+		if !newDecks[settings.DeckID] {
+			continue
+		}
+		settings.DeckID = deckIDs[settings.DeckID]
+		if err := saveDeckSettings(tx, settings); err != nil {
+			return err
+		}
+	}
+
+	// Cloze siblings share their text, so are told apart by cloze number
+	type content struct {
+		front, back string
+		cloze       int
+	}
+	deckContents := map[int]map[content]int{}
+	rows, err = tx.Query("SELECT dc.deck_id, c.id, c.front, c.back, c.cloze_number FROM cards c JOIN deck_cards dc ON c.id = dc.card_id")
+	if err != nil {
+		return fmt.Errorf("error getting cards: %w", err)
+	}
+	for rows.Next() {
+		var deckID, cardID int
+		var key content
+		if err := rows.Scan(&deckID, &cardID, &key.front, &key.back, &key.cloze); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning card: %w", err)
+		}
+		if deckContents[deckID] == nil {
+			deckContents[deckID] = map[content]int{}
+		}
+		deckContents[deckID][key] = cardID
+	}
+	rows.Close()
+
+	cardDecks := map[int][]int{}
+	for _, deckCard := range backup.DeckCards {
+		cardDecks[deckCard.CardID] = append(cardDecks[deckCard.CardID], deckIDs[deckCard.DeckID])
+	}
+
+	noteTypeIDs, err := mergeNoteTypes(tx, backup.NoteTypes)
+	if err != nil {
+		return err
+	}
+	notes := map[int]Note{}
+	for _, note := range backup.Notes {
+		notes[note.ID] = note
+	}
+
+	// Backup card and note IDs to the IDs they were restored as. A note is
+	// only restored along with one of its cards.
+	cardIDs := map[int]int{}
+	noteIDs := map[int]int{}
+	for _, card := range backup.Cards {
+		existingID, duplicate := 0, false
+		for _, deckID := range cardDecks[card.ID] {
+			if existingID, duplicate = deckContents[deckID][content{card.Front, card.Back, card.Cloze}]; duplicate {
+				break
+			}
+		}
+		if duplicate {
+			cardIDs[card.ID] = existingID
+			report.Duplicates++
+			continue
+		}
+
+		if card.NoteID != nil {
+			noteID, ok := noteIDs[*card.NoteID]
+			if !ok {
+				note := notes[*card.NoteID]
+				note.NoteTypeID = noteTypeIDs[note.NoteTypeID]
+				if noteID, err = restoreNote(tx, note, false); err != nil {
+					return err
+				}
+				noteIDs[*card.NoteID] = noteID
+			}
+			card.NoteID = &noteID
+		}
+
+		id, err := restoreCard(tx, card, false)
+		if err != nil {
+			return err
+		}
+		cardIDs[card.ID] = id
+		report.Imported++
+	}
+
+	for _, deckCard := range backup.DeckCards {
+		err := restoreDeckCard(tx, DeckCard{CardID: cardIDs[deckCard.CardID], DeckID: deckIDs[deckCard.DeckID]})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deckTimes returns when a backed up deck was created and updated, or nil
+// for backups from before decks recorded it, so that they default to now.
+func deckTimes(deck Deck) (*time.Time, *time.Time) {
+	var createdAt, updatedAt *time.Time
+	if !deck.CreatedAt.IsZero() {
+		createdAt = &deck.CreatedAt
+	}
+	if !deck.UpdatedAt.IsZero() {
+		updatedAt = &deck.UpdatedAt
+	}
+	return createdAt, updatedAt
+}
+
+// mergeNoteTypes matches note types from a backup to those in the database
+// by name, adding the ones that are missing. It returns a map from backup
+// note type IDs to database ones.
+func mergeNoteTypes(tx *sql.Tx, noteTypes []NoteType) (map[int]int, error) {
+	existing := map[string]int{}
+	rows, err := tx.Query("SELECT id, name FROM note_types")
+	if err != nil {
+		return nil, fmt.Errorf("error getting note types: %w", err)
+	}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning note type: %w", err)
+		}
+		existing[name] = id
+	}
+	rows.Close()
+
+	ids := map[int]int{}
+	for _, noteType := range noteTypes {
+		id, ok := existing[noteType.Name]
+		if !ok {
+			if id, err = restoreNoteType(tx, noteType, false); err != nil {
+				return nil, err
+			}
+		}
+		ids[noteType.ID] = id
+	}
+
+	return ids, nil
+}
+
+// restoreRow inserts a row from a backup into table, with the ID in front
+// of the other columns if keepID is set, and returns the row's ID.
+func restoreRow(tx *sql.Tx, table string, columns string, id int, keepID bool, args ...any) (int, error) {
+	if keepID {
+		columns = "id, " + columns
+		args = append([]any{id}, args...)
+	}
+	placeholders := make([]string, len(args))
+	for i := range args {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	err := tx.QueryRow("INSERT INTO "+table+" ("+columns+") VALUES ("+strings.Join(placeholders, ", ")+") RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
+func restoreNoteType(tx *sql.Tx, noteType NoteType, keepID bool) (int, error) {
+	templates, err := json.Marshal(noteType.Templates)
+	if err != nil {
+		return 0, fmt.Errorf("error restoring note type %d: %v", noteType.ID, err)
+	}
+
+	id, err := restoreRow(tx, "note_types", "name, card_type, fields, templates", noteType.ID, keepID,
+		noteType.Name, noteType.CardType, pq.StringArray(noteType.Fields), templates)
+	if err != nil {
+		return 0, fmt.Errorf("error restoring note type %d: %w", noteType.ID, err)
+	}
+
+	return id, nil
+}
+
+func restoreNote(tx *sql.Tx, note Note, keepID bool) (int, error) {
+	fields, err := json.Marshal(note.Fields)
+	if err != nil {
+		return 0, fmt.Errorf("error restoring note %d: %v", note.ID, err)
+	}
+
+	id, err := restoreRow(tx, "notes", "note_type_id, fields", note.ID, keepID, note.NoteTypeID, fields)
+	if err != nil {
+		return 0, fmt.Errorf("error restoring note %d: %w", note.ID, err)
+	}
+
+	return id, nil
+}
+
+// restoreCardColumns lists the cards columns restoreCard fills.
+const restoreCardColumns = "type, front, back, cloze_number, ease_factor, interval_days, repetitions, stability, difficulty, last_review, learning_step, state, lapses, leech, buried_until, note_id, template, due"
+
+// restoreCard inserts a card from a backup, keeping its ID if keepID is
+// set, and returns the card's ID.
+func restoreCard(tx *sql.Tx, card Card, keepID bool) (int, error) {
+	if card.State == "" {
+		card.State = CardStateNew
+	}
+	if card.Type == "" {
+		card.Type = CardTypeBasic
+	}
+
+	id, err := restoreRow(tx, "cards", restoreCardColumns, card.ID, keepID,
+		card.Type, card.Front, card.Back, card.Cloze, card.EaseFactor, card.Interval, card.Repetitions, card.Stability, card.Difficulty,
+		card.LastReview, card.Step, card.State, card.Lapses, card.Leech, card.BuriedUntil, card.NoteID, card.Template, card.Due)
+	if err != nil {
+		return 0, fmt.Errorf("error restoring card %d: %w", card.ID, err)
+	}
+	if err := addCardTags(tx, id, card.Tags); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func restoreDeckCard(tx *sql.Tx, deckCard DeckCard) error {
+	_, err := tx.Exec("INSERT INTO deck_cards (card_id, deck_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", deckCard.CardID, deckCard.DeckID)
+	if err != nil {
+		return fmt.Errorf("error adding card %d to deck %d: %w", deckCard.CardID, deckCard.DeckID, err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBackup holds two decks, the first with saved settings, and three
+// cards on Basic notes, the first of them in both decks.
+func testBackup() *Backup {
+	settings := DefaultDeckSettings(1)
+	settings.NewPerDay = 5
+	basic := builtinNoteTypes[0]
+	basic.ID = 1
+	noteIDs := []int{20, 21, 22}
+	spanish := 1
+	return &Backup{
+		Version:      BackupVersion,
+		CreatedAt:    due,
+		Decks:        []Deck{{ID: 1, Name: "Spanish", Scheduler: SchedulerFSRS, Color: "#c60b1e", CreatedAt: due, UpdatedAt: due}, {ID: 2, Name: "Verbs", Scheduler: SchedulerSM2, ParentID: &spanish}},
+		DeckSettings: []DeckSettings{settings},
+		NoteTypes:    []NoteType{basic},
+		Notes: []Note{
+			{ID: 20, NoteTypeID: 1, Fields: map[string]string{"Front": "hablar", "Back": "to speak"}},
+			{ID: 21, NoteTypeID: 1, Fields: map[string]string{"Front": "comer", "Back": "to eat"}},
+			{ID: 22, NoteTypeID: 1, Fields: map[string]string{"Front": "vivir", "Back": "to live"}},
+		},
+		Cards: []Card{
+			{ID: 10, Type: CardTypeBasic, Front: "hablar", Back: "to speak", MemoryState: MemoryState{EaseFactor: 2.5, Interval: 4, State: CardStateReview}, NoteID: &noteIDs[0], Due: due, Tags: []string{"ar-verbs"}},
+			{ID: 11, Type: CardTypeBasic, Front: "comer", Back: "to eat", MemoryState: MemoryState{EaseFactor: 2.5, State: CardStateNew}, NoteID: &noteIDs[1], Due: due},
+			{ID: 12, Type: CardTypeBasic, Front: "vivir", Back: "to live", MemoryState: MemoryState{EaseFactor: 2.5, State: CardStateNew}, NoteID: &noteIDs[2], Due: due},
+		},
+		DeckCards: []DeckCard{{CardID: 10, DeckID: 1}, {CardID: 10, DeckID: 2}, {CardID: 11, DeckID: 1}, {CardID: 12, DeckID: 2}},
+	}
+}
+
+func TestWriteBackup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	backup := testBackup()
+	settings := backup.DeckSettings[0]
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks ORDER BY id")).
+		WillReturnRows(deckRows(testBackup().Decks...))
+	mock.ExpectQuery("SELECT deck_id, new_per_day.* FROM deck_settings").
+		WillReturnRows(sqlmock.NewRows(append([]string{"deck_id"}, deckSettingsColumns...)).
+			AddRow(1, settings.NewPerDay, settings.ReviewsPerDay, "{1,10}", settings.GraduatingInterval, settings.MaximumInterval,
+				"{10}", settings.LeechThreshold, settings.LeechAction))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + noteTypeColumns + " FROM note_types ORDER BY id")).
+		WillReturnRows(noteTypeRows(backup.NoteTypes...))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + noteColumns + " FROM notes ORDER BY id")).
+		WillReturnRows(noteRows(backup.Notes...))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards ORDER BY id")).
+		WillReturnRows(cardRows(backup.Cards...))
+	mock.ExpectQuery("SELECT card_id, deck_id FROM deck_cards").
+		WillReturnRows(sqlmock.NewRows([]string{"card_id", "deck_id"}).AddRow(10, 1).AddRow(10, 2).AddRow(11, 1).AddRow(12, 2))
+	mock.ExpectRollback()
+
+	var buf bytes.Buffer
+	err = WriteBackup(db, &buf, due)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The backup reads back as what was written
+	read, err := ReadBackup(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, BackupVersion, read.Version)
+	assert.True(t, due.Equal(read.CreatedAt))
+	assert.Equal(t, backup.Decks, read.Decks)
+	assert.Equal(t, backup.DeckSettings, read.DeckSettings)
+	assert.Equal(t, backup.NoteTypes, read.NoteTypes)
+	assert.Equal(t, backup.Notes, read.Notes)
+	assert.Equal(t, backup.DeckCards, read.DeckCards)
+	assert.Len(t, read.Cards, 3)
+	assert.Equal(t, backup.Cards[0].MemoryState, read.Cards[0].MemoryState)
+	assert.Equal(t, backup.Cards[0].NoteID, read.Cards[0].NoteID)
+}
+
+func TestReadBackup(t *testing.T) {
+	t.Run("Unsupported version", func(t *testing.T) {
+		_, err := ReadBackup(strings.NewReader(`{"version": 99}`))
+		assert.ErrorIs(t, err, ErrInvalidBackup)
+		assert.EqualError(t, err, "invalid backup: unsupported version 99")
+	})
+
+	t.Run("Card in a missing deck", func(t *testing.T) {
+		_, err := ReadBackup(strings.NewReader(`{"version": 2, "cards": [{"id": 1}], "deckCards": [{"cardId": 1, "deckId": 3}]}`))
+		assert.ErrorIs(t, err, ErrInvalidBackup)
+	})
+
+	t.Run("Deck in a missing deck", func(t *testing.T) {
+		_, err := ReadBackup(strings.NewReader(`{"version": 2, "decks": [{"id": 1, "name": "Go", "parentId": 3}]}`))
+		assert.ErrorIs(t, err, ErrInvalidBackup)
+	})
+
+	t.Run("Deck nested in itself", func(t *testing.T) {
+		_, err := ReadBackup(strings.NewReader(`{"version": 2, "decks": [{"id": 1, "name": "Go", "parentId": 2}, {"id": 2, "name": "Concurrency", "parentId": 1}]}`))
+		assert.ErrorIs(t, err, ErrInvalidBackup)
+	})
+
+	t.Run("Card on a missing note", func(t *testing.T) {
+		_, err := ReadBackup(strings.NewReader(`{"version": 2, "cards": [{"id": 1, "noteId": 4}]}`))
+		assert.ErrorIs(t, err, ErrInvalidBackup)
+	})
+
+	t.Run("Version 1 cards are put on notes", func(t *testing.T) {
+		// Card 2 is the reverse of card 1, and cards 3 and 4 are cloze siblings
+		backup, err := ReadBackup(strings.NewReader(`{"version": 1, "cards": [
+			{"id": 1, "type": "basic", "front": "hablar", "back": "to speak", "noteId": 1},
+			{"id": 2, "type": "basic", "front": "to speak", "back": "hablar", "noteId": 1},
+			{"id": 3, "type": "cloze", "front": "{{c1::a}} {{c2::b}}", "back": "", "cloze": 1, "noteId": 3},
+			{"id": 4, "type": "cloze", "front": "{{c1::a}} {{c2::b}}", "back": "", "cloze": 2, "noteId": 3},
+			{"id": 5, "front": "comer", "back": "to eat"}
+		]}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, BackupVersion, backup.Version)
+		assert.Len(t, backup.NoteTypes, len(builtinNoteTypes))
+		assert.Equal(t, []Note{
+			{ID: 1, NoteTypeID: 2, Fields: map[string]string{"Front": "hablar", "Back": "to speak"}},
+			{ID: 3, NoteTypeID: 3, Fields: map[string]string{"Text": "{{c1::a}} {{c2::b}}", "Extra": ""}},
+			{ID: 5, NoteTypeID: 1, Fields: map[string]string{"Front": "comer", "Back": "to eat"}},
+		}, backup.Notes)
+		for i, want := range []struct{ note, template int }{{1, 0}, {1, 1}, {3, 0}, {3, 0}, {5, 0}} {
+			assert.Equal(t, want.note, *backup.Cards[i].NoteID)
+			assert.Equal(t, want.template, backup.Cards[i].Template)
+		}
+	})
+
+	t.Run("Not JSON", func(t *testing.T) {
+		_, err := ReadBackup(strings.NewReader("front,back"))
+		assert.ErrorIs(t, err, ErrInvalidBackup)
+	})
+}
+
+func TestRestore(t *testing.T) {
+	t.Run("Replace", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM cards").WillReturnResult(sqlmock.NewResult(0, 8))
+		mock.ExpectExec("DELETE FROM decks").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM notes").WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("DELETE FROM note_types").WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("DELETE FROM tags").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO decks \\(id, name, scheduler, description").WithArgs(1, "Spanish", SchedulerFSRS, "", "#c60b1e", "", false, due, due).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		// Verbs has no timestamps, as if from an older backup
+		mock.ExpectExec("INSERT INTO decks \\(id, name, scheduler, description").WithArgs(2, "Verbs", SchedulerSM2, "", "", "", false, nil, nil).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE decks SET parent_id = $1 WHERE id = $2")).WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO deck_settings").WithArgs(1, 5, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO note_types \\(id, name").WithArgs(1, NoteTypeBasic, CardTypeBasic, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		for _, id := range []int{20, 21, 22} {
+			mock.ExpectQuery("INSERT INTO notes \\(id, note_type_id").WithArgs(id, 1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+		}
+		for _, id := range []int{10, 11, 12} {
+			mock.ExpectQuery("INSERT INTO cards \\(id, type, front").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+			if id == 10 {
+				mock.ExpectExec("INSERT INTO card_tags").WithArgs("ar-verbs", 10).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+		}
+		for _, dc := range testBackup().DeckCards {
+			mock.ExpectExec("INSERT INTO deck_cards").WithArgs(dc.CardID, dc.DeckID).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectExec(regexp.QuoteMeta("SELECT setval(pg_get_serial_sequence('cards', 'id')")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("SELECT setval(pg_get_serial_sequence('decks', 'id')")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("SELECT setval(pg_get_serial_sequence('notes', 'id')")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("SELECT setval(pg_get_serial_sequence('note_types', 'id')")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		report, err := Restore(db, testBackup(), RestoreReplace)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportReport{Imported: 3, Decks: []string{"Spanish", "Verbs"}}, *report)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		// Spanish already exists as deck 7 and holds hablar as card 70, and a
+		// Verbs deck that is not nested in it is left alone
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).
+			WillReturnRows(deckRows(Deck{ID: 7, Name: "Spanish", Scheduler: SchedulerSM2}, Deck{ID: 9, Name: "Verbs", Scheduler: SchedulerSM2}))
+		mock.ExpectQuery("INSERT INTO decks \\(name, scheduler, parent_id, description").WithArgs("Verbs", SchedulerSM2, 7, "", "", "", false, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectQuery("SELECT dc.deck_id, c.id, c.front, c.back, c.cloze_number").
+			WillReturnRows(sqlmock.NewRows([]string{"deck_id", "id", "front", "back", "cloze_number"}).AddRow(7, 70, "hablar", "to speak", 0))
+		// Basic already exists as note type 5
+		mock.ExpectQuery("SELECT id, name FROM note_types").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, NoteTypeBasic))
+		mock.ExpectQuery("INSERT INTO notes \\(note_type_id, fields\\)").WithArgs(5, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(81))
+		mock.ExpectQuery("INSERT INTO cards \\(type, front").WithArgs(CardTypeBasic, "comer", "to eat", 0, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), 81, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(71))
+		mock.ExpectQuery("INSERT INTO notes \\(note_type_id, fields\\)").WithArgs(5, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(82))
+		mock.ExpectQuery("INSERT INTO cards \\(type, front").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(72))
+		for _, dc := range []DeckCard{{70, 7}, {70, 8}, {71, 7}, {72, 8}} {
+			mock.ExpectExec("INSERT INTO deck_cards").WithArgs(dc.CardID, dc.DeckID).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		report, err := Restore(db, testBackup(), RestoreMerge)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportReport{Imported: 2, Duplicates: 1, Decks: []string{"Spanish", "Spanish::Verbs"}}, *report)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown mode", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		_, err = Restore(db, testBackup(), "overwrite")
+		assert.ErrorIs(t, err, ErrInvalidBackup)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// The operations a card batch can hold.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// MaxBatchSize is the most operations a card batch can hold.
+const MaxBatchSize = 500
+
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// A BatchOp is one operation in a card batch. Creating a card takes the deck
+// to add it to and the card's content, as CardHandler does, and rejects
+// cards that duplicate a card in the deck unless the DuplicatePolicy says
+// otherwise. Updating a card takes its ID and content, with the stored
+// card's type unless one is given, and deleting one only its ID.
+type BatchOp struct {
+	Op      string `json:"op"` // BatchCreate, BatchUpdate or BatchDelete
+	ID      int    `json:"id"`
+	DeckID  int    `json:"deckId"`
+	Type    string `json:"type"` // CardTypeBasic (the default) or CardTypeCloze
+	Front   string `json:"front"`
+	Back    string `json:"back"`
+	Reverse bool   `json:"reverse"` // also create a reverse card
+	DuplicatePolicy
+}
+
+// BatchResult is what a BatchOp did: the IDs of the cards it created, one
+// per cloze number or with a reverse card, or of the card it updated or
+// deleted. A created card that duplicated a saved card gives that card's
+// ID as DuplicateOf, along with it in IDs if it was updated.
+type BatchResult struct {
+	Op          string `json:"op"`
+	IDs         []int  `json:"ids"`
+	DuplicateOf int    `json:"duplicateOf,omitempty"`
+}
+
+// Validate reports whether an operation could be run, without looking at
+// the database.
+func (op BatchOp) Validate() error {
+	switch op.Op {
+	case BatchCreate, BatchUpdate:
+		if op.Op == BatchCreate && op.DeckID <= 0 {
+			return fmt.Errorf("%w: a deck is required", ErrInvalidBatch)
+		}
+		if op.Op == BatchUpdate && op.ID <= 0 {
+			return fmt.Errorf("%w: a card ID is required", ErrInvalidBatch)
+		}
+		if op.Type != "" && op.Type != CardTypeBasic && op.Type != CardTypeCloze {
+			return fmt.Errorf("%w: unknown card type %q", ErrInvalidBatch, op.Type)
+		}
+		// A cloze card's back is optional extra text. An update without a
+		// type takes the stored card's, so its back is checked in runBatchOp
+		backOptional := op.Type == CardTypeCloze || (op.Op == BatchUpdate && op.Type == "")
+		if op.Front == "" || (op.Back == "" && !backOptional) {
+			return fmt.Errorf("%w: front and back content cannot be empty", ErrInvalidBatch)
+		}
+		if op.Type == CardTypeCloze && len(ClozeNumbers(op.Front)) == 0 {
+			return fmt.Errorf("%w: text has no cloze deletions", ErrInvalidCloze)
+		}
+		if err := op.DuplicatePolicy.Validate(); err != nil {
+			return err
+		}
+	case BatchDelete:
+		if op.ID <= 0 {
+			return fmt.Errorf("%w: a card ID is required", ErrInvalidBatch)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidBatch, op.Op)
+	}
+	return nil
+}
+
+// RunCardBatch runs card operations in order in a single transaction, and
+// returns a result for each. If any operation fails, none of them are kept,
+// and the error names the index of the one that failed.
+func RunCardBatch(db *sql.DB, ops []BatchOp) ([]BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: no operations", ErrInvalidBatch)
+	}
+	if len(ops) > MaxBatchSize {
+		return nil, fmt.Errorf("%w: more than %d operations", ErrInvalidBatch, MaxBatchSize)
+	}
+	for i, op := range ops {
+		if err := op.Validate(); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error running batch: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	results := make([]BatchResult, 0, len(ops))
+	for i, op := range ops {
+		result, err := runBatchOp(tx, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error running batch: %w", err)
+	}
+
+	return results, nil
+}
+
+func runBatchOp(tx *sql.Tx, op BatchOp) (BatchResult, error) {
+	result := BatchResult{Op: op.Op}
+	switch op.Op {
+	case BatchCreate:
+		if err := checkDeckExists(tx, op.DeckID); err != nil {
+			return result, err
+		}
+
+		if op.OnDuplicate != OnDuplicateAllow {
+			duplicate, err := findDuplicate(tx, op.Front, op.DeckID, op.Scope)
+			if err != nil {
+				return result, err
+			}
+			if duplicate != nil {
+				result.DuplicateOf = duplicate.ID
+				switch op.OnDuplicate {
+				case OnDuplicateSkip:
+					return result, nil
+				case OnDuplicateUpdate:
+					err := updateDuplicate(tx, *duplicate, Card{Front: op.Front, Back: op.Back}, op.DeckID)
+					result.IDs = []int{duplicate.ID}
+					return result, err
+				default:
+					return result, &DuplicateCardError{Card: *duplicate}
+				}
+			}
+		}
+
+		var card Card
+		var err error
+		if op.Type == CardTypeCloze {
+			card, err = CreateClozeCard(0, op.Front, op.Back)
+		} else {
+			card, err = CreateCard(0, op.Front, op.Back)
+		}
+		if err != nil {
+			return result, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+		}
+
+		var ids []int
+		switch {
+		case card.Type == CardTypeCloze:
+			ids, err = insertClozeCards(tx, card)
+		case op.Reverse:
+			ids, err = insertCardWithReverse(tx, card)
+		default:
+			var id int
+			id, err = insertBasicCard(tx, card)
+			ids = []int{id}
+		}
+		if err != nil {
+			return result, fmt.Errorf("error inserting card: %w", err)
+		}
+
+		for _, id := range ids {
+			if err := addCardToDeck(tx, id, op.DeckID); err != nil {
+				return result, err
+			}
+		}
+		result.IDs = ids
+	case BatchUpdate:
+		if op.Type == "" {
+			saved, err := GetCardByID(tx, op.ID)
+			if err != nil {
+				return result, err
+			}
+			op.Type = saved.Type
+			if err := op.Validate(); err != nil {
+				return result, err
+			}
+		}
+		if err := updateCard(tx, Card{ID: op.ID, Type: op.Type, Front: op.Front, Back: op.Back}); err != nil {
+			return result, err
+		}
+		result.IDs = []int{op.ID}
+	default:
+		// A card that doesn't exist fails the batch, as for updates
+		if err := deleteCardByID(tx, op.ID); err != nil {
+			return result, fmt.Errorf("error deleting card %d: %w", op.ID, err)
+		}
+		result.IDs = []int{op.ID}
+	}
+	return result, nil
+}
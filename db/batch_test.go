@@ -0,0 +1,290 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchOpValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		op   BatchOp
+		err  error
+	}{
+		{"Create", BatchOp{Op: BatchCreate, DeckID: 1, Front: "hablar", Back: "to speak"}, nil},
+		{"Create cloze without extra", BatchOp{Op: BatchCreate, DeckID: 1, Type: CardTypeCloze, Front: "{{c1::hablar}} es to speak"}, nil},
+		{"Create without deck", BatchOp{Op: BatchCreate, Front: "hablar", Back: "to speak"}, ErrInvalidBatch},
+		{"Create without back", BatchOp{Op: BatchCreate, DeckID: 1, Front: "hablar"}, ErrInvalidBatch},
+		{"Create cloze without deletions", BatchOp{Op: BatchCreate, DeckID: 1, Type: CardTypeCloze, Front: "hablar"}, ErrInvalidCloze},
+		{"Unknown card type", BatchOp{Op: BatchCreate, DeckID: 1, Type: "image", Front: "hablar", Back: "to speak"}, ErrInvalidBatch},
+		{"Update", BatchOp{Op: BatchUpdate, ID: 7, Front: "hablar", Back: "to speak"}, nil},
+		{"Update without ID", BatchOp{Op: BatchUpdate, Front: "hablar", Back: "to speak"}, ErrInvalidBatch},
+		{"Update without type or back", BatchOp{Op: BatchUpdate, ID: 7, Front: "{{c1::hablar}} es to speak"}, nil},
+		{"Update basic card without back", BatchOp{Op: BatchUpdate, ID: 7, Type: CardTypeBasic, Front: "hablar"}, ErrInvalidBatch},
+		{"Delete", BatchOp{Op: BatchDelete, ID: 7}, nil},
+		{"Delete without ID", BatchOp{Op: BatchDelete}, ErrInvalidBatch},
+		{"Unknown operation", BatchOp{Op: "archive", ID: 7}, ErrInvalidBatch},
+		{"Unknown duplicate action", BatchOp{Op: BatchCreate, DeckID: 1, Front: "hablar", Back: "to speak", DuplicatePolicy: DuplicatePolicy{OnDuplicate: "merge"}}, ErrInvalidDuplicates},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.op.Validate()
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRunCardBatch(t *testing.T) {
+	exists := regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1)")
+	ops := []BatchOp{
+		{Op: BatchCreate, DeckID: 1, Front: "hablar", Back: "to speak"},
+		{Op: BatchUpdate, ID: 7, Front: "comer", Back: "to eat"},
+		{Op: BatchDelete, ID: 8},
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(exists).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		expectDuplicate(mock, "hablar", 1)
+		expectBuiltinNote(mock, NoteTypeBasic, 3)
+		mock.ExpectQuery("INSERT INTO cards").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectExec("INSERT INTO deck_cards").WithArgs(9, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		// The update has no type, so it takes the stored card's
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(7).
+			WillReturnRows(cardRows(Card{ID: 7, Type: CardTypeBasic, Front: "comer", Back: "to have lunch", Due: due}))
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(7).
+			WillReturnRows(cardRows(Card{ID: 7, Type: CardTypeBasic, Front: "comer", Back: "to have lunch", Due: due}))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE cards SET front = $1, back = $2 WHERE id = $3")).WithArgs("comer", "to eat", 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM cards WHERE id = \\$1").WithArgs(8).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		results, err := RunCardBatch(db, ops)
+		assert.NoError(t, err)
+		assert.Equal(t, []BatchResult{{Op: BatchCreate, IDs: []int{9}}, {Op: BatchUpdate, IDs: []int{7}}, {Op: BatchDelete, IDs: []int{8}}}, results)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Failure rolls back", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(exists).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		expectDuplicate(mock, "hablar", 1)
+		expectBuiltinNote(mock, NoteTypeBasic, 3)
+		mock.ExpectQuery("INSERT INTO cards").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectExec("INSERT INTO deck_cards").WithArgs(9, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(7).WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err = RunCardBatch(db, ops)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.ErrorContains(t, err, "operation 1")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(exists).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		_, err = RunCardBatch(db, ops)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update takes the stored card's type", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		cloze := Card{ID: 7, Type: CardTypeCloze, Front: "{{c1::comer}} es to eat", Cloze: 1, Due: due}
+		basic := Card{ID: 8, Type: CardTypeBasic, Front: "hablar", Back: "to speak", Due: due}
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(7).WillReturnRows(cardRows(cloze))
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(7).WillReturnRows(cardRows(cloze))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE cards SET front = $1, back = $2 WHERE id = $3")).WithArgs("{{c1::comer}} es to have lunch", "", 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(8).WillReturnRows(cardRows(basic))
+		mock.ExpectRollback()
+
+		// The cloze card's back may be empty, the basic card's may not
+		_, err = RunCardBatch(db, []BatchOp{
+			{Op: BatchUpdate, ID: 7, Front: "{{c1::comer}} es to have lunch"},
+			{Op: BatchUpdate, ID: 8, Front: "hablar"},
+		})
+		assert.ErrorIs(t, err, ErrInvalidBatch)
+		assert.ErrorContains(t, err, "operation 1")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing card to delete", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM cards WHERE id = \\$1").WithArgs(8).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err = RunCardBatch(db, []BatchOp{{Op: BatchDelete, ID: 8}})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.ErrorContains(t, err, "operation 0")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		saved := Card{ID: 5, Type: CardTypeBasic, Front: "Hablar.", Back: "to talk", Tags: []string{}, Due: due}
+		create := BatchOp{Op: BatchCreate, DeckID: 1, Front: "hablar", Back: "to speak"}
+
+		tests := []struct {
+			name        string
+			onDuplicate string
+			expect      func(mock sqlmock.Sqlmock)
+			result      BatchResult
+		}{
+			{"Skip", OnDuplicateSkip, func(mock sqlmock.Sqlmock) {}, BatchResult{Op: BatchCreate, DuplicateOf: 5}},
+			{"Update", OnDuplicateUpdate, func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(5).WillReturnRows(cardRows(saved))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE cards SET front = $1, back = $2 WHERE id = $3")).WithArgs("hablar", "to speak", 5).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO deck_cards").WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+			}, BatchResult{Op: BatchCreate, IDs: []int{5}, DuplicateOf: 5}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatalf("error creating mock database: %v", err)
+				}
+				defer db.Close()
+
+				mock.ExpectBegin()
+				mock.ExpectQuery(exists).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+				expectDuplicate(mock, "hablar", 1, saved)
+				tt.expect(mock)
+				mock.ExpectCommit()
+
+				op := create
+				op.OnDuplicate = tt.onDuplicate
+				results, err := RunCardBatch(db, []BatchOp{op})
+				assert.NoError(t, err)
+				assert.Equal(t, []BatchResult{tt.result}, results)
+				assert.NoError(t, mock.ExpectationsWereMet())
+			})
+		}
+
+		t.Run("Reject", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error creating mock database: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(exists).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			expectDuplicate(mock, "hablar", 1, saved)
+			mock.ExpectRollback()
+
+			_, err = RunCardBatch(db, []BatchOp{create})
+			var duplicate *DuplicateCardError
+			assert.ErrorAs(t, err, &duplicate)
+			assert.Equal(t, saved, duplicate.Card)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Allow", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error creating mock database: %v", err)
+			}
+			defer db.Close()
+
+			// Duplicates are not looked for at all
+			mock.ExpectBegin()
+			mock.ExpectQuery(exists).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			expectBuiltinNote(mock, NoteTypeBasic, 3)
+			mock.ExpectQuery("INSERT INTO cards").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+			mock.ExpectExec("INSERT INTO deck_cards").WithArgs(9, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			op := create
+			op.OnDuplicate = OnDuplicateAllow
+			results, err := RunCardBatch(db, []BatchOp{op})
+			assert.NoError(t, err)
+			assert.Equal(t, []BatchResult{{Op: BatchCreate, IDs: []int{9}}}, results)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("Invalid operation", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		// Nothing is run when any operation is invalid
+		_, err = RunCardBatch(db, append(ops, BatchOp{Op: BatchDelete}))
+		assert.ErrorIs(t, err, ErrInvalidBatch)
+		assert.ErrorContains(t, err, "operation 3")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Too many operations", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		_, err = RunCardBatch(db, make([]BatchOp, MaxBatchSize+1))
+		assert.ErrorIs(t, err, ErrInvalidBatch)
+		_, err = RunCardBatch(db, nil)
+		assert.ErrorIs(t, err, ErrInvalidBatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin().WillReturnError(fmt.Errorf("connection lost"))
+
+		_, err = RunCardBatch(db, ops)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	CardTypeBasic = "basic"
+	CardTypeCloze = "cloze"
+)
+
+var ErrInvalidCloze = errors.New("invalid cloze card")
+
+// A cloze card's front holds text with deletions such as {{c1::answer}}, or
+// {{c1::answer::hint}} to show a hint in place of the answer, and its back
+// holds optional extra text. Each cloze number in the text is studied as a
+// card of its own, and the cards for one text are siblings.
+
+var clozePattern = regexp.MustCompile(`(?s)\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+
+// ClozeNumbers returns the cloze numbers used in text, in order.
+func ClozeNumbers(text string) []int {
+	var numbers []int
+	for _, match := range clozePattern.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n <= 0 || slices.Contains(numbers, n) {
+			continue
+		}
+		numbers = append(numbers, n)
+	}
+	slices.Sort(numbers)
+	return numbers
+}
+
+// RenderCloze renders cloze text for studying cloze number n. The front has
+// the deletions numbered n replaced by [...], or by their hint, and the back
+// has every deletion revealed.
+func RenderCloze(text string, n int) (string, string) {
+	front := clozePattern.ReplaceAllStringFunc(text, func(deletion string) string {
+		match := clozePattern.FindStringSubmatch(deletion)
+		if number, _ := strconv.Atoi(match[1]); number != n {
+			return match[2]
+		}
+		if match[3] != "" {
+			return "[" + match[3] + "]"
+		}
+		return "[...]"
+	})
+	back := clozePattern.ReplaceAllString(text, "$2")
+	return front, back
+}
+
+// Rendered returns the card as it is studied, with its sides also rendered
+// to HTML. A cloze card's deletion is hidden on its front and revealed on
+// its back, followed by any extra text. Other cards keep their sides as
+// they are.
+func (c Card) Rendered() Card {
+	if c.Type == CardTypeCloze {
+		front, back := RenderCloze(c.Front, c.Cloze)
+		if c.Back != "" {
+			back += "\n\n" + c.Back
+		}
+		c.Front, c.Back = front, back
+	}
+
+	c.HTML = &CardHTML{Front: RenderMarkdown(c.Front), Back: RenderMarkdown(c.Back)}
+	return c
+}
+
+// CreateClozeCard builds a new, unreviewed cloze card for text, with extra
+// shown on the back. Its Cloze is left for InsertClozeCards to fill in.
+func CreateClozeCard(id int, text string, extra string) (Card, error) {
+	if id < 0 || len(ClozeNumbers(text)) == 0 {
+		return Card{}, fmt.Errorf("%w: text has no cloze deletions", ErrInvalidCloze)
+	}
+
+	return Card{
+		ID:          id,
+		Type:        CardTypeCloze,
+		Front:       text,
+		Back:        extra,
+		MemoryState: MemoryState{EaseFactor: DefaultEaseFactor, State: CardStateNew},
+		Due:         time.Now(),
+	}, nil
+}
+
+// InsertClozeCards inserts a card for each cloze number in a cloze card's
+// text, as siblings on a Cloze note. It returns their IDs.
+func InsertClozeCards(db *sql.DB, card Card) ([]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error inserting cloze cards: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	ids, err := insertClozeCards(tx, card)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error inserting cloze cards: %w", err)
+	}
+
+	return ids, nil
+}
+
+func insertClozeCards(tx *sql.Tx, card Card) ([]int, error) {
+	numbers := ClozeNumbers(card.Front)
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("%w: text has no cloze deletions", ErrInvalidCloze)
+	}
+
+	noteID, err := insertBuiltinNote(tx, NoteTypeCloze, map[string]string{"Text": card.Front, "Extra": card.Back})
+	if err != nil {
+		return nil, err
+	}
+
+	card.Type, card.NoteID, card.Template = CardTypeCloze, &noteID, 0
+	var ids []int
+	for _, n := range numbers {
+		card.Cloze = n
+		id, err := insertCard(tx, card)
+		if err != nil {
+			return nil, fmt.Errorf("error inserting cloze card %d: %w", n, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const testClozeText = "{{c2::Madrid}} is the capital of {{c1::Spain}}, on the {{c2::Manzanares::river}}"
+
+func TestClozeNumbers(t *testing.T) {
+	assert.Equal(t, []int{1, 2}, ClozeNumbers(testClozeText))
+	assert.Equal(t, []int{3}, ClozeNumbers("{{c3::a\nmultiline answer}}"))
+	assert.Empty(t, ClozeNumbers("No deletions, {{c0::or a zero}} and {c1::half} ones"))
+}
+
+func TestRenderCloze(t *testing.T) {
+	t.Run("Hides the numbered deletion", func(t *testing.T) {
+		front, back := RenderCloze(testClozeText, 1)
+		assert.Equal(t, "Madrid is the capital of [...], on the Manzanares", front)
+		assert.Equal(t, "Madrid is the capital of Spain, on the Manzanares", back)
+	})
+
+	t.Run("Shows hints", func(t *testing.T) {
+		front, back := RenderCloze(testClozeText, 2)
+		assert.Equal(t, "[...] is the capital of Spain, on the [river]", front)
+		assert.Equal(t, "Madrid is the capital of Spain, on the Manzanares", back)
+	})
+}
+
+func TestCardRendered(t *testing.T) {
+	t.Run("Cloze card", func(t *testing.T) {
+		card := Card{ID: 3, Type: CardTypeCloze, Front: testClozeText, Back: "Since 1561", Cloze: 1}
+
+		rendered := card.Rendered()
+		assert.Equal(t, "Madrid is the capital of [...], on the Manzanares", rendered.Front)
+		assert.Equal(t, "Madrid is the capital of Spain, on the Manzanares\n\nSince 1561", rendered.Back)
+		assert.Equal(t, "<p>Madrid is the capital of [...], on the Manzanares</p>\n", rendered.HTML.Front)
+		assert.Equal(t, 3, rendered.ID)
+		assert.Equal(t, testClozeText, card.Front) // the card itself is left alone
+	})
+
+	t.Run("Basic card", func(t *testing.T) {
+		card := Card{ID: 3, Type: CardTypeBasic, Front: "{{c1::hablar}}", Back: "to speak"}
+
+		rendered := card.Rendered()
+		assert.Equal(t, card.Front, rendered.Front)
+		assert.Equal(t, card.Back, rendered.Back)
+		assert.Equal(t, &CardHTML{Front: "<p>{{c1::hablar}}</p>\n", Back: "<p>to speak</p>\n"}, rendered.HTML)
+	})
+}
+
+func TestCreateClozeCard(t *testing.T) {
+	card, err := CreateClozeCard(0, testClozeText, "")
+	assert.NoError(t, err)
+	assert.Equal(t, CardTypeCloze, card.Type)
+	assert.Equal(t, CardStateNew, card.State)
+
+	_, err = CreateClozeCard(0, "Madrid is the capital of Spain", "")
+	assert.ErrorIs(t, err, ErrInvalidCloze)
+}
+
+func TestInsertClozeCards(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	card := Card{Front: testClozeText, MemoryState: MemoryState{EaseFactor: 2.5, State: CardStateNew}, Due: due}
+
+	mock.ExpectBegin()
+	expectBuiltinNote(mock, NoteTypeCloze, 30)
+	mock.ExpectQuery("INSERT INTO cards").
+		WithArgs(CardTypeCloze, testClozeText, "", 1, 2.5, 0, 0, 0.0, 0.0, nil, 0, CardStateNew, 0, false, 30, 0, due).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("INSERT INTO cards").
+		WithArgs(CardTypeCloze, testClozeText, "", 2, 2.5, 0, 0, 0.0, 0.0, nil, 0, CardStateNew, 0, false, 30, 0, due).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectCommit()
+
+	ids, err := InsertClozeCards(db, card)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{7, 8}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateClozeCard(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	// Card 7 asks for c1 and card 8 for c2; the edit adds c3
+	text := testClozeText + " in {{c3::Europe}}"
+	noteID := 30
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards WHERE id = $1")).WithArgs(7).
+		WillReturnRows(cardRows(Card{ID: 7, Type: CardTypeCloze, Front: testClozeText, Cloze: 1, NoteID: &noteID, Due: due}))
+	mock.ExpectQuery("SELECT .* FROM notes WHERE id = \\$1").WithArgs(30).
+		WillReturnRows(noteRows(Note{ID: 30, NoteTypeID: 3, Fields: map[string]string{"Text": testClozeText, "Extra": ""}}))
+	cloze := builtinNoteTypes[2]
+	cloze.ID = 3
+	mock.ExpectQuery("SELECT .* FROM note_types WHERE id = \\$1").WithArgs(3).WillReturnRows(noteTypeRows(cloze))
+	mock.ExpectExec("UPDATE notes SET fields").WithArgs(sqlmock.AnyArg(), 30).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, template, cloze_number FROM cards WHERE note_id = \\$1").WithArgs(30).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "template", "cloze_number"}).AddRow(7, 0, 1).AddRow(8, 0, 2))
+	for _, id := range []int{7, 8} {
+		mock.ExpectExec("UPDATE cards SET front = \\$1, back = \\$2 WHERE id = \\$3").WithArgs(text, "", id).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery("INSERT INTO cards").
+		WithArgs(CardTypeCloze, text, "", 3, 2.5, 0, 0, 0.0, 0.0, nil, 0, CardStateNew, 0, false, 30, 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("INSERT INTO deck_cards").WithArgs(9, 30).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = UpdateCard(db, Card{ID: 7, Front: text})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
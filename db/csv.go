@@ -0,0 +1,183 @@
+package db
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrInvalidCSV = errors.New("invalid CSV")
+
+// The fields a CSV file of cards can hold.
+const (
+	CSVFront = "front"
+	CSVBack  = "back"
+	CSVTags  = "tags"
+)
+
+// CSVOptions describes the layout of a CSV or TSV file of cards.
+type CSVOptions struct {
+	Delimiter rune // defaults to a comma
+	// Header says the first row names the columns. Without a header the
+	// columns are front, back and tags, in that order.
+	Header bool
+	// Columns maps the fields to the header names holding them, for files
+	// whose headers are not simply front, back and tags.
+	Columns map[string]string
+	// NoQuotes treats quotes as ordinary text, as most TSV files expect.
+	NoQuotes bool
+}
+
+// ImportCSV adds the cards in a CSV or TSV file to a deck. Rows missing a
+// front or back are skipped, as are cards that duplicate one in the deck unless
+// WithDuplicates says otherwise.
+func ImportCSV(db *sql.DB, deckID int, r io.Reader, opts CSVOptions, importOpts ...ImportOption) (*ImportReport, error) {
+	deck, err := GetDeckByID(db, deckID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := readCSVRecords(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := ImportReport{}
+	if len(records) == 0 {
+		return &report, nil
+	}
+
+	columns := map[string]int{CSVFront: 0, CSVBack: 1, CSVTags: 2}
+	if opts.Header {
+		columns, err = csvColumns(records[0], opts.Columns)
+		if err != nil {
+			return nil, err
+		}
+		records = records[1:]
+	}
+
+	var cards []importedCard
+	for _, record := range records {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		front, back := field(CSVFront), field(CSVBack)
+		if front == "" || back == "" {
+			report.Skipped++
+			continue
+		}
+		cards = append(cards, importedCard{
+			DeckID: deck.ID,
+			Card:   Card{Front: front, Back: back},
+			Tags:   strings.Fields(field(CSVTags)),
+		})
+	}
+
+	if err := importCards(db, cards, &report, importOpts...); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// ExportCSV writes the cards in a deck as CSV, with a header row, separated
+// by delimiter, or by commas if delimiter is 0.
+func ExportCSV(db *sql.DB, deckID int, w io.Writer, delimiter rune) error {
+	cards, err := GetCardsFromDeck(db, deckID)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	if delimiter != 0 {
+		out.Comma = delimiter
+	}
+
+	if err := out.Write([]string{CSVFront, CSVBack, CSVTags}); err != nil {
+		return fmt.Errorf("error exporting deck %d: %v", deckID, err)
+	}
+	for _, card := range *cards {
+		if err := out.Write([]string{card.Front, card.Back, strings.Join(card.Tags, " ")}); err != nil {
+			return fmt.Errorf("error exporting deck %d: %v", deckID, err)
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return fmt.Errorf("error exporting deck %d: %v", deckID, err)
+	}
+
+	return nil
+}
+
+func readCSVRecords(r io.Reader, opts CSVOptions) ([][]string, error) {
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		delimiter = ','
+	}
+
+	var records [][]string
+	if opts.NoQuotes {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			line := strings.TrimSuffix(scanner.Text(), "\r")
+			if line == "" {
+				continue
+			}
+			records = append(records, strings.Split(line, string(delimiter)))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+		}
+	} else {
+		reader := csv.NewReader(r)
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+		var err error
+		records, err = reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+		}
+	}
+
+	// Spreadsheets often start their exports with a byte order mark
+	if len(records) > 0 && len(records[0]) > 0 {
+		records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+	}
+
+	return records, nil
+}
+
+// csvColumns finds the index of each field's column in a header row.
+// Header names are matched without regard to case.
+func csvColumns(header []string, names map[string]string) (map[string]int, error) {
+	columns := map[string]int{}
+	for _, field := range []string{CSVFront, CSVBack, CSVTags} {
+		name := field
+		if mapped, ok := names[field]; ok && mapped != "" {
+			name = mapped
+		}
+		for i, column := range header {
+			if strings.EqualFold(strings.TrimSpace(column), name) {
+				columns[field] = i
+				break
+			}
+		}
+	}
+
+	for _, field := range []string{CSVFront, CSVBack} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("%w: no %s column", ErrInvalidCSV, field)
+		}
+	}
+
+	return columns, nil
+}
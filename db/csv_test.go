@@ -0,0 +1,141 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectCSVDeck mocks looking up deck 1 for an import.
+func expectCSVDeck(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks WHERE id = $1")).WithArgs(1).
+		WillReturnRows(deckRows(Deck{ID: 1, Name: "Spanish", Scheduler: SchedulerSM2}))
+}
+
+func TestImportCSV(t *testing.T) {
+	t.Run("Header mapping", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		expectCSVDeck(mock)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).
+			WillReturnRows(deckRows(Deck{ID: 1, Name: "Spanish", Scheduler: SchedulerSM2}))
+		mock.ExpectBegin()
+		expectDuplicate(mock, "hablar", 1, Card{ID: 5, Front: "hablar", Back: "to speak", Due: due})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		expectDuplicate(mock, "comer", 1)
+		expectBuiltinNote(mock, NoteTypeBasic, 1)
+		mock.ExpectQuery("INSERT INTO cards").
+			WithArgs(CardTypeBasic, "comer", "to eat, to have lunch", 0, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectExec("INSERT INTO deck_cards").WithArgs(6, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		file := "\ufeffNotes,Spanish,English\n" +
+			"verb,hablar,to speak\n" +
+			"verb,comer,\"to eat, to have lunch\"\n" +
+			"noun,,\n"
+		report, err := ImportCSV(db, 1, strings.NewReader(file), CSVOptions{
+			Header:  true,
+			Columns: map[string]string{CSVFront: "spanish", CSVBack: "english"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, ImportReport{Imported: 1, Skipped: 1, Duplicates: 1, Decks: []string{"Spanish"}}, *report)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TSV without quotes or header", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		expectCSVDeck(mock)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).
+			WillReturnRows(deckRows(Deck{ID: 1, Name: "Spanish", Scheduler: SchedulerSM2}))
+		mock.ExpectBegin()
+		expectDuplicate(mock, `"hola"`, 1)
+		expectBuiltinNote(mock, NoteTypeBasic, 1)
+		mock.ExpectQuery("INSERT INTO cards").
+			WithArgs(CardTypeBasic, `"hola"`, "hello", 0, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectExec("INSERT INTO deck_cards").WithArgs(6, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO card_tags").WithArgs("greeting", 6).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		report, err := ImportCSV(db, 1, strings.NewReader("\"hola\"\thello\tgreeting\r\n"), CSVOptions{
+			Delimiter: '\t',
+			NoQuotes:  true,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Imported)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing column", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		expectCSVDeck(mock)
+
+		report, err := ImportCSV(db, 1, strings.NewReader("front,answer\nhablar,to speak\n"), CSVOptions{Header: true})
+
+		assert.Nil(t, report)
+		assert.ErrorIs(t, err, ErrInvalidCSV)
+		assert.EqualError(t, err, "invalid CSV: no back column")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Deck not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks WHERE id = $1")).WithArgs(1).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err = ImportCSV(db, 1, strings.NewReader("front,back\n"), CSVOptions{Header: true})
+
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestExportCSV(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT .* FROM cards c JOIN deck_cards dc").WithArgs(1).WillReturnRows(cardRows(
+		Card{ID: 5, Front: "hablar", Back: "to speak", Due: due, Tags: []string{"ar-verbs", "irregular"}},
+		Card{ID: 6, Front: "comer", Back: "to eat, to have lunch", Due: due},
+	))
+
+	var buf bytes.Buffer
+	err = ExportCSV(db, 1, &buf, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "front,back,tags\nhablar,to speak,ar-verbs irregular\ncomer,\"to eat, to have lunch\",\n", buf.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,22 +4,39 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"math/rand"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Card struct {
-	ID         int    `json:"id"`
-	Front      string `json:"front"`
-	Back       string `json:"back"`
-	Reviewed   int64  `json:"reviewed"`
-	Difficulty int    `json:"difficulty"`
+	ID    int    `json:"id"`
+	Type  string `json:"type"` // CardTypeBasic or CardTypeCloze
+	Front string `json:"front"`
+	Back  string `json:"back"`
+	Cloze int    `json:"cloze"` // the cloze number a cloze card asks for
+	MemoryState
+	Leech       bool       `json:"leech"`       // failed too often, see DeckSettings.LeechThreshold
+	BuriedUntil *time.Time `json:"buriedUntil"` // hidden from study until then
+	NoteID      *int       `json:"noteId"`      // the note the card is generated from, shared by its siblings
+	Template    int        `json:"template"`    // the note type template the card is generated from
+	Tags        []string   `json:"tags"`
+	Due         time.Time  `json:"due"`
+	HTML        *CardHTML  `json:"html,omitempty"` // Front and Back rendered from Markdown, not stored
 }
 
 type Deck struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Scheduler   string    `json:"scheduler"`
+	ParentID    *int      `json:"parentId"` // the deck this one is nested in, nil at the top level
+	Description string    `json:"description"`
+	Color       string    `json:"color"`    // #rrggbb, or empty for the default
+	Icon        string    `json:"icon"`     // a short label such as an emoji
+	Archived    bool      `json:"archived"` // hidden from the deck list unless asked for
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Children    []Deck    `json:"children,omitempty"` // only filled in by GetDecksData
 }
 
 type Option func(*dbOptions)
@@ -33,15 +50,51 @@ type TableSchema struct {
 	CreateSQL string
 }
 
+// CardsTable also defines card_front_key, which normalises a card's front to
+// find duplicates: it is compared in NFKC form and lowercase, without
+// punctuation, and with runs of whitespace as single spaces.
 var CardsTable = TableSchema{
 	Name: "cards",
 	CreateSQL: `CREATE TABLE IF NOT EXISTS cards (
         id SERIAL PRIMARY KEY,
         front TEXT NOT NULL,
-        back TEXT NOT NULL,
-        recency BIGINT NOT NULL,
-        prevdifficulty INT NOT NULL
-    );`,
+        back TEXT NOT NULL
+    );
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS ease_factor DOUBLE PRECISION NOT NULL DEFAULT 2.5;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS interval_days INT NOT NULL DEFAULT 0;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS repetitions INT NOT NULL DEFAULT 0;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS due TIMESTAMPTZ NOT NULL DEFAULT NOW();
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS stability DOUBLE PRECISION NOT NULL DEFAULT 0;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS difficulty DOUBLE PRECISION NOT NULL DEFAULT 0;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS last_review TIMESTAMPTZ;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS learning_step INT NOT NULL DEFAULT 0;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS state TEXT;
+    UPDATE cards SET state = CASE
+        WHEN last_review IS NULL THEN 'new'
+        WHEN interval_days = 0 THEN 'learning'
+        ELSE 'review'
+    END WHERE state IS NULL;
+    ALTER TABLE cards ALTER COLUMN state SET DEFAULT 'new';
+    ALTER TABLE cards ALTER COLUMN state SET NOT NULL;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS lapses INT NOT NULL DEFAULT 0;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS leech BOOLEAN NOT NULL DEFAULT FALSE;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS buried_until TIMESTAMPTZ;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS suspended_state TEXT;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS note_id INT;
+    CREATE INDEX IF NOT EXISTS cards_note_id ON cards (note_id);
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'basic';
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS cloze_number INT NOT NULL DEFAULT 0;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS template INT NOT NULL DEFAULT 0;
+    ALTER TABLE cards ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', front), 'A') || setweight(to_tsvector('english', back), 'B')
+    ) STORED;
+    CREATE INDEX IF NOT EXISTS cards_search_idx ON cards USING GIN (search);
+    CREATE OR REPLACE FUNCTION card_front_key(TEXT) RETURNS TEXT AS $$
+        SELECT btrim(regexp_replace(regexp_replace(lower(normalize($1, NFKC)), '[[:punct:]]+', '', 'g'), '\s+', ' ', 'g'))
+    $$ LANGUAGE SQL IMMUTABLE;
+    CREATE INDEX IF NOT EXISTS cards_front_key_idx ON cards (card_front_key(front));
+    ALTER TABLE cards DROP COLUMN IF EXISTS recency;
+    ALTER TABLE cards DROP COLUMN IF EXISTS prevdifficulty;`,
 }
 
 var DecksTable = TableSchema{
@@ -49,7 +102,16 @@ var DecksTable = TableSchema{
 	CreateSQL: `CREATE TABLE IF NOT EXISTS decks (
         id SERIAL PRIMARY KEY,
         name TEXT NOT NULL
-		);`,
+		);
+    ALTER TABLE decks ADD COLUMN IF NOT EXISTS scheduler TEXT NOT NULL DEFAULT 'sm2';
+    ALTER TABLE decks ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES decks(id) ON DELETE CASCADE;
+    CREATE INDEX IF NOT EXISTS decks_parent_id ON decks (parent_id);
+    ALTER TABLE decks ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+    ALTER TABLE decks ADD COLUMN IF NOT EXISTS color TEXT NOT NULL DEFAULT '';
+    ALTER TABLE decks ADD COLUMN IF NOT EXISTS icon TEXT NOT NULL DEFAULT '';
+    ALTER TABLE decks ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
+    ALTER TABLE decks ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+    ALTER TABLE decks ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();`,
 }
 
 var DeckCardsTable = TableSchema{
@@ -63,15 +125,45 @@ var DeckCardsTable = TableSchema{
     );`,
 }
 
-var CurrentTables = []TableSchema{CardsTable, DecksTable, DeckCardsTable}
+var CurrentTables = []TableSchema{CardsTable, DecksTable, DeckCardsTable, StudySessionsTable, StudySessionCardsTable, ReviewLogTable, DeckSettingsTable, NoteTypesTable, NotesTable, MediaTable, TagsTable, CardTagsTable}
+
+// cardColumns lists the cards columns in the order scanCard expects them.
+const cardColumns = "id, type, front, back, cloze_number, ease_factor, interval_days, repetitions, stability, difficulty, last_review, learning_step, state, lapses, leech, buried_until, note_id, template, due, card_tag_names(id)"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// execer and queryRower are satisfied by both *sql.DB and *sql.Tx, for
+// writes that are sometimes part of a larger transaction.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
 
-func CreateCard(id int, front string, back string, reviewed int64, difficulty int) (Card, error) {
+func scanCard(row rowScanner, card *Card) error {
+	return row.Scan(&card.ID, &card.Type, &card.Front, &card.Back, &card.Cloze, &card.EaseFactor, &card.Interval, &card.Repetitions,
+		&card.Stability, &card.Difficulty, &card.LastReview, &card.Step, &card.State, &card.Lapses, &card.Leech, &card.BuriedUntil, &card.NoteID, &card.Template, &card.Due, pq.Array(&card.Tags))
+}
+
+// deckColumns lists the decks columns in the order scanDeck expects them.
+const deckColumns = "id, name, scheduler, parent_id, description, color, icon, archived, created_at, updated_at"
+
+func scanDeck(row rowScanner, deck *Deck) error {
+	return row.Scan(&deck.ID, &deck.Name, &deck.Scheduler, &deck.ParentID, &deck.Description, &deck.Color, &deck.Icon, &deck.Archived, &deck.CreatedAt, &deck.UpdatedAt)
+}
+
+// CreateCard builds a new, unreviewed card that is due immediately.
+func CreateCard(id int, front string, back string) (Card, error) {
 	card := Card{
-		ID:         id,
-		Front:      front,
-		Back:       back,
-		Reviewed:   reviewed,
-		Difficulty: difficulty,
+		ID:          id,
+		Front:       front,
+		Back:        back,
+		MemoryState: MemoryState{EaseFactor: DefaultEaseFactor, State: CardStateNew},
+		Due:         time.Now(),
 	}
 
 	if card.ID < 0 || card.Front == "" || card.Back == "" {
@@ -155,7 +247,7 @@ func DropTable(db *sql.DB, tableName string) error {
 }
 
 func DropAllTables(db *sql.DB) error {
-	tables := []string{"deck_cards", "cards", "decks"}
+	tables := []string{"deck_settings", "review_log", "study_session_cards", "study_sessions", "deck_cards", "cards", "decks", "notes", "note_types", "media", "card_tags", "tags"}
 
 	for _, table := range tables {
 		if err := DropTable(db, table); err != nil {
@@ -165,34 +257,139 @@ func DropAllTables(db *sql.DB) error {
 	return nil
 }
 
+// InsertCards inserts cards, each on a Basic note of its own. Either all of
+// the cards are inserted or none are.
 func InsertCards(db *sql.DB, cards []Card) ([]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error inserting cards: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
 	// Slice to store IDs of inserted cards
 	var insertedIDs []int
 
 	for _, card := range cards {
-		var id int
-		err := db.QueryRow("INSERT INTO cards (front, back, recency, prevdifficulty) VALUES ($1, $2, $3, $4) RETURNING id", card.Front, card.Back, card.Reviewed, card.Difficulty).Scan(&id)
+		id, err := insertBasicCard(tx, card)
 		if err != nil {
 			return nil, err // Return nil IDs and the error
 		}
 		insertedIDs = append(insertedIDs, id)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error inserting cards: %w", err)
+	}
+
 	return insertedIDs, nil
 }
 
+// insertBasicCard inserts a card on a Basic note of its own.
+func insertBasicCard(db queryRower, card Card) (int, error) {
+	noteID, err := insertBuiltinNote(db, NoteTypeBasic, map[string]string{"Front": card.Front, "Back": card.Back})
+	if err != nil {
+		return 0, err
+	}
+	card.NoteID, card.Template = &noteID, 0
+
+	return insertCard(db, card)
+}
+
+func insertCard(db queryRower, card Card) (int, error) {
+	if card.State == "" {
+		card.State = CardStateNew
+	}
+	if card.Type == "" {
+		card.Type = CardTypeBasic
+	}
+
+	var id int
+	err := db.QueryRow("INSERT INTO cards (type, front, back, cloze_number, ease_factor, interval_days, repetitions, stability, difficulty, last_review, learning_step, state, lapses, leech, note_id, template, due) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id",
+		card.Type, card.Front, card.Back, card.Cloze, card.EaseFactor, card.Interval, card.Repetitions, card.Stability, card.Difficulty, card.LastReview, card.Step, card.State, card.Lapses, card.Leech, card.NoteID, card.Template, card.Due).Scan(&id)
+	return id, err
+}
+
+// UpdateCard saves the content of a card. Its schedule is left untouched,
+// use UpdateCardSchedule for that. The front and back are saved to the
+// fields of the card's note that its template shows, and the note's cards
+// are generated again, so siblings such as a reverse card change to match.
+// Changing a side that shows more than a single field gives ErrGeneratedCard.
 func UpdateCard(db *sql.DB, card Card) error {
-	_, err := db.Exec("UPDATE cards SET front = $1, back = $2, recency = $3, prevdifficulty = $4 WHERE id = $5",
-		card.Front, card.Back, card.Reviewed, card.Difficulty, card.ID)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	if err := updateCard(tx, card); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
+func updateCard(tx *sql.Tx, card Card) error {
+	var saved Card
+	err := scanCard(tx.QueryRow("SELECT "+cardColumns+" FROM cards WHERE id = $1", card.ID), &saved)
+	if err != nil {
+		return fmt.Errorf("error getting card %d: %w", card.ID, err)
+	}
+
+	if saved.NoteID == nil {
+		_, err = tx.Exec("UPDATE cards SET front = $1, back = $2 WHERE id = $3",
+			card.Front, card.Back, card.ID)
+		return err
+	}
+
+	note, err := getNote(tx, *saved.NoteID)
+	if err != nil {
+		return err
+	}
+	noteType, err := getNoteType(tx, note.NoteTypeID)
 	if err != nil {
 		return err
 	}
+	if note.Fields == nil {
+		note.Fields = map[string]string{}
+	}
+
+	template := noteType.Templates[0]
+	if saved.Template < len(noteType.Templates) {
+		template = noteType.Templates[saved.Template]
+	}
+	for _, side := range [][3]string{{template.Front, saved.Front, card.Front}, {template.Back, saved.Back, card.Back}} {
+		if side[1] == side[2] {
+			continue
+		}
+		field := templateField(side[0])
+		if field == "" {
+			return fmt.Errorf("%w: card %d", ErrGeneratedCard, card.ID)
+		}
+		note.Fields[field] = side[2]
+	}
+
+	return updateNote(tx, noteType, *note)
+}
+
+func UpdateCardSchedule(db execer, card Card) error {
+	_, err := db.Exec(`UPDATE cards SET ease_factor = $1, interval_days = $2, repetitions = $3,
+        stability = $4, difficulty = $5, last_review = $6, learning_step = $7, state = $8, lapses = $9, leech = $10,
+        due = $11 WHERE id = $12`,
+		card.EaseFactor, card.Interval, card.Repetitions, card.Stability, card.Difficulty, card.LastReview, card.Step,
+		card.State, card.Lapses, card.Leech, card.Due, card.ID)
+
+	if err != nil {
+		return fmt.Errorf("error updating schedule for card %d: %w", card.ID, err)
+	}
 
 	return nil
 }
 
 func AddCardToDeck(db *sql.DB, cardID int, deckID int) error {
+	return addCardToDeck(db, cardID, deckID)
+}
+
+func addCardToDeck(db execer, cardID int, deckID int) error {
 	// SQL statement to insert a new relation into the card_deck table
 	query := `INSERT INTO deck_cards (card_id, deck_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`
 
@@ -214,28 +411,42 @@ func InsertDeck(db *sql.DB, deckName string) (int64, error) {
 	return id, nil
 }
 
+// SetDeckScheduler switches the scheduling algorithm used for a deck's cards.
+func SetDeckScheduler(db *sql.DB, deckID int, scheduler string) error {
+	if _, err := GetScheduler(scheduler); err != nil {
+		return err
+	}
+
+	res, err := db.Exec("UPDATE decks SET scheduler = $1 WHERE id = $2", scheduler, deckID)
+	if err != nil {
+		return fmt.Errorf("error setting scheduler for deck %d: %w", deckID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("error setting scheduler for deck %d: %w", deckID, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
 func PrintCards(db *sql.DB) error {
-	rows, err := db.Query("SELECT * FROM cards")
+	rows, err := db.Query("SELECT " + cardColumns + " FROM cards")
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var front, back string
-		var id int
-		var reviewed int64
-		var difficulty int
+		var card Card
 
-		rows.Scan(&id, &front, &back, &reviewed, &difficulty) // trunk-ignore(golangci-lint/errcheck)
-		fmt.Printf("Front: %s, Back: %s, ID: %d, Reviewed: %d, Difficulty: %d\n", front, back, id, reviewed, difficulty)
+		scanCard(rows, &card) // trunk-ignore(golangci-lint/errcheck)
+		fmt.Printf("Front: %s, Back: %s, ID: %d, Interval: %d, Due: %s\n", card.Front, card.Back, card.ID, card.Interval, card.Due.Format(time.DateOnly))
 	}
 	return nil
 }
 
 func PrintCardsInDeck(db *sql.DB, deckID int) error {
 	query := `
-        SELECT cards.* FROM cards
+        SELECT ` + cardColumns + ` FROM cards
 		JOIN deck_cards ON cards.id = deck_cards.card_id
 		WHERE deck_cards.deck_id = $1;`
 
@@ -248,7 +459,7 @@ func PrintCardsInDeck(db *sql.DB, deckID int) error {
 	fmt.Printf("Cards in deck %d:\n", deckID)
 	for rows.Next() {
 		var card Card
-		rows.Scan(&card.ID, &card.Front, &card.Back, &card.Reviewed, &card.Difficulty) // trunk-ignore(golangci-lint/errcheck)
+		scanCard(rows, &card) // trunk-ignore(golangci-lint/errcheck)
 		fmt.Printf("- ID: %d, Front: %s, Back: %s\n", card.ID, card.Front, card.Back)
 	}
 
@@ -263,51 +474,115 @@ func DeleteCard(db *sql.DB, card Card) error {
 	return nil
 }
 
+// DeleteCardByID deletes a card, and its note if no other cards are left
+// on it. It returns sql.ErrNoRows if there is no such card.
 func DeleteCardByID(db *sql.DB, cardID int) error {
-	_, err := db.Exec("DELETE FROM cards WHERE id = $1", cardID)
+	return deleteCardByID(db, cardID)
+}
+
+// deleteCardByID deletes the card in the outer statement, so that the rows
+// it affected are the cards deleted rather than the notes.
+func deleteCardByID(db execer, cardID int) error {
+	result, err := db.Exec(`WITH orphaned AS (
+            DELETE FROM notes WHERE id = (SELECT note_id FROM cards WHERE id = $1)
+            AND NOT EXISTS (SELECT 1 FROM cards WHERE note_id = notes.id AND id <> $1)
+        )
+        DELETE FROM cards WHERE id = $1`, cardID)
 	if err != nil {
 		return err
 	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("card %d: %w", cardID, sql.ErrNoRows)
+	}
 	return nil
 }
 
+// DeleteDeckByID deletes a deck along with every deck nested in it. Cards
+// are kept, even when they are left in no deck.
 func DeleteDeckByID(db *sql.DB, deckID int) error {
-	_, err := db.Exec("DELETE FROM decks WHERE id = $1", deckID)
+	_, err := db.Exec("DELETE FROM decks WHERE id IN ("+deckSubtree("$1")+")", deckID)
 	if err != nil {
 		return fmt.Errorf("error deleting deck: %w", err) // Wrap error for better context
 	}
 	return nil
 }
 
-func GetRandomCard(db *sql.DB) (*Card, error) {
-	// 1. Get the total number of cards
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM cards").Scan(&count)
+// GetRandomCard returns a random card that is neither suspended nor buried.
+// Only WithTags changes which cards it picks from.
+func GetRandomCard(db *sql.DB, opts ...CardOption) (*Card, error) {
+	options := &cardOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	query := "SELECT " + cardColumns + " FROM cards c WHERE " + visibleCardsCondition
+	var args []any
+	if options.tags != nil {
+		query += " AND " + options.tags.condition(&args)
+	}
+
+	var card Card
+	err := scanCard(db.QueryRow(query+" ORDER BY random() LIMIT 1", args...), &card)
 	if err != nil {
-		return nil, fmt.Errorf("error getting card count: %v", err)
+		return nil, fmt.Errorf("error getting card: %w", err)
 	}
 
-	// 2. Generate a random ID within the valid range
-	randomID := rand.Intn(count) + 1
+	return &card, nil
+}
+
+// CardOption changes which cards GetCardsFromDeck returns.
+type CardOption func(*cardOptions)
 
-	// 3. Fetch the card with the random ID
+type cardOptions struct {
+	visibleOnly bool
+	tags        *TagFilter
+}
+
+// VisibleOnly leaves out suspended and buried cards, for when the cards are
+// going to be studied rather than edited.
+func VisibleOnly() CardOption {
+	return func(opts *cardOptions) {
+		opts.visibleOnly = true
+	}
+}
+
+func GetCardByID(db queryRower, cardID int) (*Card, error) {
 	var card Card
-	err = db.QueryRow("SELECT id, front, back FROM cards WHERE id = $1", randomID).Scan(&card.ID, &card.Front, &card.Back)
+	err := scanCard(db.QueryRow("SELECT "+cardColumns+" FROM cards WHERE id = $1", cardID), &card)
 	if err != nil {
-		return nil, fmt.Errorf("error getting card: %v", err)
+		return nil, fmt.Errorf("error getting card %d: %w", cardID, err)
 	}
 
 	return &card, nil
 }
 
-func GetCardsFromDeck(db *sql.DB, deckID int) (*[]Card, error) {
+// GetCardsFromDeck returns every card in a deck, including suspended and
+// buried ones unless VisibleOnly is given, or those matching WithTags.
+func GetCardsFromDeck(db *sql.DB, deckID int, opts ...CardOption) (*[]Card, error) {
+	options := &cardOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	// 1. Fetch the cards associated with the deck
-	rows, err := db.Query(`
-        SELECT c.*
+	query := `
+        SELECT ` + cardColumns + `
         FROM cards c
         JOIN deck_cards dc ON c.id = dc.card_id
         WHERE dc.deck_id = $1
-    `, deckID)
+    `
+	args := []any{deckID}
+	if options.visibleOnly {
+		query += " AND " + visibleCardsCondition
+	}
+	if options.tags != nil {
+		query += " AND " + options.tags.condition(&args)
+	}
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error getting cards for deck: %v", err)
 	}
@@ -318,7 +593,7 @@ func GetCardsFromDeck(db *sql.DB, deckID int) (*[]Card, error) {
 	// 2. Populate the deck's Cards slice
 	for rows.Next() {
 		var card Card
-		err := rows.Scan(&card.ID, &card.Front, &card.Back, &card.Reviewed, &card.Difficulty)
+		err := scanCard(rows, &card)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning card: %v", err)
 		}
@@ -328,9 +603,31 @@ func GetCardsFromDeck(db *sql.DB, deckID int) (*[]Card, error) {
 	return &cards, nil
 }
 
-func GetDecksData(db *sql.DB) (*[]Deck, error) {
+// GetDecksData returns the top level decks by name, with the decks nested
+// in each as its Children. Archived decks, and the decks nested in them,
+// are left out unless IncludeArchived is given.
+func GetDecksData(db *sql.DB, opts ...DeckOption) (*[]Deck, error) {
+	options := &deckOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	decks, err := getDeckList(db)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := buildDeckTree(decks)
+	if !options.includeArchived {
+		tree = withoutArchived(tree)
+	}
+	return &tree, nil
+}
+
+// getDeckList returns every deck by name, without nesting them.
+func getDeckList(db *sql.DB) ([]Deck, error) {
 	// 1. Fetch all decks
-	rows, err := db.Query("SELECT * FROM decks")
+	rows, err := db.Query("SELECT " + deckColumns + " FROM decks ORDER BY name, id")
 	if err != nil {
 		return nil, fmt.Errorf("error getting decks: %v", err)
 	}
@@ -341,14 +638,24 @@ func GetDecksData(db *sql.DB) (*[]Deck, error) {
 	// 2. Populate the decks slice
 	for rows.Next() {
 		var deck Deck
-		err := rows.Scan(&deck.ID, &deck.Name)
+		err := scanDeck(rows, &deck)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning deck: %v", err)
 		}
 		decks = append(decks, deck)
 	}
 
-	return &decks, nil
+	return decks, nil
+}
+
+func GetDeckByID(db *sql.DB, deckID int) (*Deck, error) {
+	var deck Deck
+	err := scanDeck(db.QueryRow("SELECT "+deckColumns+" FROM decks WHERE id = $1", deckID), &deck)
+	if err != nil {
+		return nil, fmt.Errorf("error getting deck %d: %w", deckID, err)
+	}
+
+	return &deck, nil
 }
 
 /* Get all cards from given deck
@@ -360,14 +667,6 @@ WHERE dc.deck_id = $1;
 
 */
 
-/* Get all decks a card is in
-
-SELECT d.*
-FROM decks d
-JOIN deck_cards dc ON d.id = dc.deck_id
-WHERE dc.card_id = $1;
-*/
-
 /*  Insert into deck_cards
 INSERT INTO deck_cards (card_id, deck_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;
 */
@@ -2,28 +2,60 @@ package db
 
 import (
 	"bytes"
+	"database/sql"
 	"fmt"
 	"os"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
+// cardRowColumns mirrors cardColumns for building mocked card rows.
+var cardRowColumns = []string{"id", "type", "front", "back", "cloze_number", "ease_factor", "interval_days", "repetitions", "stability", "difficulty", "last_review", "learning_step", "state", "lapses", "leech", "buried_until", "note_id", "template", "due", "tags"}
+
+// deckRows builds mocked rows holding decks, in the column order of
+// deckColumns.
+func deckRows(decks ...Deck) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "scheduler", "parent_id", "description", "color", "icon", "archived", "created_at", "updated_at"})
+	for _, deck := range decks {
+		rows.AddRow(deck.ID, deck.Name, deck.Scheduler, deck.ParentID, deck.Description, deck.Color, deck.Icon, deck.Archived, deck.CreatedAt, deck.UpdatedAt)
+	}
+	return rows
+}
+
+// due is a fixed due date for mocked card rows.
+var due = time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+// cardRows builds mocked rows holding cards, in the column order of cardColumns.
+func cardRows(cards ...Card) *sqlmock.Rows {
+	rows := sqlmock.NewRows(cardRowColumns)
+	for _, card := range cards {
+		tags, _ := pq.StringArray(card.Tags).Value()
+		rows.AddRow(card.ID, card.Type, card.Front, card.Back, card.Cloze, card.EaseFactor, card.Interval, card.Repetitions,
+			card.Stability, card.Difficulty, card.LastReview, card.Step, card.State, card.Lapses, card.Leech, card.BuriedUntil, card.NoteID, card.Template, card.Due, tags)
+	}
+	return rows
+}
+
 func TestCreateCard(t *testing.T) {
 	t.Run("Successful creation", func(t *testing.T) {
-		card, err := CreateCard(1, "front", "back", 1, 5)
+		card, err := CreateCard(1, "front", "back")
 		assert.Nil(t, err)
 		assert.Equal(t, 1, card.ID)
 		assert.Equal(t, "front", card.Front)
 		assert.Equal(t, "back", card.Back)
-		assert.Equal(t, int64(1), card.Reviewed)
-		assert.Equal(t, 5, card.Difficulty)
+		assert.Equal(t, DefaultEaseFactor, card.EaseFactor)
+		assert.Equal(t, 0, card.Interval)
+		assert.Equal(t, CardStateNew, card.State)
+		assert.WithinDuration(t, time.Now(), card.Due, time.Second)
 	})
 
 	t.Run("Erroneous creation", func(t *testing.T) {
-		card, err := CreateCard(1, "", "back", 1, 5)
+		card, err := CreateCard(1, "", "back")
 		assert.NotNil(t, err)
 		assert.EqualError(t, err, "invalid card data")
 		assert.Equal(t, Card{}, card)
@@ -61,13 +93,13 @@ func TestDropAllTables(t *testing.T) {
 	}{
 		{
 			name:    "Success",
-			tables:  []string{"card_deck", "cards", "decks"},
+			tables:  []string{"deck_settings", "review_log", "study_session_cards", "study_sessions", "deck_cards", "cards", "decks", "notes", "note_types", "media", "card_tags", "tags"},
 			dropErr: nil,
 			wantErr: false,
 		},
 		{
 			name:    "Error dropping table",
-			tables:  []string{"card_deck", "cards", "decks"},
+			tables:  []string{"deck_settings", "review_log", "study_session_cards", "study_sessions", "deck_cards", "cards", "decks", "notes", "note_types", "media", "card_tags", "tags"},
 			dropErr: fmt.Errorf("error dropping table"),
 			wantErr: true,
 		},
@@ -190,9 +222,18 @@ func TestCreateAllTables(t *testing.T) {
 		mock.ExpectExec("CREATE TABLE IF NOT EXISTS cards").WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectExec("CREATE TABLE IF NOT EXISTS decks").WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectExec("CREATE TABLE IF NOT EXISTS deck_cards").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS study_sessions").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS study_session_cards").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS review_log").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS deck_settings").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS note_types").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS notes").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS media").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS tags").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS card_tags").WillReturnResult(sqlmock.NewResult(0, 0))
 
 		// Call the function that executes the SQL
-		tables := []TableSchema{CardsTable, DecksTable, DeckCardsTable}
+		tables := CurrentTables
 		err = CreateAllTables(db, tables)
 		assert.NoError(t, err)
 		// Verify that all expectations were met
@@ -228,11 +269,15 @@ func TestInsertCards(t *testing.T) {
 		}
 		defer db.Close()
 
+		mock.ExpectBegin()
+		expectBuiltinNote(mock, NoteTypeBasic, 3)
 		mock.ExpectQuery("INSERT INTO cards").
-			WithArgs("Front", "Back", 1, 5).
+			WithArgs(CardTypeBasic, "Front", "Back", 0, 2.5, 1, 1, 0.0, 0.0, nil, 0, CardStateReview, 0, false, 3, 0, due).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
 
-		ids, err := InsertCards(db, []Card{{Front: "Front", Back: "Back", Reviewed: 1, Difficulty: 5}})
+		card := Card{Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5, Interval: 1, Repetitions: 1, State: CardStateReview}, Due: due}
+		ids, err := InsertCards(db, []Card{card})
 		assert.NoError(t, err)
 		assert.Equal(t, []int{1}, ids)
 
@@ -248,11 +293,16 @@ func TestInsertCards(t *testing.T) {
 		}
 		defer db.Close()
 
+		// Nothing is left behind when a card fails
+		mock.ExpectBegin()
+		expectBuiltinNote(mock, NoteTypeBasic, 3)
 		mock.ExpectQuery("INSERT INTO cards").
-			WithArgs("Front", "Back", 1, 0).
+			WithArgs(CardTypeBasic, "Front", "Back", 0, 2.5, 0, 0, 0.0, 0.0, nil, 0, CardStateNew, 0, false, 3, 0, due).
 			WillReturnError(fmt.Errorf("error inserting card"))
+		mock.ExpectRollback()
 
-		_, err = InsertCards(db, []Card{{Front: "Front", Back: "Back", Reviewed: 1, Difficulty: 0}})
+		card := Card{Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5}, Due: due}
+		_, err = InsertCards(db, []Card{card})
 		assert.Error(t, err)
 
 		if err := mock.ExpectationsWereMet(); err != nil {
@@ -261,6 +311,79 @@ func TestInsertCards(t *testing.T) {
 	})
 }
 
+func TestUpdateCard(t *testing.T) {
+	reversed := builtinNoteTypes[1]
+	reversed.ID = 2
+	noteID := 30
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		// Card 8 is the reverse of card 7, so its front is the note's back
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards WHERE id = $1")).WithArgs(8).
+			WillReturnRows(cardRows(Card{ID: 8, Type: CardTypeBasic, Front: "to speak", Back: "hablar", NoteID: &noteID, Template: 1, Due: due}))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + noteColumns + " FROM notes WHERE id = $1")).WithArgs(30).
+			WillReturnRows(noteRows(Note{ID: 30, NoteTypeID: 2, Fields: map[string]string{"Front": "hablar", "Back": "to speak"}}))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + noteTypeColumns + " FROM note_types WHERE id = $1")).WithArgs(2).
+			WillReturnRows(noteTypeRows(reversed))
+		mock.ExpectExec("UPDATE notes SET fields").WithArgs([]byte(`{"Back":"to talk","Front":"hablar"}`), 30).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT id, template, cloze_number FROM cards WHERE note_id = \\$1").WithArgs(30).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "template", "cloze_number"}).AddRow(7, 0, 0).AddRow(8, 1, 0))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE cards SET front = $1, back = $2 WHERE id = $3")).
+			WithArgs("hablar", "to talk", 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE cards SET front = $1, back = $2 WHERE id = $3")).
+			WithArgs("to talk", "hablar", 8).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = UpdateCard(db, Card{ID: 8, Front: "to talk", Back: "hablar"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Generated side", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WithArgs(20).
+			WillReturnRows(cardRows(Card{ID: 20, Type: CardTypeBasic, Front: "犬", Back: "犬<hr>いぬ<br>dog", NoteID: &noteID, Due: due}))
+		mock.ExpectQuery("SELECT .* FROM notes WHERE id = \\$1").WithArgs(30).
+			WillReturnRows(noteRows(Note{ID: 30, NoteTypeID: 4, Fields: map[string]string{"Word": "犬", "Reading": "いぬ", "Meaning": "dog"}}))
+		mock.ExpectQuery("SELECT .* FROM note_types WHERE id = \\$1").WithArgs(4).WillReturnRows(noteTypeRows(vocabulary))
+		mock.ExpectRollback()
+
+		err = UpdateCard(db, Card{ID: 20, Front: "犬", Back: "dog"})
+		assert.ErrorIs(t, err, ErrGeneratedCard)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .* FROM cards WHERE id = \\$1").WillReturnError(fmt.Errorf("select error"))
+		mock.ExpectRollback()
+
+		err = UpdateCard(db, Card{ID: 7, Front: "hablar", Back: "to speak"})
+		assert.EqualError(t, err, "error getting card 7: select error")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestInsertDeck(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -302,6 +425,50 @@ func TestInsertDeck(t *testing.T) {
 	})
 }
 
+func TestSetDeckScheduler(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE decks SET scheduler = $1 WHERE id = $2")).
+			WithArgs(SchedulerFSRS, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err = SetDeckScheduler(db, 1, SchedulerFSRS)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown scheduler", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		err = SetDeckScheduler(db, 1, "leitner")
+		assert.ErrorIs(t, err, ErrUnknownScheduler)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Deck not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE decks SET scheduler").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err = SetDeckScheduler(db, 99, SchedulerSM2)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestPrintCards(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -311,11 +478,12 @@ func TestPrintCards(t *testing.T) {
 		defer db.Close()
 
 		// Set expectations for the SQL query
-		rows := sqlmock.NewRows([]string{"id", "front", "back", "reviewed", "difficulty"}).
-			AddRow(1, "Front of card 1", "Back of card 1", 1234567890, 5).
-			AddRow(2, "Front of card 2", "Back of card 2", 1234567891, 6)
+		rows := cardRows(
+			Card{ID: 1, Front: "Front of card 1", Back: "Back of card 1", MemoryState: MemoryState{EaseFactor: 2.5, Interval: 1, Repetitions: 1}, Due: due},
+			Card{ID: 2, Front: "Front of card 2", Back: "Back of card 2", MemoryState: MemoryState{EaseFactor: 2.6, Interval: 6, Repetitions: 2}, Due: due.AddDate(0, 0, 5)},
+		)
 
-		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM cards")).WillReturnRows(rows)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards")).WillReturnRows(rows)
 
 		old := os.Stdout // keep backup of the real stdout
 		r, w, _ := os.Pipe()
@@ -339,8 +507,8 @@ func TestPrintCards(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 
 		// Check the output text
-		expectedOutput := "Front: Front of card 1, Back: Back of card 1, ID: 1, Reviewed: 1234567890, Difficulty: 5\n" +
-			"Front: Front of card 2, Back: Back of card 2, ID: 2, Reviewed: 1234567891, Difficulty: 6\n"
+		expectedOutput := "Front: Front of card 1, Back: Back of card 1, ID: 1, Interval: 1, Due: 2024-05-01\n" +
+			"Front: Front of card 2, Back: Back of card 2, ID: 2, Interval: 6, Due: 2024-05-06\n"
 		assert.Equal(t, expectedOutput, output)
 	})
 	t.Run("Error", func(t *testing.T) {
@@ -350,7 +518,7 @@ func TestPrintCards(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM cards")).WillReturnError(fmt.Errorf("error selecting cards"))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards")).WillReturnError(fmt.Errorf("error selecting cards"))
 
 		err = PrintCards(db)
 
@@ -369,13 +537,14 @@ func TestPrintCardsInDeck(t *testing.T) {
 
 		// Set up expected query and results for deck ID 1
 		expectedDeckID := 1
-		rows := sqlmock.NewRows([]string{"id", "front", "back", "reviewed", "difficulty"}).
-			AddRow(3, "Front 3", "Back 3", 9876543210, 3).
-			AddRow(5, "Front 5", "Back 5", 9876543211, 4)
+		rows := cardRows(
+			Card{ID: 3, Front: "Front 3", Back: "Back 3", MemoryState: MemoryState{EaseFactor: 2.5}, Due: due},
+			Card{ID: 5, Front: "Front 5", Back: "Back 5", MemoryState: MemoryState{EaseFactor: 2.5}, Due: due},
+		)
 
 		// Expect a specific query with the deck ID
 		mock.ExpectQuery(regexp.QuoteMeta(`
-            SELECT cards.* FROM cards
+            SELECT ` + cardColumns + ` FROM cards
             JOIN deck_cards ON cards.id = deck_cards.card_id
             WHERE deck_cards.deck_id = $1;
         `)).
@@ -416,7 +585,7 @@ func TestPrintCardsInDeck(t *testing.T) {
 
 		// Expect the query and return an error
 		mock.ExpectQuery(regexp.QuoteMeta(`
-            SELECT cards.* FROM cards
+            SELECT ` + cardColumns + ` FROM cards
             JOIN deck_cards ON cards.id = deck_cards.card_id
             WHERE deck_cards.deck_id = $1;
         `)).
@@ -432,6 +601,8 @@ func TestPrintCardsInDeck(t *testing.T) {
 
 // working
 func TestGetRandomCard(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards c WHERE " + visibleCardsCondition + " ORDER BY random() LIMIT 1")
+
 	t.Run("Success", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
 		if err != nil {
@@ -439,17 +610,9 @@ func TestGetRandomCard(t *testing.T) {
 		}
 		defer db.Close()
 
-		// 1. Mock the count query
-		expectedCount := 10
-		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM cards").
-			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(expectedCount))
-
-		// 2. Mock the card retrieval query
-		expectedCard := Card{ID: 5, Front: "Front 5", Back: "Back 5"} // Example card
-		mock.ExpectQuery("SELECT id, front, back FROM cards WHERE id = \\$1").
-			WithArgs(sqlmock.AnyArg()).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "front", "back"}).
-				AddRow(expectedCard.ID, expectedCard.Front, expectedCard.Back))
+		// Mock the card retrieval query
+		expectedCard := Card{ID: 5, Front: "Front 5", Back: "Back 5", MemoryState: MemoryState{EaseFactor: 2.5, State: CardStateNew}, Due: due} // Example card
+		mock.ExpectQuery(query).WillReturnRows(cardRows(expectedCard))
 
 		// Call the function under test
 		card, err := GetRandomCard(db)
@@ -461,23 +624,19 @@ func TestGetRandomCard(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
-	t.Run("ErrorGettingCount", func(t *testing.T) {
+	t.Run("NoVisibleCards", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
 		if err != nil {
 			t.Fatalf("error creating mock database: %v", err)
 		}
 		defer db.Close()
 
-		// Mock an error when getting the count
-		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM cards").
-			WillReturnError(fmt.Errorf("count query error"))
+		mock.ExpectQuery(query).WillReturnRows(cardRows())
 
-		// Call the function and expect an error
 		card, err := GetRandomCard(db)
 
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
 		assert.Nil(t, card)
-		assert.EqualError(t, err, "error getting card count: count query error")
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -488,12 +647,7 @@ func TestGetRandomCard(t *testing.T) {
 		}
 		defer db.Close()
 
-		// Mock success for count, error for card retrieval
-		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM cards").
-			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
-		mock.ExpectQuery("SELECT id, front, back FROM cards WHERE id = \\$1").
-			WithArgs(sqlmock.AnyArg()). // Assuming the random ID will be 1
-			WillReturnError(fmt.Errorf("card retrieval error"))
+		mock.ExpectQuery(query).WillReturnError(fmt.Errorf("card retrieval error"))
 
 		// Call the function and expect an error
 		card, err := GetRandomCard(db)
@@ -516,16 +670,13 @@ func TestGetCardsFromDeck(t *testing.T) {
 		// 1. Mock successful query with expected deck ID and cards
 		deckID := 123 // Example deck ID
 		expectedCards := []Card{
-			{ID: 1, Front: "Front 1", Back: "Back 1", Reviewed: 1234567890, Difficulty: 5},
-			{ID: 2, Front: "Front 2", Back: "Back 2", Reviewed: 9876543210, Difficulty: 4},
+			{ID: 1, Front: "Front 1", Back: "Back 1", MemoryState: MemoryState{EaseFactor: 2.5, Interval: 1, Repetitions: 1}, Due: due},
+			{ID: 2, Front: "Front 2", Back: "Back 2", MemoryState: MemoryState{EaseFactor: 2.36, Interval: 6, Repetitions: 2}, Due: due},
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "front", "back", "reviewed", "difficulty"})
-		for _, card := range expectedCards {
-			rows.AddRow(card.ID, card.Front, card.Back, card.Reviewed, card.Difficulty)
-		}
+		rows := cardRows(expectedCards...)
 		mock.ExpectQuery(regexp.QuoteMeta(`
-            SELECT c.*
+            SELECT ` + cardColumns + `
             FROM cards c
             JOIN deck_cards dc ON c.id = dc.card_id
             WHERE dc.deck_id = $1
@@ -541,6 +692,26 @@ func TestGetCardsFromDeck(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("VisibleOnly", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`
+            SELECT ` + cardColumns + `
+            FROM cards c
+            JOIN deck_cards dc ON c.id = dc.card_id
+            WHERE dc.deck_id = $1
+         AND ` + visibleCardsCondition)).WithArgs(123).WillReturnRows(cardRows())
+
+		cards, err := GetCardsFromDeck(db, 123, VisibleOnly())
+		assert.NoError(t, err)
+		assert.Empty(t, *cards)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("QueryError", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
 		if err != nil {
@@ -550,7 +721,7 @@ func TestGetCardsFromDeck(t *testing.T) {
 
 		// Mock an error when fetching cards
 		mock.ExpectQuery(regexp.QuoteMeta(`
-            SELECT c.*
+            SELECT ` + cardColumns + `
             FROM cards c
             JOIN deck_cards dc ON c.id = dc.card_id
             WHERE dc.deck_id = $1
@@ -573,7 +744,7 @@ func TestGetCardsFromDeck(t *testing.T) {
 
 		// Mock invalid data returned from the database that would fail Scan()
 		mock.ExpectQuery(regexp.QuoteMeta(`
-            SELECT c.*
+            SELECT ` + cardColumns + `
             FROM cards c
             JOIN deck_cards dc ON c.id = dc.card_id
             WHERE dc.deck_id = $1
@@ -598,16 +769,13 @@ func TestGetDecksData(t *testing.T) {
 
 		// 1. Mock successful query with expected deck data
 		expectedDecks := []Deck{
-			{ID: 1, Name: "Deck 1"},
-			{ID: 2, Name: "Deck 2"},
-			{ID: 3, Name: "Deck 3"}, // Adding more decks for a thorough test
+			{ID: 1, Name: "Deck 1", Scheduler: SchedulerSM2},
+			{ID: 2, Name: "Deck 2", Scheduler: SchedulerFSRS},
+			{ID: 3, Name: "Deck 3", Scheduler: SchedulerSM2}, // Adding more decks for a thorough test
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "name"})
-		for _, deck := range expectedDecks {
-			rows.AddRow(deck.ID, deck.Name)
-		}
-		mock.ExpectQuery("SELECT \\* FROM decks").WillReturnRows(rows)
+		rows := deckRows(expectedDecks...)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).WillReturnRows(rows)
 
 		// 2. Call the function
 		decks, err := GetDecksData(db)
@@ -627,7 +795,7 @@ func TestGetDecksData(t *testing.T) {
 		defer db.Close()
 
 		// Mock an error when fetching decks
-		mock.ExpectQuery("SELECT \\* FROM decks").WillReturnError(fmt.Errorf("query error"))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).WillReturnError(fmt.Errorf("query error"))
 
 		// Call the function and expect an error
 		decks, err := GetDecksData(db)
@@ -645,9 +813,9 @@ func TestGetDecksData(t *testing.T) {
 		defer db.Close()
 
 		// Mock invalid data returned from the database to trigger a Scan() error
-		mock.ExpectQuery("SELECT \\* FROM decks").
-			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
-				AddRow("invalid", 123)) // Inconsistent data types
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scheduler", "parent_id", "description", "color", "icon", "archived", "created_at", "updated_at"}).
+				AddRow("invalid", 123, SchedulerSM2, nil, "", "", "", false, due, due)) // Inconsistent data types
 
 		// Call the function and expect an error
 		decks, err := GetDecksData(db)
@@ -658,6 +826,40 @@ func TestGetDecksData(t *testing.T) {
 	})
 }
 
+func TestGetDeckByID(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks WHERE id = $1")).WithArgs(2).
+			WillReturnRows(deckRows(Deck{ID: 2, Name: "Deck 2", Scheduler: SchedulerFSRS}))
+
+		deck, err := GetDeckByID(db, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, Deck{ID: 2, Name: "Deck 2", Scheduler: SchedulerFSRS}, *deck)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks WHERE id = $1")).WithArgs(2).
+			WillReturnError(sql.ErrNoRows)
+
+		deck, err := GetDeckByID(db, 2)
+		assert.Nil(t, deck)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestDeleteCard(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -669,7 +871,7 @@ func TestDeleteCard(t *testing.T) {
 		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM cards WHERE id = $1")).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
 
 		// Create a Card instance
-		card := Card{ID: 1, Front: "Front", Back: "Back", Reviewed: 1, Difficulty: 5}
+		card := Card{ID: 1, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: DefaultEaseFactor}}
 
 		InsertCards(db, []Card{card}) // trunk-ignore(golangci-lint/errcheck)
 
@@ -687,8 +889,8 @@ func TestDeleteCard(t *testing.T) {
 		defer db.Close()
 
 		mock.ExpectExec("DELETE FROM cards").WillReturnError(fmt.Errorf("error deleting card"))
-		card := Card{ID: 99, Front: "Front", Back: "Back", Reviewed: 1, Difficulty: 5}
-		card2 := Card{Front: "Front", Back: "Back", Reviewed: 1, Difficulty: 5}
+		card := Card{ID: 99, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: DefaultEaseFactor}}
+		card2 := Card{Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: DefaultEaseFactor}}
 
 		InsertCards(db, []Card{card}) // trunk-ignore(golangci-lint/errcheck)
 		err = DeleteCard(db, card2)
@@ -706,7 +908,7 @@ func TestDeleteCardByID(t *testing.T) {
 		defer db.Close()
 
 		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM cards WHERE id = $1")).WithArgs(99).WillReturnResult(sqlmock.NewResult(0, 1))
-		card := Card{ID: 99, Front: "Front", Back: "Back", Reviewed: 1, Difficulty: 5}
+		card := Card{ID: 99, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: DefaultEaseFactor}}
 
 		InsertCards(db, []Card{card}) // trunk-ignore(golangci-lint/errcheck)
 		err = DeleteCardByID(db, 99)
@@ -728,6 +930,19 @@ func TestDeleteCardByID(t *testing.T) {
 		assert.Error(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+	t.Run("Not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM cards WHERE id = $1")).WithArgs(99).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err = DeleteCardByID(db, 99)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestDeleteDeckByID(t *testing.T) {
@@ -738,7 +953,8 @@ func TestDeleteDeckByID(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM decks WHERE id = $1")).
+		// The decks nested in it go with it
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM decks WHERE id IN (" + deckSubtree("$1") + ")")).
 			WithArgs(1).                              // Example deck ID
 			WillReturnResult(sqlmock.NewResult(0, 1)) // 1 row affected
 
@@ -772,7 +988,7 @@ func TestAddCardToDeck(t *testing.T) {
 
 		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO deck_cards")).WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
 
-		card := Card{ID: 99, Front: "Front", Back: "Back", Reviewed: 1, Difficulty: 5}
+		card := Card{ID: 99, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: DefaultEaseFactor}}
 		InsertCards(db, []Card{card})
 
 		err = AddCardToDeck(db, 1, 1)
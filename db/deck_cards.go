@@ -0,0 +1,175 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidMove = errors.New("invalid move")
+
+// GetCardDecks returns the decks a card is in, by name.
+func GetCardDecks(db *sql.DB, cardID int) (*[]Deck, error) {
+	rows, err := db.Query(`SELECT `+deckColumns+` FROM decks
+        WHERE id IN (SELECT deck_id FROM deck_cards WHERE card_id = $1) ORDER BY name, id`, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting decks for card %d: %v", cardID, err)
+	}
+	defer rows.Close()
+
+	decks := []Deck{}
+	for rows.Next() {
+		var deck Deck
+		if err := scanDeck(rows, &deck); err != nil {
+			return nil, fmt.Errorf("error scanning deck: %v", err)
+		}
+		decks = append(decks, deck)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting decks for card %d: %v", cardID, err)
+	}
+
+	return &decks, nil
+}
+
+// GetDeckCard returns a card that is in a deck, or an error wrapping
+// sql.ErrNoRows if the card does not exist or is not in the deck.
+func GetDeckCard(db *sql.DB, deckID int, cardID int) (*Card, error) {
+	var card Card
+	err := scanCard(db.QueryRow("SELECT "+cardColumns+" FROM cards WHERE id = $1 AND id IN (SELECT card_id FROM deck_cards WHERE deck_id = $2)", cardID, deckID), &card)
+	if err != nil {
+		return nil, fmt.Errorf("error getting card %d in deck %d: %w", cardID, deckID, err)
+	}
+	return &card, nil
+}
+
+// RemoveCardFromDeck takes a card out of a deck without deleting it, even
+// when it is left in no deck.
+func RemoveCardFromDeck(db *sql.DB, cardID int, deckID int) error {
+	return removeCardFromDeck(db, cardID, deckID)
+}
+
+func removeCardFromDeck(db execer, cardID int, deckID int) error {
+	res, err := db.Exec("DELETE FROM deck_cards WHERE card_id = $1 AND deck_id = $2", cardID, deckID)
+	if err != nil {
+		return fmt.Errorf("error removing card %d from deck %d: %w", cardID, deckID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("error removing card %d from deck %d: %w", cardID, deckID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// DeleteCardFromDeck takes a card out of a deck, and deletes the card, with
+// its review history, only if that left it in no deck. It reports whether
+// the card was deleted, and returns sql.ErrNoRows if the card isn't in the
+// deck.
+func DeleteCardFromDeck(db *sql.DB, cardID int, deckID int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error removing card %d from deck %d: %w", cardID, deckID, err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	if err := removeCardFromDeck(tx, cardID, deckID); err != nil {
+		return false, err
+	}
+	var inDeck bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM deck_cards WHERE card_id = $1)", cardID).Scan(&inDeck)
+	if err != nil {
+		return false, fmt.Errorf("error removing card %d from deck %d: %w", cardID, deckID, err)
+	}
+	if !inDeck {
+		if err := deleteCardByID(tx, cardID); err != nil {
+			return false, fmt.Errorf("error deleting card %d: %w", cardID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error removing card %d from deck %d: %w", cardID, deckID, err)
+	}
+	return !inDeck, nil
+}
+
+// CopyCardsToDeck adds cards to a deck, leaving them in the decks they are
+// already in. Cards that do not exist or are in the deck already are
+// skipped. It returns how many cards were added.
+func CopyCardsToDeck(db *sql.DB, cardIDs []int, deckID int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error copying cards to deck %d: %w", deckID, err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	if err := checkDeckExists(tx, deckID); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`INSERT INTO deck_cards (card_id, deck_id)
+        SELECT id, $2 FROM cards WHERE id = ANY($1) ON CONFLICT DO NOTHING`, pq.Array(cardIDs), deckID)
+	if err != nil {
+		return 0, fmt.Errorf("error copying cards to deck %d: %w", deckID, err)
+	}
+	copied, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error copying cards to deck %d: %w", deckID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error copying cards to deck %d: %w", deckID, err)
+	}
+
+	return int(copied), nil
+}
+
+// MoveCardsToDeck moves cards from one deck to another. Cards that are not
+// in the from deck are left where they are. It returns how many cards were
+// moved, including those that were in both decks already.
+func MoveCardsToDeck(db *sql.DB, cardIDs []int, fromDeckID int, toDeckID int) (int, error) {
+	if fromDeckID == toDeckID {
+		return 0, fmt.Errorf("%w: cards are already in deck %d", ErrInvalidMove, toDeckID)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error moving cards to deck %d: %w", toDeckID, err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	if err := checkDeckExists(tx, toDeckID); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`INSERT INTO deck_cards (card_id, deck_id)
+        SELECT card_id, $3 FROM deck_cards WHERE deck_id = $2 AND card_id = ANY($1) ON CONFLICT DO NOTHING`, pq.Array(cardIDs), fromDeckID, toDeckID)
+	if err != nil {
+		return 0, fmt.Errorf("error moving cards to deck %d: %w", toDeckID, err)
+	}
+	res, err := tx.Exec("DELETE FROM deck_cards WHERE deck_id = $2 AND card_id = ANY($1)", pq.Array(cardIDs), fromDeckID)
+	if err != nil {
+		return 0, fmt.Errorf("error moving cards to deck %d: %w", toDeckID, err)
+	}
+	moved, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error moving cards to deck %d: %w", toDeckID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error moving cards to deck %d: %w", toDeckID, err)
+	}
+
+	return int(moved), nil
+}
+
+// checkDeckExists returns an error wrapping sql.ErrNoRows if a deck does
+// not exist.
+func checkDeckExists(db queryRower, deckID int) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1)", deckID).Scan(&exists); err != nil {
+		return fmt.Errorf("error getting deck %d: %w", deckID, err)
+	}
+	if !exists {
+		return fmt.Errorf("error getting deck %d: %w", deckID, sql.ErrNoRows)
+	}
+	return nil
+}
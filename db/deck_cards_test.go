@@ -0,0 +1,230 @@
+package db
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCardDecks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	spanish := 1
+	mock.ExpectQuery("SELECT id, name, .* FROM decks\\s+WHERE id IN \\(SELECT deck_id FROM deck_cards WHERE card_id = \\$1\\)").WithArgs(5).
+		WillReturnRows(deckRows(Deck{ID: 1, Name: "Spanish", Scheduler: SchedulerSM2}, Deck{ID: 2, Name: "Verbs", Scheduler: SchedulerSM2, ParentID: &spanish}))
+
+	decks, err := GetCardDecks(db, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []Deck{{ID: 1, Name: "Spanish", Scheduler: SchedulerSM2}, {ID: 2, Name: "Verbs", Scheduler: SchedulerSM2, ParentID: &spanish}}, *decks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDeckCard(t *testing.T) {
+	query := "SELECT .* FROM cards WHERE id = \\$1 AND id IN \\(SELECT card_id FROM deck_cards WHERE deck_id = \\$2\\)"
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		card := Card{ID: 5, Type: CardTypeBasic, Front: "hablar", Back: "to speak", Tags: []string{}, Due: due}
+		mock.ExpectQuery(query).WithArgs(5, 1).WillReturnRows(cardRows(card))
+
+		got, err := GetDeckCard(db, 1, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, card, *got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not in deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(query).WithArgs(5, 2).WillReturnRows(cardRows())
+
+		_, err = GetDeckCard(db, 2, 5)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRemoveCardFromDeck(t *testing.T) {
+	query := regexp.QuoteMeta("DELETE FROM deck_cards WHERE card_id = $1 AND deck_id = $2")
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(query).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err = RemoveCardFromDeck(db, 5, 1)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not in deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(query).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err = RemoveCardFromDeck(db, 5, 1)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteCardFromDeck(t *testing.T) {
+	remove := regexp.QuoteMeta("DELETE FROM deck_cards WHERE card_id = $1 AND deck_id = $2")
+	inDeck := regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM deck_cards WHERE card_id = $1)")
+
+	t.Run("Still in another deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(remove).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(inDeck).WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectCommit()
+
+		deleted, err := DeleteCardFromDeck(db, 5, 1)
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Left in no deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(remove).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(inDeck).WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM cards WHERE id = $1")).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		deleted, err := DeleteCardFromDeck(db, 5, 1)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not in deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(remove).WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err = DeleteCardFromDeck(db, 5, 1)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCopyCardsToDeck(t *testing.T) {
+	exists := regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1)")
+	ids, _ := pq.Array([]int{5, 6}).Value()
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(exists).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec("INSERT INTO deck_cards \\(card_id, deck_id\\)\\s+SELECT id, \\$2 FROM cards").WithArgs(ids, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		copied, err := CopyCardsToDeck(db, []int{5, 6}, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, copied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Deck not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(exists).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		_, err = CopyCardsToDeck(db, []int{5, 6}, 2)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMoveCardsToDeck(t *testing.T) {
+	ids, _ := pq.Array([]int{5, 6}).Value()
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1)")).WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec("INSERT INTO deck_cards \\(card_id, deck_id\\)\\s+SELECT card_id, \\$3 FROM deck_cards WHERE deck_id = \\$2").WithArgs(ids, 1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM deck_cards WHERE deck_id = $2 AND card_id = ANY($1)")).WithArgs(ids, 1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		moved, err := MoveCardsToDeck(db, []int{5, 6}, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, moved)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Same deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		_, err = MoveCardsToDeck(db, []int{5, 6}, 1, 1)
+		assert.ErrorIs(t, err, ErrInvalidMove)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
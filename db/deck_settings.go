@@ -0,0 +1,295 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DeckSettings controls how a deck is studied.
+type DeckSettings struct {
+	DeckID             int    `json:"deckId"`
+	NewPerDay          int    `json:"newPerDay"`
+	ReviewsPerDay      int    `json:"reviewsPerDay"`
+	LearningSteps      []int  `json:"learningSteps"`      // minutes between reviews while learning
+	GraduatingInterval int    `json:"graduatingInterval"` // days, once the last learning step is passed
+	MaximumInterval    int    `json:"maximumInterval"`    // days
+	RelearningSteps    []int  `json:"relearningSteps"`    // minutes between reviews after a lapse
+	LeechThreshold     int    `json:"leechThreshold"`     // lapses before a card is a leech, 0 to never mark leeches
+	LeechAction        string `json:"leechAction"`
+}
+
+// What happens to a card once it becomes a leech.
+const (
+	LeechActionTag     = "tag"     // only mark the card as a leech
+	LeechActionSuspend = "suspend" // also suspend it
+)
+
+var DeckSettingsTable = TableSchema{
+	Name: "deck_settings",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS deck_settings (
+        deck_id INT PRIMARY KEY,
+        new_per_day INT NOT NULL,
+        reviews_per_day INT NOT NULL,
+        learning_steps INT[] NOT NULL,
+        graduating_interval INT NOT NULL,
+        maximum_interval INT NOT NULL,
+        FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE CASCADE
+    );
+    ALTER TABLE deck_settings ADD COLUMN IF NOT EXISTS relearning_steps INT[] NOT NULL DEFAULT '{10}';
+    ALTER TABLE deck_settings ADD COLUMN IF NOT EXISTS leech_threshold INT NOT NULL DEFAULT 8;
+    ALTER TABLE deck_settings ADD COLUMN IF NOT EXISTS leech_action TEXT NOT NULL DEFAULT 'tag';`,
+}
+
+// DefaultDeckSettings returns the settings used by decks that have never
+// had theirs changed.
+func DefaultDeckSettings(deckID int) DeckSettings {
+	return DeckSettings{
+		DeckID:             deckID,
+		NewPerDay:          20,
+		ReviewsPerDay:      200,
+		LearningSteps:      []int{1, 10},
+		GraduatingInterval: 1,
+		MaximumInterval:    36500,
+		RelearningSteps:    []int{10},
+		LeechThreshold:     8,
+		LeechAction:        LeechActionTag,
+	}
+}
+
+var ErrInvalidDeckSettings = errors.New("invalid deck settings")
+
+func (s DeckSettings) Validate() error {
+	if s.NewPerDay < 0 || s.ReviewsPerDay < 0 {
+		return fmt.Errorf("%w: daily limits cannot be negative", ErrInvalidDeckSettings)
+	}
+	for _, step := range slices.Concat(s.LearningSteps, s.RelearningSteps) {
+		if step <= 0 {
+			return fmt.Errorf("%w: learning steps must be positive", ErrInvalidDeckSettings)
+		}
+	}
+	if s.GraduatingInterval < 1 || s.MaximumInterval < 1 {
+		return fmt.Errorf("%w: intervals must be at least one day", ErrInvalidDeckSettings)
+	}
+	if s.GraduatingInterval > s.MaximumInterval {
+		return fmt.Errorf("%w: graduating interval exceeds maximum interval", ErrInvalidDeckSettings)
+	}
+	if s.LeechThreshold < 0 {
+		return fmt.Errorf("%w: leech threshold cannot be negative", ErrInvalidDeckSettings)
+	}
+	if s.LeechAction != LeechActionTag && s.LeechAction != LeechActionSuspend {
+		return fmt.Errorf("%w: unknown leech action %q", ErrInvalidDeckSettings, s.LeechAction)
+	}
+	return nil
+}
+
+// GetDeckSettings returns a deck's settings, falling back to the defaults
+// if they were never saved.
+func GetDeckSettings(db *sql.DB, deckID int) (DeckSettings, error) {
+	settings := DeckSettings{DeckID: deckID}
+	var steps, relearningSteps pq.Int64Array
+	err := db.QueryRow(`SELECT new_per_day, reviews_per_day, learning_steps, graduating_interval, maximum_interval,
+            relearning_steps, leech_threshold, leech_action
+        FROM deck_settings WHERE deck_id = $1`, deckID).
+		Scan(&settings.NewPerDay, &settings.ReviewsPerDay, &steps, &settings.GraduatingInterval, &settings.MaximumInterval,
+			&relearningSteps, &settings.LeechThreshold, &settings.LeechAction)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultDeckSettings(deckID), nil
+	} else if err != nil {
+		return DeckSettings{}, fmt.Errorf("error getting settings for deck %d: %w", deckID, err)
+	}
+
+	settings.LearningSteps = fromInt64Array(steps)
+	settings.RelearningSteps = fromInt64Array(relearningSteps)
+
+	return settings, nil
+}
+
+func toInt64Array(values []int) pq.Int64Array {
+	array := make(pq.Int64Array, len(values))
+	for i, value := range values {
+		array[i] = int64(value)
+	}
+	return array
+}
+
+func fromInt64Array(array pq.Int64Array) []int {
+	values := make([]int, len(array))
+	for i, value := range array {
+		values[i] = int(value)
+	}
+	return values
+}
+
+func SaveDeckSettings(db *sql.DB, settings DeckSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	return saveDeckSettings(db, settings)
+}
+
+func saveDeckSettings(db execer, settings DeckSettings) error {
+	_, err := db.Exec(`INSERT INTO deck_settings (deck_id, new_per_day, reviews_per_day, learning_steps, graduating_interval,
+            maximum_interval, relearning_steps, leech_threshold, leech_action)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (deck_id) DO UPDATE SET
+            new_per_day = EXCLUDED.new_per_day,
+            reviews_per_day = EXCLUDED.reviews_per_day,
+            learning_steps = EXCLUDED.learning_steps,
+            graduating_interval = EXCLUDED.graduating_interval,
+            maximum_interval = EXCLUDED.maximum_interval,
+            relearning_steps = EXCLUDED.relearning_steps,
+            leech_threshold = EXCLUDED.leech_threshold,
+            leech_action = EXCLUDED.leech_action`,
+		settings.DeckID, settings.NewPerDay, settings.ReviewsPerDay, toInt64Array(settings.LearningSteps), settings.GraduatingInterval,
+		settings.MaximumInterval, toInt64Array(settings.RelearningSteps), settings.LeechThreshold, settings.LeechAction)
+	if err != nil {
+		return fmt.Errorf("error saving settings for deck %d: %w", settings.DeckID, err)
+	}
+
+	return nil
+}
+
+// Scheduler wraps base so that new cards go through the deck's learning
+// steps and failed review cards through its relearning steps before being
+// handed to base, and intervals never exceed the deck's maximum.
+func (s DeckSettings) Scheduler(base Scheduler) Scheduler {
+	return deckScheduler{settings: s, base: base}
+}
+
+type deckScheduler struct {
+	settings DeckSettings
+	base     Scheduler
+}
+
+func (d deckScheduler) Schedule(state MemoryState, rating int, now time.Time) (MemoryState, time.Time, error) {
+	if err := validateRating(rating); err != nil {
+		return state, time.Time{}, err
+	}
+
+	switch state.State {
+	case CardStateSuspended:
+		return state, time.Time{}, ErrCardSuspended
+
+	case CardStateReview:
+		// The base scheduler still sees the lapse so it can adjust the
+		// card's memory state, but the card then goes back through the
+		// relearning steps instead of straight to its next interval.
+		state, due, err := d.base.Schedule(state, rating, now)
+		if err != nil {
+			return state, due, err
+		}
+		if rating >= 3 {
+			return d.capInterval(state, due, now)
+		}
+		state.Lapses++
+		if len(d.settings.RelearningSteps) == 0 {
+			return d.capInterval(state, due, now)
+		}
+		state.State = CardStateRelearning
+		state.Step = 0
+		state.Interval = 0
+		return state, now.Add(time.Duration(d.settings.RelearningSteps[0]) * time.Minute), nil
+
+	case CardStateRelearning:
+		return d.step(state, d.settings.RelearningSteps, rating, now, 0)
+
+	default:
+		// New cards and cards still on sub-day intervals are learning.
+		return d.step(state, d.settings.LearningSteps, rating, now, d.settings.GraduatingInterval)
+	}
+}
+
+// step moves a (re)learning card through steps. Past the last step the card
+// graduates to review with graduatingInterval, or with the base scheduler's
+// interval if that is 0, and the base scheduler takes over from here on.
+func (d deckScheduler) step(state MemoryState, steps []int, rating int, now time.Time, graduatingInterval int) (MemoryState, time.Time, error) {
+	if rating < 3 {
+		state.Step = 0
+	} else if len(steps) > 0 {
+		state.Step++
+	}
+	if state.Step < len(steps) {
+		state.State = learningState(state)
+		state.Interval = 0
+		state.LastReview = &now
+		return state, now.Add(time.Duration(steps[state.Step]) * time.Minute), nil
+	}
+
+	state, _, err := d.base.Schedule(state, rating, now)
+	if err != nil {
+		return state, time.Time{}, err
+	}
+	state.State = CardStateReview
+	state.Step = 0
+	if graduatingInterval > 0 {
+		state.Interval = graduatingInterval
+	} else {
+		state.Interval = max(state.Interval, 1)
+	}
+	return d.capInterval(state, now.AddDate(0, 0, state.Interval), now)
+}
+
+func learningState(state MemoryState) string {
+	if state.State == CardStateRelearning {
+		return CardStateRelearning
+	}
+	return CardStateLearning
+}
+
+func (d deckScheduler) capInterval(state MemoryState, due time.Time, now time.Time) (MemoryState, time.Time, error) {
+	if state.Interval > d.settings.MaximumInterval {
+		state.Interval = d.settings.MaximumInterval
+		due = now.AddDate(0, 0, state.Interval)
+	}
+	return state, due, nil
+}
+
+// CountStudiedToday returns how many new cards were introduced and how many
+// review cards were studied in a deck and the decks nested in it since the
+// start of the day.
+func CountStudiedToday(db *sql.DB, deckID int, since time.Time) (int, int, error) {
+	var newCards, reviews int
+	err := db.QueryRow(`
+        SELECT
+            COUNT(*) FILTER (WHERE first_review),
+            COUNT(*) FILTER (WHERE NOT first_review AND prev_interval > 0)
+        FROM (
+            SELECT r.prev_interval,
+                NOT EXISTS (SELECT 1 FROM review_log p WHERE p.card_id = r.card_id AND p.id < r.id) AS first_review
+            FROM review_log r
+            WHERE r.deck_id IN (`+deckSubtree("$1")+`) AND r.reviewed_at >= $2
+        ) today
+    `, deckID, since).Scan(&newCards, &reviews)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error counting today's reviews for deck %d: %w", deckID, err)
+	}
+
+	return newCards, reviews, nil
+}
+
+// GetDueLimits works out how many more new and review cards a deck may
+// show today given its daily limits.
+func GetDueLimits(db *sql.DB, deckID int, now time.Time) (DueLimits, error) {
+	settings, err := GetDeckSettings(db, deckID)
+	if err != nil {
+		return DueLimits{}, err
+	}
+
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	newCards, reviews, err := CountStudiedToday(db, deckID, startOfDay)
+	if err != nil {
+		return DueLimits{}, err
+	}
+
+	return DueLimits{
+		New:      max(settings.NewPerDay-newCards, 0),
+		Learning: DefaultDueLimits.Learning,
+		Review:   max(settings.ReviewsPerDay-reviews, 0),
+	}, nil
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var deckSettingsColumns = []string{"new_per_day", "reviews_per_day", "learning_steps", "graduating_interval", "maximum_interval", "relearning_steps", "leech_threshold", "leech_action"}
+
+func TestDeckSettingsValidate(t *testing.T) {
+	t.Run("Defaults are valid", func(t *testing.T) {
+		assert.NoError(t, DefaultDeckSettings(1).Validate())
+	})
+
+	invalid := map[string]func(*DeckSettings){
+		"Negative new limit":    func(s *DeckSettings) { s.NewPerDay = -1 },
+		"Negative review limit": func(s *DeckSettings) { s.ReviewsPerDay = -1 },
+		"Zero learning step":    func(s *DeckSettings) { s.LearningSteps = []int{1, 0} },
+		"Zero graduating":       func(s *DeckSettings) { s.GraduatingInterval = 0 },
+		"Graduating over max":   func(s *DeckSettings) { s.GraduatingInterval = 10; s.MaximumInterval = 5 },
+		"Zero relearning step":  func(s *DeckSettings) { s.RelearningSteps = []int{0} },
+		"Negative leech":        func(s *DeckSettings) { s.LeechThreshold = -1 },
+		"Unknown leech action":  func(s *DeckSettings) { s.LeechAction = "delete" },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			settings := DefaultDeckSettings(1)
+			mutate(&settings)
+			assert.ErrorIs(t, settings.Validate(), ErrInvalidDeckSettings)
+		})
+	}
+}
+
+func TestGetDeckSettings(t *testing.T) {
+	t.Run("Saved settings", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT new_per_day, reviews_per_day, learning_steps, graduating_interval, maximum_interval").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows(deckSettingsColumns).AddRow(5, 50, "{1,10,60}", 2, 180, "{5,20}", 4, LeechActionSuspend))
+
+		settings, err := GetDeckSettings(db, 4)
+		assert.NoError(t, err)
+		assert.Equal(t, DeckSettings{
+			DeckID:             4,
+			NewPerDay:          5,
+			ReviewsPerDay:      50,
+			LearningSteps:      []int{1, 10, 60},
+			GraduatingInterval: 2,
+			MaximumInterval:    180,
+			RelearningSteps:    []int{5, 20},
+			LeechThreshold:     4,
+			LeechAction:        LeechActionSuspend,
+		}, settings)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(4).WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+
+		settings, err := GetDeckSettings(db, 4)
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultDeckSettings(4), settings)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("QueryError", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT new_per_day").WillReturnError(fmt.Errorf("query error"))
+
+		_, err = GetDeckSettings(db, 4)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSaveDeckSettings(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("INSERT INTO deck_settings").
+			WithArgs(4, 5, 50, pq.Int64Array{1, 10}, 2, 180, pq.Int64Array{}, 0, LeechActionTag).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err = SaveDeckSettings(db, DeckSettings{DeckID: 4, NewPerDay: 5, ReviewsPerDay: 50, LearningSteps: []int{1, 10}, GraduatingInterval: 2, MaximumInterval: 180, LeechAction: LeechActionTag})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		err = SaveDeckSettings(db, DeckSettings{DeckID: 4})
+		assert.ErrorIs(t, err, ErrInvalidDeckSettings)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("INSERT INTO deck_settings").WillReturnError(fmt.Errorf("insert error"))
+
+		err = SaveDeckSettings(db, DefaultDeckSettings(4))
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeckScheduler(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	settings := DeckSettings{LearningSteps: []int{1, 10}, GraduatingInterval: 2, MaximumInterval: 30, RelearningSteps: []int{5}}
+	scheduler := settings.Scheduler(SM2{})
+
+	t.Run("New card enters the learning steps", func(t *testing.T) {
+		state, due, err := scheduler.Schedule(MemoryState{EaseFactor: DefaultEaseFactor}, 4, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, state.Step)
+		assert.Equal(t, 0, state.Interval)
+		assert.Equal(t, CardStateLearning, state.State)
+		assert.Equal(t, now.Add(10*time.Minute), due)
+	})
+
+	t.Run("Failing a step starts over", func(t *testing.T) {
+		state, due, err := scheduler.Schedule(MemoryState{EaseFactor: DefaultEaseFactor, Step: 1, LastReview: &now, State: CardStateLearning}, 2, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, state.Step)
+		assert.Equal(t, now.Add(time.Minute), due)
+	})
+
+	t.Run("Passing the last step graduates", func(t *testing.T) {
+		state, due, err := scheduler.Schedule(MemoryState{EaseFactor: DefaultEaseFactor, Step: 1, LastReview: &now, State: CardStateLearning}, 4, now)
+		assert.NoError(t, err)
+		assert.Equal(t, CardStateReview, state.State)
+		assert.Equal(t, 0, state.Step)
+		assert.Equal(t, 2, state.Interval)
+		assert.Equal(t, 1, state.Repetitions)
+		assert.Equal(t, now.AddDate(0, 0, 2), due)
+	})
+
+	t.Run("Review cards are capped at the maximum interval", func(t *testing.T) {
+		state, due, err := scheduler.Schedule(MemoryState{EaseFactor: DefaultEaseFactor, Interval: 20, Repetitions: 4, LastReview: &now, State: CardStateReview}, 5, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 30, state.Interval)
+		assert.Equal(t, now.AddDate(0, 0, 30), due)
+	})
+
+	t.Run("Failed review card enters relearning", func(t *testing.T) {
+		state, due, err := scheduler.Schedule(MemoryState{EaseFactor: DefaultEaseFactor, Interval: 20, Repetitions: 4, LastReview: &now, State: CardStateReview, Lapses: 1}, 1, now)
+		assert.NoError(t, err)
+		assert.Equal(t, CardStateRelearning, state.State)
+		assert.Equal(t, 2, state.Lapses)
+		assert.Equal(t, 0, state.Interval)
+		assert.Equal(t, 0, state.Repetitions)
+		assert.InDelta(t, 1.96, state.EaseFactor, 1e-9)
+		assert.Equal(t, now.Add(5*time.Minute), due)
+	})
+
+	t.Run("Passing relearning returns to review", func(t *testing.T) {
+		state, due, err := scheduler.Schedule(MemoryState{EaseFactor: 1.96, LastReview: &now, State: CardStateRelearning, Lapses: 2}, 4, now)
+		assert.NoError(t, err)
+		assert.Equal(t, CardStateReview, state.State)
+		assert.Equal(t, 1, state.Interval)
+		assert.Equal(t, 2, state.Lapses)
+		assert.Equal(t, now.AddDate(0, 0, 1), due)
+	})
+
+	t.Run("Failing without relearning steps", func(t *testing.T) {
+		state, due, err := DeckSettings{GraduatingInterval: 1, MaximumInterval: 100}.Scheduler(SM2{}).
+			Schedule(MemoryState{EaseFactor: DefaultEaseFactor, Interval: 20, Repetitions: 4, LastReview: &now, State: CardStateReview}, 2, now)
+		assert.NoError(t, err)
+		assert.Equal(t, CardStateReview, state.State)
+		assert.Equal(t, 1, state.Lapses)
+		assert.Equal(t, 1, state.Interval)
+		assert.Equal(t, now.AddDate(0, 0, 1), due)
+	})
+
+	t.Run("Suspended cards cannot be reviewed", func(t *testing.T) {
+		_, _, err := scheduler.Schedule(MemoryState{State: CardStateSuspended}, 4, now)
+		assert.ErrorIs(t, err, ErrCardSuspended)
+	})
+
+	t.Run("No learning steps", func(t *testing.T) {
+		state, _, err := DeckSettings{GraduatingInterval: 1, MaximumInterval: 100}.Scheduler(SM2{}).
+			Schedule(MemoryState{EaseFactor: DefaultEaseFactor}, 4, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, state.Interval)
+		assert.Equal(t, 1, state.Repetitions)
+	})
+
+	t.Run("Rating out of range", func(t *testing.T) {
+		_, _, err := scheduler.Schedule(MemoryState{}, 9, now)
+		assert.Error(t, err)
+	})
+}
+
+func TestGetDueLimits(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 15, 30, 0, 0, time.UTC)
+
+	t.Run("Subtracts today's reviews", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(4).
+			WillReturnRows(sqlmock.NewRows(deckSettingsColumns).AddRow(10, 100, "{1}", 1, 365, "{10}", 8, LeechActionTag))
+		mock.ExpectQuery("(?s)FROM review_log r.*WHERE r.deck_id IN \\(WITH RECURSIVE subtree").
+			WithArgs(4, time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)).
+			WillReturnRows(sqlmock.NewRows([]string{"new", "reviews"}).AddRow(4, 120))
+
+		limits, err := GetDueLimits(db, 4, now)
+		assert.NoError(t, err)
+		assert.Equal(t, DueLimits{New: 6, Learning: DefaultDueLimits.Learning, Review: 0}, limits)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("CountError", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT new_per_day").WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+		mock.ExpectQuery("FROM review_log r").WillReturnError(fmt.Errorf("query error"))
+
+		_, err = GetDueLimits(db, 4, now)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidDeck = errors.New("invalid deck")
+
+const (
+	maxDeckIconLength        = 32
+	maxDeckDescriptionLength = 2000
+)
+
+var deckColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Validate checks the parts of a deck that can be edited.
+func (d Deck) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("%w: name is empty", ErrInvalidDeck)
+	}
+	if strings.Contains(d.Name, DeckPathSeparator) {
+		return fmt.Errorf("%w: name cannot contain %q, nest the deck instead", ErrInvalidDeck, DeckPathSeparator)
+	}
+	if d.Color != "" && !deckColorPattern.MatchString(d.Color) {
+		return fmt.Errorf("%w: colour %q is not like #1e90ff", ErrInvalidDeck, d.Color)
+	}
+	if len(d.Icon) > maxDeckIconLength {
+		return fmt.Errorf("%w: icon is longer than %d bytes", ErrInvalidDeck, maxDeckIconLength)
+	}
+	if len(d.Description) > maxDeckDescriptionLength {
+		return fmt.Errorf("%w: description is longer than %d bytes", ErrInvalidDeck, maxDeckDescriptionLength)
+	}
+	return nil
+}
+
+// UpdateDeck saves a deck's name, description, colour, icon and archived
+// flag, returning the updated deck. Use SetDeckScheduler and MoveDeck to
+// change the rest.
+func UpdateDeck(db *sql.DB, deck Deck) (*Deck, error) {
+	if err := deck.Validate(); err != nil {
+		return nil, err
+	}
+
+	var updated Deck
+	err := scanDeck(db.QueryRow(`UPDATE decks SET name = $1, description = $2, color = $3, icon = $4, archived = $5, updated_at = NOW()
+        WHERE id = $6 RETURNING `+deckColumns, deck.Name, deck.Description, deck.Color, deck.Icon, deck.Archived, deck.ID), &updated)
+	if err != nil {
+		return nil, fmt.Errorf("error updating deck %d: %w", deck.ID, err)
+	}
+
+	return &updated, nil
+}
+
+// DeckOption changes which decks GetDecksData returns.
+type DeckOption func(*deckOptions)
+
+type deckOptions struct {
+	includeArchived bool
+}
+
+// IncludeArchived returns archived decks along with the rest.
+func IncludeArchived() DeckOption {
+	return func(opts *deckOptions) {
+		opts.includeArchived = true
+	}
+}
+
+// withoutArchived leaves archived decks out of a deck tree, along with the
+// decks nested in them.
+func withoutArchived(decks []Deck) []Deck {
+	kept := []Deck{}
+	for _, deck := range decks {
+		if deck.Archived {
+			continue
+		}
+		if deck.Children != nil {
+			deck.Children = withoutArchived(deck.Children)
+			if len(deck.Children) == 0 {
+				deck.Children = nil
+			}
+		}
+		kept = append(kept, deck)
+	}
+	return kept
+}
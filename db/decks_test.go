@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeckValidate(t *testing.T) {
+	assert.NoError(t, Deck{Name: "Spanish", Color: "#C60B1E", Icon: "🇪🇸"}.Validate())
+
+	for _, deck := range []Deck{
+		{Name: " "},
+		{Name: "Go::Concurrency"},
+		{Name: "Spanish", Color: "red"},
+		{Name: "Spanish", Icon: strings.Repeat("x", maxDeckIconLength+1)},
+		{Name: "Spanish", Description: strings.Repeat("x", maxDeckDescriptionLength+1)},
+	} {
+		assert.ErrorIs(t, deck.Validate(), ErrInvalidDeck, deck)
+	}
+}
+
+func TestUpdateDeck(t *testing.T) {
+	query := regexp.QuoteMeta("UPDATE decks SET name = $1, description = $2, color = $3, icon = $4, archived = $5, updated_at = NOW()")
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		deck := Deck{ID: 1, Name: "Español", Scheduler: SchedulerSM2, Description: "Everyday words", Color: "#c60b1e", Archived: true, CreatedAt: due, UpdatedAt: due}
+		mock.ExpectQuery(query).WithArgs("Español", "Everyday words", "#c60b1e", "", true, 1).WillReturnRows(deckRows(deck))
+
+		updated, err := UpdateDeck(db, deck)
+		assert.NoError(t, err)
+		assert.Equal(t, deck, *updated)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		_, err = UpdateDeck(db, Deck{ID: 1, Name: ""})
+		assert.ErrorIs(t, err, ErrInvalidDeck)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(query).WillReturnError(sql.ErrNoRows)
+
+		_, err = UpdateDeck(db, Deck{ID: 9, Name: "Spanish"})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetDecksDataArchived(t *testing.T) {
+	goID := 1
+	decks := []Deck{
+		{ID: 1, Name: "Go", Scheduler: SchedulerSM2, CreatedAt: due, UpdatedAt: due},
+		{ID: 3, Name: "Old", Scheduler: SchedulerSM2, ParentID: &goID, Archived: true, CreatedAt: due, UpdatedAt: due},
+		{ID: 2, Name: "Spanish", Scheduler: SchedulerSM2, Archived: true, CreatedAt: due, UpdatedAt: due},
+	}
+
+	t.Run("Hidden", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).WillReturnRows(deckRows(decks...))
+
+		got, err := GetDecksData(db)
+		assert.NoError(t, err)
+		assert.Equal(t, []Deck{decks[0]}, *got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Included", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).WillReturnRows(deckRows(decks...))
+
+		got, err := GetDecksData(db, IncludeArchived())
+		assert.NoError(t, err)
+		goDeck := decks[0]
+		goDeck.Children = []Deck{decks[1]}
+		assert.Equal(t, []Deck{goDeck, decks[2]}, *got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DueLimits caps how many cards of each kind a due queue may contain.
+type DueLimits struct {
+	New      int `json:"new"`
+	Learning int `json:"learning"`
+	Review   int `json:"review"`
+}
+
+var DefaultDueLimits = DueLimits{New: 20, Learning: 100, Review: 200}
+
+// inDeckSubtree selects the cards in deck $1 or in a deck nested in it,
+// once each.
+var inDeckSubtree = "c.id IN (SELECT dc.card_id FROM deck_cards dc WHERE dc.deck_id IN (" + deckSubtree("$1") + "))"
+
+// Relearning cards share the learning limit, and suspended and buried cards
+// are never due.
+var dueCardsQuery = `
+    SELECT ` + cardColumns + ` FROM (
+        (SELECT c.* FROM cards c
+            WHERE ` + inDeckSubtree + ` AND c.due <= $2 AND (c.buried_until IS NULL OR c.buried_until <= $2) AND c.state = 'new'
+            ORDER BY c.due, c.id LIMIT $3)
+        UNION ALL
+        (SELECT c.* FROM cards c
+            WHERE ` + inDeckSubtree + ` AND c.due <= $2 AND (c.buried_until IS NULL OR c.buried_until <= $2) AND c.state IN ('learning', 'relearning')
+            ORDER BY c.due, c.id LIMIT $4)
+        UNION ALL
+        (SELECT c.* FROM cards c
+            WHERE ` + inDeckSubtree + ` AND c.due <= $2 AND (c.buried_until IS NULL OR c.buried_until <= $2) AND c.state = 'review'
+            ORDER BY c.due, c.id LIMIT $5)
+    ) due_cards
+    ORDER BY due, id`
+
+// GetDueCards returns the cards in a deck and the decks nested in it that
+// are due at time now, ordered by due time, with at most limits.New new
+// cards, limits.Learning learning cards and limits.Review review cards. Only
+// one card from each note is returned.
+func GetDueCards(db *sql.DB, deckID int, limits DueLimits, now time.Time) (*[]Card, error) {
+	rows, err := db.Query(dueCardsQuery, deckID, now, limits.New, limits.Learning, limits.Review)
+	if err != nil {
+		return nil, fmt.Errorf("error getting due cards for deck: %v", err)
+	}
+	defer rows.Close()
+
+	cards := []Card{}
+
+	for rows.Next() {
+		var card Card
+		if err := scanCard(rows, &card); err != nil {
+			return nil, fmt.Errorf("error scanning card: %v", err)
+		}
+		cards = append(cards, card)
+	}
+
+	// Only the first of any siblings that are due is studied today; the
+	// rest are buried once it has been reviewed.
+	cards = withoutSiblings(cards)
+
+	return &cards, nil
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDueCards(t *testing.T) {
+	now := time.Date(2024, time.May, 2, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta(dueCardsQuery)
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		lastReview := due.AddDate(0, 0, -6)
+		expectedCards := []Card{
+			{ID: 2, Front: "Front 2", Back: "Back 2", MemoryState: MemoryState{EaseFactor: 2.5, Interval: 6, Repetitions: 2, LastReview: &lastReview}, Due: due},
+			{ID: 1, Front: "Front 1", Back: "Back 1", MemoryState: MemoryState{EaseFactor: 2.5}, Due: due.Add(time.Hour)},
+		}
+		mock.ExpectQuery(query).WithArgs(5, now, 20, 100, 200).WillReturnRows(cardRows(expectedCards...))
+
+		cards, err := GetDueCards(db, 5, DefaultDueLimits, now)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedCards, *cards)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Custom limits", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(query).WithArgs(5, now, 0, 10, 50).WillReturnRows(cardRows())
+
+		cards, err := GetDueCards(db, 5, DueLimits{New: 0, Learning: 10, Review: 50}, now)
+		assert.NoError(t, err)
+		assert.Empty(t, *cards)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("QueryError", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(query).WillReturnError(fmt.Errorf("query error"))
+
+		cards, err := GetDueCards(db, 5, DefaultDueLimits, now)
+		assert.Nil(t, cards)
+		assert.EqualError(t, err, "error getting due cards for deck: query error")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Where to look for cards that a card duplicates.
+const (
+	DuplicatesInDeck       = "deck" // the default
+	DuplicatesInCollection = "collection"
+)
+
+// What to do with a card that duplicates a saved card.
+const (
+	OnDuplicateReject = "reject" // fail with a DuplicateCardError
+	OnDuplicateSkip   = "skip"   // keep the saved card as it is
+	OnDuplicateUpdate = "update" // save the card's content to the saved card
+	OnDuplicateAllow  = "allow"  // add the card anyway
+)
+
+var ErrInvalidDuplicates = errors.New("invalid duplicate handling")
+
+// A DuplicateCardError reports the saved card that a new card duplicates.
+type DuplicateCardError struct {
+	Card Card
+}
+
+func (e *DuplicateCardError) Error() string {
+	return fmt.Sprintf("duplicate card: card %d has the same front", e.Card.ID)
+}
+
+// A DuplicatePolicy says where to look for cards that a new card duplicates,
+// and what to do when it does. Cards are duplicates when their fronts are
+// the same once normalised, see card_front_key in CardsTable.
+type DuplicatePolicy struct {
+	Scope       string `json:"duplicateScope"` // DuplicatesInDeck or DuplicatesInCollection
+	OnDuplicate string `json:"onDuplicate"`    // OnDuplicateReject, OnDuplicateSkip, OnDuplicateUpdate or OnDuplicateAllow
+}
+
+// Validate reports whether a policy's scope and action are known. Either
+// may be empty to use the default.
+func (p DuplicatePolicy) Validate() error {
+	switch p.Scope {
+	case "", DuplicatesInDeck, DuplicatesInCollection:
+	default:
+		return fmt.Errorf("%w: unknown scope %q", ErrInvalidDuplicates, p.Scope)
+	}
+	switch p.OnDuplicate {
+	case "", OnDuplicateReject, OnDuplicateSkip, OnDuplicateUpdate, OnDuplicateAllow:
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidDuplicates, p.OnDuplicate)
+	}
+	return nil
+}
+
+// DuplicateGroup is a set of cards whose fronts are the same once
+// normalised, along with that normalised front.
+type DuplicateGroup struct {
+	Front string `json:"front"`
+	Cards []Card `json:"cards"`
+}
+
+// findDuplicate returns the saved card, with the lowest ID, whose front is
+// the same as front once normalised, or nil if there is none. Only cards in
+// the deck are looked at unless scope is DuplicatesInCollection.
+func findDuplicate(db queryRower, front string, deckID int, scope string) (*Card, error) {
+	query := "SELECT " + cardColumns + " FROM cards WHERE card_front_key(front) = card_front_key($1)"
+	args := []any{front}
+	if scope != DuplicatesInCollection {
+		query += " AND id IN (SELECT card_id FROM deck_cards WHERE deck_id = $2)"
+		args = append(args, deckID)
+	}
+
+	var card Card
+	err := scanCard(db.QueryRow(query+" ORDER BY id LIMIT 1", args...), &card)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error looking for duplicates of %q: %w", front, err)
+	}
+	return &card, nil
+}
+
+// GetDuplicateCards returns the cards in a deck that duplicate each other,
+// grouped by their normalised front. With DuplicatesInCollection, cards in
+// the deck are grouped with their duplicates in any deck. Cards on the same
+// note, such as the cards for one cloze text, are not duplicates of each
+// other.
+func GetDuplicateCards(db *sql.DB, deckID int, scope string) (*[]DuplicateGroup, error) {
+	if err := (DuplicatePolicy{Scope: scope}).Validate(); err != nil {
+		return nil, err
+	}
+
+	inDeck := "id IN (SELECT card_id FROM deck_cards WHERE deck_id = $1)"
+	candidates, cards := " WHERE "+inDeck, inDeck
+	if scope == DuplicatesInCollection {
+		candidates, cards = "", "card_front_key(front) IN (SELECT card_front_key(front) FROM cards WHERE "+inDeck+")"
+	}
+	rows, err := db.Query(`SELECT `+cardColumns+`, card_front_key(front) FROM cards WHERE card_front_key(front) IN (
+            SELECT card_front_key(front) FROM cards`+candidates+`
+            GROUP BY 1 HAVING COUNT(DISTINCT COALESCE(note_id, -id)) > 1
+        ) AND `+cards+` ORDER BY card_front_key(front), id`, deckID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting duplicate cards for deck %d: %v", deckID, err)
+	}
+	defer rows.Close()
+
+	groups := []DuplicateGroup{}
+	for rows.Next() {
+		var card Card
+		var front string
+		if err := scanCard(extraColumns{rows, []any{&front}}, &card); err != nil {
+			return nil, fmt.Errorf("error scanning card: %v", err)
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Front != front {
+			groups = append(groups, DuplicateGroup{Front: front})
+		}
+		groups[len(groups)-1].Cards = append(groups[len(groups)-1].Cards, card)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting duplicate cards for deck %d: %v", deckID, err)
+	}
+
+	return &groups, nil
+}
+
+// updateDuplicate saves a new card's front and back to the saved card it
+// duplicates, and adds the saved card to the new card's deck.
+func updateDuplicate(tx *sql.Tx, duplicate Card, card Card, deckID int) error {
+	duplicate.Front, duplicate.Back = card.Front, card.Back
+	if err := updateCard(tx, duplicate); err != nil {
+		return err
+	}
+	return addCardToDeck(tx, duplicate.ID, deckID)
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectDuplicate expects a look for cards in a deck that duplicate front,
+// finding cards.
+func expectDuplicate(mock sqlmock.Sqlmock, front string, deckID int, cards ...Card) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+cardColumns+" FROM cards WHERE card_front_key(front) = card_front_key($1) AND id IN")).
+		WithArgs(front, deckID).WillReturnRows(cardRows(cards...))
+}
+
+func TestDuplicatePolicyValidate(t *testing.T) {
+	assert.NoError(t, DuplicatePolicy{}.Validate())
+	assert.NoError(t, DuplicatePolicy{Scope: DuplicatesInCollection, OnDuplicate: OnDuplicateUpdate}.Validate())
+	assert.ErrorIs(t, DuplicatePolicy{Scope: "everywhere"}.Validate(), ErrInvalidDuplicates)
+	assert.ErrorIs(t, DuplicatePolicy{OnDuplicate: "merge"}.Validate(), ErrInvalidDuplicates)
+}
+
+func TestFindDuplicate(t *testing.T) {
+	t.Run("In deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		card := Card{ID: 5, Type: CardTypeBasic, Front: "¿Hablar?", Back: "to speak", Tags: []string{}, Due: due}
+		expectDuplicate(mock, "hablar", 1, card)
+
+		duplicate, err := findDuplicate(db, "hablar", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, &card, duplicate)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("In collection", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards WHERE card_front_key(front) = card_front_key($1) ORDER BY id LIMIT 1")).
+			WithArgs("hablar").WillReturnRows(cardRows())
+
+		duplicate, err := findDuplicate(db, "hablar", 1, DuplicatesInCollection)
+		assert.NoError(t, err)
+		assert.Nil(t, duplicate)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT .* FROM cards").WillReturnError(fmt.Errorf("connection lost"))
+
+		_, err = findDuplicate(db, "hablar", 1, "")
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetDuplicateCards(t *testing.T) {
+	// duplicateRows builds mocked rows of cards followed by their
+	// normalised front.
+	duplicateRows := func(fronts []string, cards ...Card) *sqlmock.Rows {
+		rows := sqlmock.NewRows(slices.Concat(cardRowColumns, []string{"card_front_key"}))
+		for i, card := range cards {
+			tags, _ := pq.StringArray(card.Tags).Value()
+			rows.AddRow([]driver.Value{card.ID, card.Type, card.Front, card.Back, card.Cloze, card.EaseFactor, card.Interval, card.Repetitions,
+				card.Stability, card.Difficulty, card.LastReview, card.Step, card.State, card.Lapses, card.Leech, card.BuriedUntil, card.NoteID, card.Template, card.Due, tags, fronts[i]}...)
+		}
+		return rows
+	}
+	hablar := Card{ID: 5, Type: CardTypeBasic, Front: "Hablar", Back: "to speak", Tags: []string{}, Due: due}
+	hablar2 := Card{ID: 9, Type: CardTypeBasic, Front: "hablar!", Back: "to talk", Tags: []string{}, Due: due}
+	comer := Card{ID: 6, Type: CardTypeBasic, Front: "comer", Back: "to eat", Tags: []string{}, Due: due}
+	comer2 := Card{ID: 7, Type: CardTypeBasic, Front: "Comer", Back: "to have lunch", Tags: []string{}, Due: due}
+
+	t.Run("In deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT .* FROM cards WHERE card_front_key\\(front\\) IN \\(\\s+SELECT card_front_key\\(front\\) FROM cards WHERE id IN").
+			WithArgs(1).WillReturnRows(duplicateRows([]string{"comer", "comer", "hablar", "hablar"}, comer, comer2, hablar, hablar2))
+
+		groups, err := GetDuplicateCards(db, 1, DuplicatesInDeck)
+		assert.NoError(t, err)
+		assert.Equal(t, []DuplicateGroup{
+			{Front: "comer", Cards: []Card{comer, comer2}},
+			{Front: "hablar", Cards: []Card{hablar, hablar2}},
+		}, *groups)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("In collection", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT .* FROM cards WHERE card_front_key\\(front\\) IN \\(\\s+SELECT card_front_key\\(front\\) FROM cards\\s+GROUP BY").
+			WithArgs(1).WillReturnRows(duplicateRows(nil))
+
+		groups, err := GetDuplicateCards(db, 1, DuplicatesInCollection)
+		assert.NoError(t, err)
+		assert.Empty(t, *groups)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown scope", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		_, err = GetDuplicateCards(db, 1, "everywhere")
+		assert.ErrorIs(t, err, ErrInvalidDuplicates)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
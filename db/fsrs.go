@@ -0,0 +1,136 @@
+package db
+
+import (
+	"math"
+	"time"
+)
+
+// FSRS grades. Ratings are mapped onto these before scheduling.
+const (
+	fsrsAgain = iota + 1
+	fsrsHard
+	fsrsGood
+	fsrsEasy
+)
+
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0 // 0.9^(1/fsrsDecay) - 1, so that R(S, S) = 0.9
+)
+
+// DefaultFSRSWeights are the published FSRS-4.5 default parameters.
+var DefaultFSRSWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031, 1.6474,
+	0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+// FSRS is the Free Spaced Repetition Scheduler (version 4.5). It models each
+// card with a stability (days until recall probability drops to the desired
+// retention) and a difficulty between 1 and 10.
+type FSRS struct {
+	Weights          [17]float64
+	DesiredRetention float64
+	MaximumInterval  int
+}
+
+func NewFSRS() FSRS {
+	return FSRS{
+		Weights:          DefaultFSRSWeights,
+		DesiredRetention: 0.9,
+		MaximumInterval:  36500,
+	}
+}
+
+// fsrsGrade maps a 1-5 rating onto the four FSRS grades. Ratings 1 and 2 are
+// both failed recalls.
+func fsrsGrade(rating int) int {
+	if rating <= 2 {
+		return fsrsAgain
+	}
+	return rating - 1
+}
+
+func (f FSRS) Schedule(state MemoryState, rating int, now time.Time) (MemoryState, time.Time, error) {
+	if err := validateRating(rating); err != nil {
+		return state, time.Time{}, err
+	}
+	grade := fsrsGrade(rating)
+
+	if state.Stability == 0 {
+		state.Stability = f.initStability(grade)
+		state.Difficulty = f.initDifficulty(grade)
+	} else {
+		elapsed := 0.0
+		if state.LastReview != nil {
+			elapsed = math.Max(0, math.Floor(now.Sub(*state.LastReview).Hours()/24))
+		}
+		r := f.retrievability(elapsed, state.Stability)
+
+		if grade == fsrsAgain {
+			state.Stability = f.forgetStability(state.Difficulty, state.Stability, r)
+		} else {
+			state.Stability = f.recallStability(state.Difficulty, state.Stability, r, grade)
+		}
+		state.Difficulty = f.nextDifficulty(state.Difficulty, grade)
+	}
+
+	if grade == fsrsAgain {
+		state.Repetitions = 0
+	} else {
+		state.Repetitions++
+	}
+	state.Interval = f.nextInterval(state.Stability)
+	state.LastReview = &now
+
+	return state, now.AddDate(0, 0, state.Interval), nil
+}
+
+func (f FSRS) initStability(grade int) float64 {
+	return math.Max(f.Weights[grade-1], 0.1)
+}
+
+func (f FSRS) initDifficulty(grade int) float64 {
+	return clampDifficulty(f.Weights[4] - float64(grade-3)*f.Weights[5])
+}
+
+func (f FSRS) nextDifficulty(d float64, grade int) float64 {
+	next := d - f.Weights[6]*float64(grade-3)
+	// Mean reversion towards the initial difficulty of a "Good" answer.
+	return clampDifficulty(f.Weights[7]*f.Weights[4] + (1-f.Weights[7])*next)
+}
+
+func (f FSRS) retrievability(elapsedDays, stability float64) float64 {
+	return math.Pow(1+fsrsFactor*elapsedDays/stability, fsrsDecay)
+}
+
+func (f FSRS) recallStability(d, s, r float64, grade int) float64 {
+	hardPenalty, easyBonus := 1.0, 1.0
+	if grade == fsrsHard {
+		hardPenalty = f.Weights[15]
+	} else if grade == fsrsEasy {
+		easyBonus = f.Weights[16]
+	}
+
+	return s * (1 + math.Exp(f.Weights[8])*
+		(11-d)*
+		math.Pow(s, -f.Weights[9])*
+		(math.Exp((1-r)*f.Weights[10])-1)*
+		hardPenalty*
+		easyBonus)
+}
+
+func (f FSRS) forgetStability(d, s, r float64) float64 {
+	return f.Weights[11] *
+		math.Pow(d, -f.Weights[12]) *
+		(math.Pow(s+1, f.Weights[13]) - 1) *
+		math.Exp((1-r)*f.Weights[14])
+}
+
+func (f FSRS) nextInterval(stability float64) int {
+	interval := stability / fsrsFactor * (math.Pow(f.DesiredRetention, 1/fsrsDecay) - 1)
+	return min(max(int(math.Round(interval)), 1), f.MaximumInterval)
+}
+
+func clampDifficulty(d float64) float64 {
+	return math.Min(math.Max(d, 1), 10)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFSRSSchedule(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	fsrs := NewFSRS()
+
+	t.Run("First review uses initial stability", func(t *testing.T) {
+		state, due, err := fsrs.Schedule(MemoryState{}, 4, now)
+		assert.NoError(t, err)
+		assert.InDelta(t, DefaultFSRSWeights[2], state.Stability, 1e-9)
+		assert.InDelta(t, DefaultFSRSWeights[4], state.Difficulty, 1e-9)
+		assert.Equal(t, 4, state.Interval)
+		assert.Equal(t, 1, state.Repetitions)
+		assert.Equal(t, now.AddDate(0, 0, 4), due)
+	})
+
+	t.Run("Easier first ratings start with more stability", func(t *testing.T) {
+		hard, _, _ := fsrs.Schedule(MemoryState{}, 3, now)
+		easy, _, _ := fsrs.Schedule(MemoryState{}, 5, now)
+		assert.Less(t, hard.Stability, easy.Stability)
+		assert.Greater(t, hard.Difficulty, easy.Difficulty)
+	})
+
+	t.Run("Successful review on time grows stability", func(t *testing.T) {
+		lastReview := now.AddDate(0, 0, -4)
+		prev := MemoryState{Stability: 4, Difficulty: 5, Interval: 4, Repetitions: 1, LastReview: &lastReview}
+
+		state, _, err := fsrs.Schedule(prev, 4, now)
+		assert.NoError(t, err)
+		assert.Greater(t, state.Stability, prev.Stability)
+		assert.Greater(t, state.Interval, prev.Interval)
+		assert.Equal(t, 2, state.Repetitions)
+	})
+
+	t.Run("Failed review shrinks stability", func(t *testing.T) {
+		lastReview := now.AddDate(0, 0, -20)
+		prev := MemoryState{Stability: 20, Difficulty: 5, Interval: 20, Repetitions: 3, LastReview: &lastReview}
+
+		state, _, err := fsrs.Schedule(prev, 1, now)
+		assert.NoError(t, err)
+		assert.Less(t, state.Stability, prev.Stability)
+		assert.Greater(t, state.Difficulty, prev.Difficulty)
+		assert.Equal(t, 0, state.Repetitions)
+		assert.GreaterOrEqual(t, state.Interval, 1)
+	})
+
+	t.Run("Interval is capped", func(t *testing.T) {
+		capped := NewFSRS()
+		capped.MaximumInterval = 10
+		lastReview := now.AddDate(0, 0, -100)
+		prev := MemoryState{Stability: 100, Difficulty: 1, LastReview: &lastReview}
+
+		state, _, err := capped.Schedule(prev, 5, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, state.Interval)
+	})
+
+	t.Run("Rating out of range", func(t *testing.T) {
+		_, _, err := fsrs.Schedule(MemoryState{}, 0, now)
+		assert.Error(t, err)
+	})
+}
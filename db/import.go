@@ -0,0 +1,152 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ImportReport summarises what happened to the cards in an import.
+type ImportReport struct {
+	Imported   int      `json:"imported"`
+	Skipped    int      `json:"skipped"`    // entries that could not be turned into cards
+	Duplicates int      `json:"duplicates"` // cards duplicating a saved card, which was left untouched
+	Updated    int      `json:"updated"`    // saved cards updated from cards duplicating them
+	Decks      []string `json:"decks"`      // decks cards were imported into
+}
+
+type ImportOption func(*importOptions)
+
+type importOptions struct {
+	duplicates DuplicatePolicy
+}
+
+// WithDuplicates sets how imported cards that duplicate saved cards are
+// handled. By default they are skipped, and OnDuplicateReject is treated
+// the same way, as cards already imported are kept.
+func WithDuplicates(policy DuplicatePolicy) ImportOption {
+	return func(opts *importOptions) {
+		opts.duplicates = policy
+	}
+}
+
+// importedCard is a card read from an import, along with the deck it goes
+// in. A card with a DeckID goes in that deck, otherwise in the deck at the
+// deck path Deck, such as "Go::Concurrency".
+type importedCard struct {
+	Deck   string
+	DeckID int
+	Card   Card
+	Tags   []string
+}
+
+// importCards adds cards to their decks, creating any deck on a card's deck
+// path that does not exist yet. The report lists each deck by its path. A
+// card that duplicates a saved card, including one imported before it, is
+// handled as the options' DuplicatePolicy says. Tags that cannot be stored
+// are left off the card.
+func importCards(db *sql.DB, cards []importedCard, report *ImportReport, opts ...ImportOption) error {
+	options := &importOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if err := options.duplicates.Validate(); err != nil {
+		return err
+	}
+
+	decks, err := getDeckList(db)
+	if err != nil {
+		return err
+	}
+	paths := deckPaths(decks)
+	deckIDs := map[string]int{}
+	for _, deck := range decks {
+		if _, ok := deckIDs[paths[deck.ID]]; !ok {
+			deckIDs[paths[deck.ID]] = deck.ID
+		}
+	}
+
+	seen := map[int]bool{}
+	for _, card := range cards {
+		deckID, path := card.DeckID, card.Deck
+		if deckID == 0 {
+			id, err := deckForPath(db, card.Deck, deckIDs)
+			if err != nil {
+				return err
+			}
+			deckID = id
+		} else {
+			path = paths[deckID]
+		}
+		if !seen[deckID] {
+			seen[deckID] = true
+			report.Decks = append(report.Decks, path)
+		}
+
+		if err := importCard(db, card, deckID, options.duplicates, report); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importCard inserts a card along with its deck and tags, so that a failed
+// import leaves no card behind outside of a deck.
+func importCard(db *sql.DB, imported importedCard, deckID int, policy DuplicatePolicy, report *ImportReport) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error importing card: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	var id int
+	var duplicate *Card
+	if policy.OnDuplicate != OnDuplicateAllow {
+		duplicate, err = findDuplicate(tx, imported.Card.Front, deckID, policy.Scope)
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case duplicate == nil:
+		id, err = insertBasicCard(tx, imported.Card)
+		if err != nil {
+			return fmt.Errorf("error importing card: %w", err)
+		}
+		if err := addCardToDeck(tx, id, deckID); err != nil {
+			return err
+		}
+		report.Imported++
+	case policy.OnDuplicate == OnDuplicateUpdate:
+		err := updateDuplicate(tx, *duplicate, imported.Card, deckID)
+		if errors.Is(err, ErrGeneratedCard) {
+			// A reverse card cannot be changed on its own, so it is kept
+			report.Duplicates++
+			return nil
+		} else if err != nil {
+			return err
+		}
+		id = duplicate.ID
+		report.Updated++
+	default:
+		report.Duplicates++
+		return nil
+	}
+
+	var tags []string
+	for _, tag := range imported.Tags {
+		if name, err := NormalizeTag(tag); err == nil {
+			tags = append(tags, name)
+		}
+	}
+	if err := addCardTags(tx, id, tags); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error importing card: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// checkLeech marks a card that has just lapsed as a leech once it has
+// lapsed LeechThreshold times, suspending it if the deck asks for that.
+func (s DeckSettings) checkLeech(card *Card) {
+	if s.LeechThreshold == 0 || card.Lapses < s.LeechThreshold {
+		return
+	}
+
+	card.Leech = true
+	if s.LeechAction == LeechActionSuspend {
+		card.State = CardStateSuspended
+	}
+}
+
+// GetLeeches returns the leeches in a deck and the decks nested in it, most
+// lapsed first.
+func GetLeeches(db *sql.DB, deckID int) (*[]Card, error) {
+	rows, err := db.Query(`
+        SELECT `+cardColumns+`
+        FROM cards c
+        WHERE `+inDeckSubtree+` AND c.leech
+        ORDER BY c.lapses DESC, c.id
+    `, deckID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting leeches: %v", err)
+	}
+	defer rows.Close()
+
+	cards := []Card{}
+
+	for rows.Next() {
+		var card Card
+		if err := scanCard(rows, &card); err != nil {
+			return nil, fmt.Errorf("error scanning card: %v", err)
+		}
+		cards = append(cards, card)
+	}
+
+	return &cards, nil
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLeech(t *testing.T) {
+	settings := DeckSettings{LeechThreshold: 3, LeechAction: LeechActionTag}
+
+	t.Run("Below threshold", func(t *testing.T) {
+		card := Card{MemoryState: MemoryState{State: CardStateRelearning, Lapses: 2}}
+		settings.checkLeech(&card)
+		assert.False(t, card.Leech)
+	})
+
+	t.Run("Tags leech", func(t *testing.T) {
+		card := Card{MemoryState: MemoryState{State: CardStateRelearning, Lapses: 3}}
+		settings.checkLeech(&card)
+		assert.True(t, card.Leech)
+		assert.Equal(t, CardStateRelearning, card.State)
+	})
+
+	t.Run("Suspends leech", func(t *testing.T) {
+		card := Card{MemoryState: MemoryState{State: CardStateRelearning, Lapses: 3}}
+		DeckSettings{LeechThreshold: 3, LeechAction: LeechActionSuspend}.checkLeech(&card)
+		assert.True(t, card.Leech)
+		assert.Equal(t, CardStateSuspended, card.State)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		card := Card{MemoryState: MemoryState{State: CardStateRelearning, Lapses: 30}}
+		DeckSettings{LeechAction: LeechActionSuspend}.checkLeech(&card)
+		assert.False(t, card.Leech)
+		assert.Equal(t, CardStateRelearning, card.State)
+	})
+}
+
+func TestGetLeeches(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		expected := []Card{
+			{ID: 4, Front: "Front 4", Back: "Back 4", MemoryState: MemoryState{EaseFactor: 1.3, State: CardStateSuspended, Lapses: 9}, Leech: true, Due: due},
+			{ID: 2, Front: "Front 2", Back: "Back 2", MemoryState: MemoryState{EaseFactor: 1.3, Interval: 1, State: CardStateReview, Lapses: 8}, Leech: true, Due: due},
+		}
+		mock.ExpectQuery(regexp.QuoteMeta(inDeckSubtree + " AND c.leech")).WithArgs(5).WillReturnRows(cardRows(expected...))
+
+		cards, err := GetLeeches(db, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, *cards)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("QueryError", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("AND c.leech").WillReturnError(fmt.Errorf("query error"))
+
+		cards, err := GetLeeches(db, 5)
+		assert.Nil(t, cards)
+		assert.EqualError(t, err, "error getting leeches: query error")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
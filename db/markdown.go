@@ -0,0 +1,179 @@
+package db
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrInvalidMarkdown = errors.New("invalid Markdown deck")
+
+// A Markdown deck is a file like:
+//
+//	# Spanish verbs
+//
+//	## hablar
+//	to speak
+//
+//	## comer
+//	to eat
+//
+// The top heading names the deck, and each second level heading is the
+// front of a card whose back is the text up to the next one. Lines in a
+// back that would otherwise read as headings are escaped with a backslash,
+// except inside fenced code blocks.
+//
+// Only plain front and back cards fit this format. Exporting a deck leaves
+// out cloze cards, and cards a note generates from its later templates such
+// as the reverse of a Basic (and reversed) note, so they are lost if the
+// file is imported again.
+
+// ImportMarkdown adds the cards in a Markdown deck to the deck its top
+// heading names, creating the deck if needed. deckName is used for files
+// without a top heading. Cards with an empty front or back are skipped.
+func ImportMarkdown(db *sql.DB, r io.Reader, deckName string, opts ...ImportOption) (*ImportReport, error) {
+	name, cards, skipped, err := readMarkdownDeck(r)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = deckName
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: no deck name heading", ErrInvalidMarkdown)
+	}
+
+	imported := make([]importedCard, len(cards))
+	for i, card := range cards {
+		imported[i] = importedCard{Deck: name, Card: card}
+	}
+
+	report := ImportReport{Skipped: skipped}
+	if err := importCards(db, imported, &report, opts...); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// ExportMarkdown writes the cards in a deck as a Markdown deck, one entry
+// per note. Cloze cards and reverse cards are not exported.
+func ExportMarkdown(db *sql.DB, deckID int, w io.Writer) error {
+	deck, err := GetDeckByID(db, deckID)
+	if err != nil {
+		return err
+	}
+	cards, err := GetCardsFromDeck(db, deckID)
+	if err != nil {
+		return err
+	}
+
+	if err := writeMarkdownDeck(w, deck.Name, *cards); err != nil {
+		return fmt.Errorf("error exporting deck %d: %v", deckID, err)
+	}
+
+	return nil
+}
+
+func readMarkdownDeck(r io.Reader) (string, []Card, int, error) {
+	var name string
+	var cards []Card
+	var front string
+	var back []string
+	inCard, inFence := false, false
+	skipped := 0
+
+	finishCard := func() {
+		if !inCard {
+			return
+		}
+		card := Card{Front: front, Back: strings.TrimSpace(strings.Join(back, "\n"))}
+		if card.Front == "" || card.Back == "" {
+			skipped++
+		} else {
+			cards = append(cards, card)
+		}
+		back = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if isMarkdownFence(line) {
+			inFence = !inFence
+		}
+		if !inFence {
+			switch {
+			case strings.HasPrefix(line, "## "):
+				finishCard()
+				front, inCard = strings.TrimSpace(strings.TrimPrefix(line, "## ")), true
+				continue
+			case strings.HasPrefix(line, "# ") && !inCard && name == "":
+				name = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+				continue
+			case isEscapedHeading(line):
+				line = line[1:]
+			}
+		}
+
+		// Anything between the deck name and the first card is ignored
+		if inCard {
+			back = append(back, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, 0, fmt.Errorf("%w: %v", ErrInvalidMarkdown, err)
+	}
+	finishCard()
+
+	return name, cards, skipped, nil
+}
+
+func writeMarkdownDeck(w io.Writer, name string, cards []Card) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "# %s\n", name)
+
+	for _, card := range cards {
+		if !exportsToMarkdown(card) {
+			continue
+		}
+
+		// Headings are a single line
+		fmt.Fprintf(out, "\n## %s\n\n", strings.Join(strings.Fields(card.Front), " "))
+
+		inFence := false
+		for _, line := range strings.Split(card.Back, "\n") {
+			if isMarkdownFence(line) {
+				inFence = !inFence
+			} else if !inFence && (strings.HasPrefix(line, "#") || isEscapedHeading(line)) {
+				line = `\` + line
+			}
+			fmt.Fprintln(out, line)
+		}
+	}
+
+	return out.Flush()
+}
+
+// exportsToMarkdown reports whether card is written to a Markdown deck. A
+// cloze card's text has no front and back to split it into, and a card from
+// a note's later template would be imported as a separate card.
+func exportsToMarkdown(card Card) bool {
+	return card.Type != CardTypeCloze && card.Template == 0
+}
+
+// isEscapedHeading reports whether line is a heading with one or more
+// backslashes in front of it.
+func isEscapedHeading(line string) bool {
+	return strings.HasPrefix(line, `\`) && strings.HasPrefix(strings.TrimLeft(line, `\`), "#")
+}
+
+func isMarkdownFence(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMarkdownDeck = `# Spanish verbs
+
+Verbs from chapter one.
+
+## hablar
+to speak
+
+## comer
+
+to eat
+\# not a heading
+
+` + "```" + `
+## inside a code block
+` + "```" + `
+
+## vivir
+
+## salir
+to leave
+`
+
+func TestReadMarkdownDeck(t *testing.T) {
+	name, cards, skipped, err := readMarkdownDeck(strings.NewReader(testMarkdownDeck))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Spanish verbs", name)
+	assert.Equal(t, 1, skipped) // vivir has no back
+	assert.Equal(t, []Card{
+		{Front: "hablar", Back: "to speak"},
+		{Front: "comer", Back: "to eat\n# not a heading\n\n```\n## inside a code block\n```"},
+		{Front: "salir", Back: "to leave"},
+	}, cards)
+}
+
+func TestWriteMarkdownDeck(t *testing.T) {
+	cards := []Card{
+		{Front: "hablar", Back: "to speak"},
+		{Front: "comer\nor eat", Back: "to eat\n# not a heading\n\\# escaped\n\n```\n## inside a code block\n```"},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeMarkdownDeck(&buf, "Spanish verbs", cards))
+
+	// Writing then reading a deck gives back the same cards
+	name, read, skipped, err := readMarkdownDeck(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Spanish verbs", name)
+	assert.Equal(t, 0, skipped)
+	assert.Equal(t, "comer or eat", read[1].Front)
+	assert.Equal(t, cards[1].Back, read[1].Back)
+	assert.Equal(t, cards[0], read[0])
+}
+
+func TestImportMarkdown(t *testing.T) {
+	t.Run("Deck named by the heading", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks")).
+			WillReturnRows(deckRows())
+		mock.ExpectQuery("INSERT INTO decks").WithArgs("Spanish verbs").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		for i := 0; i < 3; i++ {
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT .* FROM cards WHERE card_front_key").WillReturnRows(cardRows())
+			expectBuiltinNote(mock, NoteTypeBasic, 1)
+			mock.ExpectQuery("INSERT INTO cards").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10 + i))
+			mock.ExpectExec("INSERT INTO deck_cards").WithArgs(10+i, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+		}
+
+		report, err := ImportMarkdown(db, strings.NewReader(testMarkdownDeck), "notes")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ImportReport{Imported: 3, Skipped: 1, Decks: []string{"Spanish verbs"}}, *report)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No deck name", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		_, err = ImportMarkdown(db, strings.NewReader("## hablar\nto speak\n"), "")
+
+		assert.ErrorIs(t, err, ErrInvalidMarkdown)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestExportMarkdown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks WHERE id = $1")).WithArgs(1).
+		WillReturnRows(deckRows(Deck{ID: 1, Name: "Spanish verbs", Scheduler: SchedulerSM2}))
+	noteID := 4
+	mock.ExpectQuery("SELECT .* FROM cards c JOIN deck_cards dc").WithArgs(1).WillReturnRows(cardRows(
+		Card{ID: 5, Type: CardTypeBasic, Front: "hablar", Back: "to speak", NoteID: &noteID, Due: due},
+		Card{ID: 6, Type: CardTypeBasic, Front: "to speak", Back: "hablar", NoteID: &noteID, Template: 1, Due: due},
+		Card{ID: 7, Type: CardTypeCloze, Front: "{{c1::comer}} is to eat", Cloze: 1, Due: due},
+		Card{ID: 8, Type: CardTypeBasic, Front: "comer", Back: "to eat", Due: due},
+	))
+
+	var buf bytes.Buffer
+	err = ExportMarkdown(db, 1, &buf)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "# Spanish verbs\n\n## hablar\n\nto speak\n\n## comer\n\nto eat\n", buf.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
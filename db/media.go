@@ -0,0 +1,235 @@
+package db
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Media is an image or audio clip that cards show. Files are stored on disk
+// under the SHA-256 hash of their content, so uploading the same file twice
+// stores it once. Card text refers to media by its URL, /media/{hash}, as in
+// ![a cat](/media/{hash}) or <audio controls src="/media/{hash}"></audio>.
+type Media struct {
+	Hash        string    `json:"hash"`
+	Filename    string    `json:"filename"` // the name it was uploaded as
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+const (
+	DefaultMaxMediaSize = 10 << 20
+	// MediaGracePeriod keeps media that no card refers to yet from being
+	// collected while the card that will use it is still being written.
+	MediaGracePeriod = time.Hour
+)
+
+var ErrInvalidMedia = errors.New("invalid media")
+
+// mediaTypes are the content types media may have, as sniffed from the file.
+var mediaTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"audio/mpeg":      true,
+	"audio/wave":      true,
+	"audio/ogg":       true,
+	"application/ogg": true,
+}
+
+// audioTypes are the declared content types trusted for audio that cannot
+// be sniffed, such as MP3 files without an ID3 tag.
+var audioTypes = map[string]bool{
+	"audio/mpeg": true,
+	"audio/mp3":  true,
+	"audio/ogg":  true,
+	"audio/wav":  true,
+}
+
+var mediaHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var MediaTable = TableSchema{
+	Name: "media",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS media (
+        hash TEXT PRIMARY KEY,
+        filename TEXT NOT NULL,
+        content_type TEXT NOT NULL,
+        size BIGINT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );`,
+}
+
+// mediaColumns lists the media columns in the order scanMedia expects them.
+const mediaColumns = "hash, filename, content_type, size, created_at"
+
+func scanMedia(row rowScanner, media *Media) error {
+	return row.Scan(&media.Hash, &media.Filename, &media.ContentType, &media.Size, &media.CreatedAt)
+}
+
+// A MediaStore keeps media files in a directory on disk, with their details
+// in the media table.
+type MediaStore struct {
+	Dir     string
+	MaxSize int64 // in bytes
+}
+
+// NewMediaStore returns a store keeping files in dir, creating it if needed.
+func NewMediaStore(dir string) (*MediaStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating media directory: %w", err)
+	}
+	return &MediaStore{Dir: dir, MaxSize: DefaultMaxMediaSize}, nil
+}
+
+// ValidMediaHash reports whether hash could name a media file.
+func ValidMediaHash(hash string) bool {
+	return mediaHashPattern.MatchString(hash)
+}
+
+// Path returns the path of the file holding the media with the given hash.
+func (s *MediaStore) Path(hash string) string {
+	return filepath.Join(s.Dir, hash)
+}
+
+// Save stores an uploaded file, checking its size and that its content is
+// an image or audio clip. declaredType is the content type the upload gave,
+// only used for audio that cannot be told from its content. Saving a file
+// that is already stored restarts its grace period before CollectGarbage may
+// remove it, as a card using it is likely about to be saved.
+func (s *MediaStore) Save(db *sql.DB, filename string, declaredType string, r io.Reader) (*Media, error) {
+	content, err := io.ReadAll(io.LimitReader(r, s.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading media: %w", err)
+	}
+	if len(content) == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidMedia)
+	}
+	if int64(len(content)) > s.MaxSize {
+		return nil, fmt.Errorf("%w: file is larger than %d bytes", ErrInvalidMedia, s.MaxSize)
+	}
+
+	contentType := http.DetectContentType(content)
+	declaredType, _, _ = strings.Cut(declaredType, ";")
+	if contentType == "application/octet-stream" && audioTypes[declaredType] {
+		contentType = declaredType
+	}
+	if !mediaTypes[contentType] && !audioTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s is not an image or audio type", ErrInvalidMedia, contentType)
+	}
+
+	// The row goes first, so that it waits for CollectGarbage to finish with
+	// a file it is removing and then writes the file again
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	_, err = db.Exec("INSERT INTO media (hash, filename, content_type, size) VALUES ($1, $2, $3, $4) ON CONFLICT (hash) DO UPDATE SET created_at = now()",
+		hash, filepath.Base(filename), contentType, len(content))
+	if err != nil {
+		return nil, fmt.Errorf("error saving media %s: %w", hash, err)
+	}
+	if err := s.writeFile(hash, content); err != nil {
+		return nil, err
+	}
+
+	return GetMedia(db, hash)
+}
+
+// writeFile writes content under hash unless it is already there, going
+// through a temporary file so that a partly written file is never served.
+func (s *MediaStore) writeFile(hash string, content []byte) error {
+	if _, err := os.Stat(s.Path(hash)); err == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("error writing media %s: %w", hash, err)
+	}
+	defer os.Remove(tmp.Name()) // trunk-ignore(golangci-lint/errcheck)
+
+	if _, err := io.Copy(tmp, bytes.NewReader(content)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing media %s: %w", hash, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing media %s: %w", hash, err)
+	}
+	if err := os.Rename(tmp.Name(), s.Path(hash)); err != nil {
+		return fmt.Errorf("error writing media %s: %w", hash, err)
+	}
+
+	return nil
+}
+
+func GetMedia(db *sql.DB, hash string) (*Media, error) {
+	var media Media
+	err := scanMedia(db.QueryRow("SELECT "+mediaColumns+" FROM media WHERE hash = $1", hash), &media)
+	if err != nil {
+		return nil, fmt.Errorf("error getting media %s: %w", hash, err)
+	}
+	return &media, nil
+}
+
+// CollectGarbage deletes media that no card or note refers to, other than
+// media uploaded within MediaGracePeriod of now. It returns the hashes of
+// the deleted media. The rows are deleted in the statement that finds them
+// unused, and their files are removed before the deletion commits, so a
+// Save of the same media waits for it rather than losing its file.
+func (s *MediaStore) CollectGarbage(db *sql.DB, now time.Time) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error collecting media: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	rows, err := tx.Query(`DELETE FROM media m WHERE m.created_at < $1
+        AND NOT EXISTS (SELECT 1 FROM cards c WHERE strpos(c.front, m.hash) > 0 OR strpos(c.back, m.hash) > 0)
+        AND NOT EXISTS (SELECT 1 FROM notes n WHERE strpos(n.fields::TEXT, m.hash) > 0)
+        RETURNING m.hash`, now.Add(-MediaGracePeriod))
+	if err != nil {
+		return nil, fmt.Errorf("error collecting media: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("error scanning media: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error collecting media: %w", err)
+	}
+	rows.Close()
+
+	// A file that cannot be removed is only left over on disk, so its row
+	// is still deleted
+	var errs []error
+	for _, hash := range hashes {
+		if err := os.Remove(s.Path(hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error collecting media: %w", err)
+	}
+	if len(errs) > 0 {
+		return hashes, fmt.Errorf("error removing media files: %w", errors.Join(errs...))
+	}
+
+	return hashes, nil
+}
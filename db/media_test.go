@@ -0,0 +1,125 @@
+package db
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPNG is the start of a PNG file, enough for its type to be sniffed.
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+var testMediaHash = func() string {
+	sum := sha256.Sum256(testPNG)
+	return hex.EncodeToString(sum[:])
+}()
+
+func TestMediaStoreSave(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+		store, err := NewMediaStore(t.TempDir())
+		assert.NoError(t, err)
+
+		mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (hash) DO UPDATE SET created_at = now()")).WithArgs(testMediaHash, "cat.png", "image/png", len(testPNG)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + mediaColumns + " FROM media WHERE hash = $1")).WithArgs(testMediaHash).
+			WillReturnRows(sqlmock.NewRows([]string{"hash", "filename", "content_type", "size", "created_at"}).
+				AddRow(testMediaHash, "cat.png", "image/png", len(testPNG), due))
+
+		media, err := store.Save(db, "uploads/cat.png", "text/plain", bytes.NewReader(testPNG))
+		assert.NoError(t, err)
+		assert.Equal(t, &Media{Hash: testMediaHash, Filename: "cat.png", ContentType: "image/png", Size: int64(len(testPNG)), CreatedAt: due}, media)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		content, err := os.ReadFile(store.Path(testMediaHash))
+		assert.NoError(t, err)
+		assert.Equal(t, testPNG, content)
+	})
+
+	t.Run("Audio without a signature", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+		store, err := NewMediaStore(t.TempDir())
+		assert.NoError(t, err)
+
+		mock.ExpectExec("INSERT INTO media").WithArgs(sqlmock.AnyArg(), "hola.mp3", "audio/mpeg", 4).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT .* FROM media WHERE hash = \\$1").
+			WillReturnRows(sqlmock.NewRows([]string{"hash", "filename", "content_type", "size", "created_at"}).
+				AddRow("hash", "hola.mp3", "audio/mpeg", 4, due))
+
+		_, err = store.Save(db, "hola.mp3", "audio/mpeg", bytes.NewReader([]byte{0xff, 0xfb, 0x90, 0x00}))
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	tests := []struct {
+		name         string
+		content      []byte
+		declaredType string
+	}{
+		{"Empty", nil, "image/png"},
+		{"Too large", append(testPNG, make([]byte, 64)...), "image/png"},
+		{"Not media", []byte("<svg onload=\"alert(1)\"></svg>"), "image/svg+xml"},
+		{"Declared image", []byte{0x00, 0x01, 0x02}, "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error creating mock database: %v", err)
+			}
+			defer db.Close()
+			store := &MediaStore{Dir: t.TempDir(), MaxSize: 64}
+
+			_, err = store.Save(db, "file", tt.declaredType, bytes.NewReader(tt.content))
+			assert.ErrorIs(t, err, ErrInvalidMedia)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestValidMediaHash(t *testing.T) {
+	assert.True(t, ValidMediaHash(testMediaHash))
+	assert.False(t, ValidMediaHash("../db.go"))
+	assert.False(t, ValidMediaHash(testMediaHash[:63]))
+}
+
+func TestMediaStoreCollectGarbage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+	store, err := NewMediaStore(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(store.Path(testMediaHash), testPNG, 0o644))
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM media m WHERE m.created_at < \\$1").WithArgs(now.Add(-MediaGracePeriod)).
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(testMediaHash))
+	mock.ExpectCommit()
+
+	hashes, err := store.CollectGarbage(db, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{testMediaHash}, hashes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = os.Stat(store.Path(testMediaHash))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,483 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A Note holds the content that one or more cards are generated from. Its
+// note type names its fields and has a template for each card; editing the
+// note generates the cards again.
+type Note struct {
+	ID         int               `json:"id"`
+	NoteTypeID int               `json:"noteTypeId"`
+	Fields     map[string]string `json:"fields"`
+}
+
+type NoteType struct {
+	ID        int            `json:"id"`
+	Name      string         `json:"name"`
+	CardType  string         `json:"cardType"` // CardTypeCloze for a card per cloze number of the first template
+	Fields    []string       `json:"fields"`
+	Templates []CardTemplate `json:"templates"`
+}
+
+// A CardTemplate renders a note's fields into the front and back of a card.
+// {{Field}} is replaced by the field's content as it is, {{FrontSide}} on the
+// back by the rendered front, and {{cloze:Field}} by cloze text. A card whose
+// front renders empty is not generated.
+type CardTemplate struct {
+	Name  string `json:"name"`
+	Front string `json:"front"`
+	Back  string `json:"back"`
+}
+
+// The built-in note types. Cards from before notes belong to one of these.
+const (
+	NoteTypeBasic    = "Basic"
+	NoteTypeReversed = "Basic (and reversed card)"
+	NoteTypeCloze    = "Cloze"
+)
+
+var (
+	ErrInvalidNoteType = errors.New("invalid note type")
+	ErrInvalidNote     = errors.New("invalid note")
+	ErrGeneratedCard   = errors.New("card side is generated from several fields, edit its note instead")
+)
+
+var builtinNoteTypes = []NoteType{
+	{Name: NoteTypeBasic, CardType: CardTypeBasic, Fields: []string{"Front", "Back"}, Templates: []CardTemplate{
+		{Name: "Card 1", Front: "{{Front}}", Back: "{{Back}}"},
+	}},
+	{Name: NoteTypeReversed, CardType: CardTypeBasic, Fields: []string{"Front", "Back"}, Templates: []CardTemplate{
+		{Name: "Card 1", Front: "{{Front}}", Back: "{{Back}}"},
+		{Name: "Card 2", Front: "{{Back}}", Back: "{{Front}}"},
+	}},
+	{Name: NoteTypeCloze, CardType: CardTypeCloze, Fields: []string{"Text", "Extra"}, Templates: []CardTemplate{
+		{Name: "Cloze", Front: "{{cloze:Text}}", Back: "{{Extra}}"},
+	}},
+}
+
+var NoteTypesTable = TableSchema{
+	Name: "note_types",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS note_types (
+        id SERIAL PRIMARY KEY,
+        name TEXT NOT NULL UNIQUE,
+        card_type TEXT NOT NULL DEFAULT 'basic',
+        fields TEXT[] NOT NULL,
+        templates JSONB NOT NULL
+    );
+    ` + insertBuiltinNoteTypesSQL(),
+}
+
+// NotesTable moves cards from before notes onto notes of their own, sharing
+// one between a card and its reverse or between cloze siblings. The note
+// takes the ID of its first card.
+var NotesTable = TableSchema{
+	Name: "notes",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS notes (
+        id SERIAL PRIMARY KEY,
+        note_type_id INT NOT NULL,
+        fields JSONB NOT NULL,
+        FOREIGN KEY (note_type_id) REFERENCES note_types(id)
+    );
+    UPDATE cards c SET note_id = g.first_id,
+        template = CASE WHEN c.type = 'basic' AND c.id <> g.first_id THEN 1 ELSE 0 END
+    FROM (
+        SELECT COALESCE(note_id, id) AS grp, MIN(id) AS first_id FROM cards
+        WHERE note_id IS NULL OR note_id NOT IN (SELECT id FROM notes)
+        GROUP BY 1
+    ) g
+    WHERE COALESCE(c.note_id, c.id) = g.grp AND (c.note_id IS NULL OR c.note_id NOT IN (SELECT id FROM notes));
+    INSERT INTO notes (id, note_type_id, fields)
+    SELECT f.note_id, t.id, CASE WHEN f.type = 'cloze'
+        THEN jsonb_build_object('Text', f.front, 'Extra', f.back)
+        ELSE jsonb_build_object('Front', f.front, 'Back', f.back) END
+    FROM (
+        SELECT DISTINCT ON (note_id) note_id, type, front, back, COUNT(*) OVER (PARTITION BY note_id) AS cards
+        FROM cards WHERE note_id NOT IN (SELECT id FROM notes)
+        ORDER BY note_id, id
+    ) f
+    JOIN note_types t ON t.name = CASE WHEN f.type = 'cloze' THEN 'Cloze' WHEN f.cards > 1 THEN 'Basic (and reversed card)' ELSE 'Basic' END;
+    SELECT setval(pg_get_serial_sequence('notes', 'id'), GREATEST(MAX(id), (SELECT last_value FROM notes_id_seq)), true) FROM notes;
+    DO $$ BEGIN
+        ALTER TABLE cards ADD CONSTRAINT cards_note_id_fkey FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE;
+    EXCEPTION WHEN duplicate_object THEN NULL;
+    END $$;`,
+}
+
+func insertBuiltinNoteTypesSQL() string {
+	var values []string
+	for _, noteType := range builtinNoteTypes {
+		templates, err := json.Marshal(noteType.Templates)
+		if err != nil {
+			panic(err)
+		}
+		values = append(values, fmt.Sprintf("(%s, %s, %s, %s)", pq.QuoteLiteral(noteType.Name), pq.QuoteLiteral(noteType.CardType),
+			pq.QuoteLiteral("{"+strings.Join(noteType.Fields, ",")+"}"), pq.QuoteLiteral(string(templates))))
+	}
+	return "INSERT INTO note_types (name, card_type, fields, templates) VALUES " + strings.Join(values, ", ") + " ON CONFLICT (name) DO NOTHING;"
+}
+
+// noteTypeColumns lists the note_types columns in the order scanNoteType
+// expects them.
+const noteTypeColumns = "id, name, card_type, fields, templates"
+
+func scanNoteType(row rowScanner, noteType *NoteType) error {
+	var fields pq.StringArray
+	var templates []byte
+	if err := row.Scan(&noteType.ID, &noteType.Name, &noteType.CardType, &fields, &templates); err != nil {
+		return err
+	}
+	noteType.Fields = fields
+	return json.Unmarshal(templates, &noteType.Templates)
+}
+
+const noteColumns = "id, note_type_id, fields"
+
+func scanNote(row rowScanner, note *Note) error {
+	var fields []byte
+	if err := row.Scan(&note.ID, &note.NoteTypeID, &fields); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields, &note.Fields)
+}
+
+// Validate checks that a note type has fields with distinct names and
+// templates that only use them. A cloze note type has a single template
+// with a cloze field on its front.
+func (t NoteType) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidNoteType)
+	}
+	if t.CardType != CardTypeBasic && t.CardType != CardTypeCloze {
+		return fmt.Errorf("%w: unknown card type %q", ErrInvalidNoteType, t.CardType)
+	}
+	if len(t.Fields) == 0 {
+		return fmt.Errorf("%w: at least one field is required", ErrInvalidNoteType)
+	}
+	for i, field := range t.Fields {
+		if field == "" || field == "FrontSide" || strings.ContainsAny(field, "{}:") {
+			return fmt.Errorf("%w: invalid field name %q", ErrInvalidNoteType, field)
+		}
+		if slices.Contains(t.Fields[:i], field) {
+			return fmt.Errorf("%w: field %q is repeated", ErrInvalidNoteType, field)
+		}
+	}
+	if len(t.Templates) == 0 {
+		return fmt.Errorf("%w: at least one template is required", ErrInvalidNoteType)
+	}
+	if t.CardType == CardTypeCloze && (len(t.Templates) != 1 || !strings.Contains(t.Templates[0].Front, "{{cloze:")) {
+		return fmt.Errorf("%w: a cloze note type has one template with a cloze field on its front", ErrInvalidNoteType)
+	}
+	for _, template := range t.Templates {
+		for _, side := range []string{template.Front, template.Back} {
+			for _, match := range templateFieldPattern.FindAllStringSubmatch(side, -1) {
+				name := strings.TrimPrefix(match[1], "cloze:")
+				if name != "FrontSide" && !slices.Contains(t.Fields, name) {
+					return fmt.Errorf("%w: template %q uses unknown field %q", ErrInvalidNoteType, template.Name, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var templateFieldPattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// renderTemplate fills in a template side with a note's fields.
+func renderTemplate(side string, fields map[string]string, frontSide string) string {
+	return templateFieldPattern.ReplaceAllStringFunc(side, func(ref string) string {
+		name := templateFieldPattern.FindStringSubmatch(ref)[1]
+		if name == "FrontSide" {
+			return frontSide
+		}
+		return fields[strings.TrimPrefix(name, "cloze:")]
+	})
+}
+
+// templateField returns the field a template side shows, if it shows
+// nothing but that one field.
+func templateField(side string) string {
+	match := templateFieldPattern.FindStringSubmatch(side)
+	if match == nil || strings.TrimSpace(side) != match[0] || match[1] == "FrontSide" {
+		return ""
+	}
+	return strings.TrimPrefix(match[1], "cloze:")
+}
+
+// generateCards returns the cards a note of this type generates, without
+// IDs or a schedule.
+func (t NoteType) generateCards(note Note) []Card {
+	var cards []Card
+	if t.CardType == CardTypeCloze {
+		template := t.Templates[0]
+		front := renderTemplate(template.Front, note.Fields, "")
+		back := renderTemplate(template.Back, note.Fields, front)
+		for _, n := range ClozeNumbers(front) {
+			cards = append(cards, Card{Type: CardTypeCloze, Front: front, Back: back, Cloze: n})
+		}
+		return cards
+	}
+
+	for i, template := range t.Templates {
+		front := renderTemplate(template.Front, note.Fields, "")
+		if strings.TrimSpace(front) == "" {
+			continue
+		}
+		back := renderTemplate(template.Back, note.Fields, front)
+		cards = append(cards, Card{Type: CardTypeBasic, Front: front, Back: back, Template: i})
+	}
+	return cards
+}
+
+func GetNoteTypes(db *sql.DB) (*[]NoteType, error) {
+	rows, err := db.Query("SELECT " + noteTypeColumns + " FROM note_types ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error getting note types: %v", err)
+	}
+	defer rows.Close()
+
+	var noteTypes []NoteType
+	for rows.Next() {
+		var noteType NoteType
+		if err := scanNoteType(rows, &noteType); err != nil {
+			return nil, fmt.Errorf("error scanning note type: %v", err)
+		}
+		noteTypes = append(noteTypes, noteType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting note types: %v", err)
+	}
+
+	return &noteTypes, nil
+}
+
+func GetNoteType(db *sql.DB, noteTypeID int) (*NoteType, error) {
+	return getNoteType(db, noteTypeID)
+}
+
+func getNoteType(db queryRower, noteTypeID int) (*NoteType, error) {
+	var noteType NoteType
+	err := scanNoteType(db.QueryRow("SELECT "+noteTypeColumns+" FROM note_types WHERE id = $1", noteTypeID), &noteType)
+	if err != nil {
+		return nil, fmt.Errorf("error getting note type %d: %w", noteTypeID, err)
+	}
+	return &noteType, nil
+}
+
+// InsertNoteType adds a note type and returns its ID.
+func InsertNoteType(db *sql.DB, noteType NoteType) (int, error) {
+	if err := noteType.Validate(); err != nil {
+		return 0, err
+	}
+	templates, err := json.Marshal(noteType.Templates)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting note type: %v", err)
+	}
+
+	var id int
+	err = db.QueryRow("INSERT INTO note_types (name, card_type, fields, templates) VALUES ($1, $2, $3, $4) RETURNING id",
+		noteType.Name, noteType.CardType, pq.StringArray(noteType.Fields), templates).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting note type: %w", err)
+	}
+
+	return id, nil
+}
+
+func GetNote(db *sql.DB, noteID int) (*Note, error) {
+	return getNote(db, noteID)
+}
+
+func getNote(db queryRower, noteID int) (*Note, error) {
+	var note Note
+	err := scanNote(db.QueryRow("SELECT "+noteColumns+" FROM notes WHERE id = $1", noteID), &note)
+	if err != nil {
+		return nil, fmt.Errorf("error getting note %d: %w", noteID, err)
+	}
+	return &note, nil
+}
+
+// InsertNote adds a note with the new cards it generates, and returns the
+// cards' IDs. note.ID is set to the note's ID.
+func InsertNote(db *sql.DB, note *Note) ([]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error inserting note: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	noteType, err := getNoteType(tx, note.NoteTypeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no note type %d", ErrInvalidNote, note.NoteTypeID)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := noteType.validateNote(*note); err != nil {
+		return nil, err
+	}
+	cards := noteType.generateCards(*note)
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("%w: no cards would be generated", ErrInvalidNote)
+	}
+
+	fields, err := json.Marshal(note.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting note: %v", err)
+	}
+	err = tx.QueryRow("INSERT INTO notes (note_type_id, fields) VALUES ($1, $2) RETURNING id", note.NoteTypeID, fields).Scan(&note.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting note: %w", err)
+	}
+
+	var ids []int
+	for _, card := range cards {
+		id, err := insertCard(tx, newNoteCard(card, note.ID))
+		if err != nil {
+			return nil, fmt.Errorf("error inserting card for note %d: %w", note.ID, err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error inserting note: %w", err)
+	}
+
+	return ids, nil
+}
+
+// UpdateNote saves a note's fields and generates its cards again. Cards
+// keep their schedule, and templates that now generate a card add it to the
+// decks of the note's other cards. Cards that would no longer be generated
+// are kept.
+func UpdateNote(db *sql.DB, note Note) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error updating note %d: %w", note.ID, err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	existing, err := getNote(tx, note.ID)
+	if err != nil {
+		return err
+	}
+	note.NoteTypeID = existing.NoteTypeID
+	noteType, err := getNoteType(tx, note.NoteTypeID)
+	if err != nil {
+		return err
+	}
+	if err := updateNote(tx, noteType, note); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error updating note %d: %w", note.ID, err)
+	}
+
+	return nil
+}
+
+func updateNote(tx *sql.Tx, noteType *NoteType, note Note) error {
+	if err := noteType.validateNote(note); err != nil {
+		return err
+	}
+
+	fields, err := json.Marshal(note.Fields)
+	if err != nil {
+		return fmt.Errorf("error updating note %d: %v", note.ID, err)
+	}
+	if _, err := tx.Exec("UPDATE notes SET fields = $1 WHERE id = $2", fields, note.ID); err != nil {
+		return fmt.Errorf("error updating note %d: %w", note.ID, err)
+	}
+
+	// Cards are told apart by template, and cloze cards by cloze number
+	type ord struct{ template, cloze int }
+	cardIDs := map[ord]int{}
+	rows, err := tx.Query("SELECT id, template, cloze_number FROM cards WHERE note_id = $1", note.ID)
+	if err != nil {
+		return fmt.Errorf("error getting cards of note %d: %w", note.ID, err)
+	}
+	for rows.Next() {
+		var id int
+		var key ord
+		if err := rows.Scan(&id, &key.template, &key.cloze); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning card of note %d: %w", note.ID, err)
+		}
+		cardIDs[key] = id
+	}
+	rows.Close()
+
+	for _, card := range noteType.generateCards(note) {
+		if id, ok := cardIDs[ord{card.Template, card.Cloze}]; ok {
+			if _, err := tx.Exec("UPDATE cards SET front = $1, back = $2 WHERE id = $3", card.Front, card.Back, id); err != nil {
+				return fmt.Errorf("error updating card %d: %w", id, err)
+			}
+			continue
+		}
+
+		id, err := insertCard(tx, newNoteCard(card, note.ID))
+		if err != nil {
+			return fmt.Errorf("error inserting card for note %d: %w", note.ID, err)
+		}
+		_, err = tx.Exec(`INSERT INTO deck_cards (card_id, deck_id)
+            SELECT DISTINCT $1::INT, dc.deck_id FROM deck_cards dc JOIN cards c ON c.id = dc.card_id WHERE c.note_id = $2`, id, note.ID)
+		if err != nil {
+			return fmt.Errorf("error adding card %d to decks: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteNote deletes a note along with all of its cards.
+func DeleteNote(db *sql.DB, noteID int) error {
+	_, err := db.Exec("DELETE FROM notes WHERE id = $1", noteID)
+	if err != nil {
+		return fmt.Errorf("error deleting note %d: %w", noteID, err)
+	}
+	return nil
+}
+
+func (t NoteType) validateNote(note Note) error {
+	for name := range note.Fields {
+		if !slices.Contains(t.Fields, name) {
+			return fmt.Errorf("%w: %s has no field %q", ErrInvalidNote, t.Name, name)
+		}
+	}
+	return nil
+}
+
+// newNoteCard gives a card generated for a note the schedule of a new card.
+func newNoteCard(card Card, noteID int) Card {
+	card.MemoryState = MemoryState{EaseFactor: DefaultEaseFactor, State: CardStateNew}
+	card.Due = time.Now()
+	card.NoteID = &noteID
+	return card
+}
+
+// insertBuiltinNote adds a note of one of the built-in note types, for cards
+// that are made without going through note types.
+func insertBuiltinNote(db queryRower, noteType string, fields map[string]string) (int, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting note: %v", err)
+	}
+
+	var id int
+	err = db.QueryRow("INSERT INTO notes (note_type_id, fields) SELECT id, $2 FROM note_types WHERE name = $1 RETURNING id", noteType, data).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting note: %w", err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// vocabulary is a note type with a card from the word and, for notes with
+// an example, a second card from the example.
+var vocabulary = NoteType{
+	ID:       4,
+	Name:     "Vocabulary",
+	CardType: CardTypeBasic,
+	Fields:   []string{"Word", "Reading", "Meaning", "Example"},
+	Templates: []CardTemplate{
+		{Name: "Recognition", Front: "{{Word}}", Back: "{{FrontSide}}<hr>{{Reading}}<br>{{Meaning}}"},
+		{Name: "Example", Front: "{{Example}}", Back: "{{Meaning}}"},
+	},
+}
+
+// expectBuiltinNote expects a note of a built-in note type to be inserted
+// as id.
+func expectBuiltinNote(mock sqlmock.Sqlmock, noteType string, id int) {
+	mock.ExpectQuery("INSERT INTO notes").WithArgs(noteType, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+}
+
+// noteTypeRows builds mocked rows holding note types, in the column order of
+// noteTypeColumns.
+func noteTypeRows(noteTypes ...NoteType) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "card_type", "fields", "templates"})
+	for _, noteType := range noteTypes {
+		templates, _ := json.Marshal(noteType.Templates)
+		fields, _ := pq.StringArray(noteType.Fields).Value()
+		rows.AddRow(noteType.ID, noteType.Name, noteType.CardType, fields, templates)
+	}
+	return rows
+}
+
+func noteRows(notes ...Note) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "note_type_id", "fields"})
+	for _, note := range notes {
+		fields, _ := json.Marshal(note.Fields)
+		rows.AddRow(note.ID, note.NoteTypeID, fields)
+	}
+	return rows
+}
+
+func TestNoteTypeValidate(t *testing.T) {
+	assert.NoError(t, vocabulary.Validate())
+	for _, noteType := range builtinNoteTypes {
+		assert.NoError(t, noteType.Validate(), noteType.Name)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*NoteType)
+	}{
+		{"No name", func(nt *NoteType) { nt.Name = " " }},
+		{"Unknown card type", func(nt *NoteType) { nt.CardType = "image" }},
+		{"No fields", func(nt *NoteType) { nt.Fields = nil }},
+		{"Repeated field", func(nt *NoteType) { nt.Fields = []string{"Word", "Word"} }},
+		{"Reserved field", func(nt *NoteType) { nt.Fields = append(nt.Fields, "FrontSide") }},
+		{"No templates", func(nt *NoteType) { nt.Templates = nil }},
+		{"Unknown field", func(nt *NoteType) { nt.Templates = []CardTemplate{{Name: "Card 1", Front: "{{Kanji}}"}} }},
+		{"Cloze without a cloze field", func(nt *NoteType) { nt.CardType = CardTypeCloze; nt.Templates = nt.Templates[:1] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noteType := vocabulary
+			noteType.Fields = append([]string{}, vocabulary.Fields...)
+			tt.modify(&noteType)
+			assert.ErrorIs(t, noteType.Validate(), ErrInvalidNoteType)
+		})
+	}
+}
+
+func TestGenerateCards(t *testing.T) {
+	t.Run("A card per template", func(t *testing.T) {
+		note := Note{Fields: map[string]string{"Word": "猫", "Reading": "ねこ", "Meaning": "cat", "Example": "猫が好き"}}
+
+		assert.Equal(t, []Card{
+			{Type: CardTypeBasic, Front: "猫", Back: "猫<hr>ねこ<br>cat"},
+			{Type: CardTypeBasic, Front: "猫が好き", Back: "cat", Template: 1},
+		}, vocabulary.generateCards(note))
+	})
+
+	t.Run("Skips empty fronts", func(t *testing.T) {
+		note := Note{Fields: map[string]string{"Word": "犬", "Meaning": "dog"}}
+
+		cards := vocabulary.generateCards(note)
+		assert.Len(t, cards, 1)
+		assert.Equal(t, "犬<hr><br>dog", cards[0].Back)
+	})
+
+	t.Run("A card per cloze number", func(t *testing.T) {
+		note := Note{Fields: map[string]string{"Text": testClozeText, "Extra": "Since 1561"}}
+
+		assert.Equal(t, []Card{
+			{Type: CardTypeCloze, Front: testClozeText, Back: "Since 1561", Cloze: 1},
+			{Type: CardTypeCloze, Front: testClozeText, Back: "Since 1561", Cloze: 2},
+		}, builtinNoteTypes[2].generateCards(note))
+	})
+}
+
+func TestTemplateField(t *testing.T) {
+	assert.Equal(t, "Front", templateField("{{Front}}"))
+	assert.Equal(t, "Text", templateField(" {{ cloze:Text }} "))
+	assert.Equal(t, "", templateField("{{FrontSide}}"))
+	assert.Equal(t, "", templateField("{{Word}} ({{Reading}})"))
+	assert.Equal(t, "", templateField("<b>{{Word}}</b>"))
+}
+
+func TestInsertNote(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT " + noteTypeColumns + " FROM note_types WHERE id = $1")).WithArgs(4).
+			WillReturnRows(noteTypeRows(vocabulary))
+		mock.ExpectQuery("INSERT INTO notes").WithArgs(4, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectQuery("INSERT INTO cards").
+			WithArgs(CardTypeBasic, "犬", "犬<hr>いぬ<br>dog", 0, 2.5, 0, 0, 0.0, 0.0, nil, 0, CardStateNew, 0, false, 9, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(20))
+		mock.ExpectCommit()
+
+		note := Note{NoteTypeID: 4, Fields: map[string]string{"Word": "犬", "Reading": "いぬ", "Meaning": "dog"}}
+		ids, err := InsertNote(db, &note)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{20}, ids)
+		assert.Equal(t, 9, note.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .* FROM note_types WHERE id = \\$1").WithArgs(4).WillReturnRows(noteTypeRows(vocabulary))
+		mock.ExpectRollback()
+
+		_, err = InsertNote(db, &Note{NoteTypeID: 4, Fields: map[string]string{"Word": "犬", "Kanji": "犬"}})
+
+		assert.ErrorIs(t, err, ErrInvalidNote)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No cards", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .* FROM note_types WHERE id = \\$1").WithArgs(4).WillReturnRows(noteTypeRows(vocabulary))
+		mock.ExpectRollback()
+
+		_, err = InsertNote(db, &Note{NoteTypeID: 4, Fields: map[string]string{"Meaning": "dog"}})
+
+		assert.ErrorIs(t, err, ErrInvalidNote)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateNote(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	// The note had no example, so only its first card
+	fields := map[string]string{"Word": "犬", "Reading": "いぬ", "Meaning": "dog", "Example": "犬がいる"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + noteColumns + " FROM notes WHERE id = $1")).WithArgs(9).
+		WillReturnRows(noteRows(Note{ID: 9, NoteTypeID: 4, Fields: map[string]string{"Word": "犬", "Meaning": "dog"}}))
+	mock.ExpectQuery("SELECT .* FROM note_types WHERE id = \\$1").WithArgs(4).WillReturnRows(noteTypeRows(vocabulary))
+	mock.ExpectExec("UPDATE notes SET fields = \\$1 WHERE id = \\$2").WithArgs(sqlmock.AnyArg(), 9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, template, cloze_number FROM cards WHERE note_id = \\$1").WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "template", "cloze_number"}).AddRow(20, 0, 0))
+	mock.ExpectExec("UPDATE cards SET front = \\$1, back = \\$2 WHERE id = \\$3").WithArgs("犬", "犬<hr>いぬ<br>dog", 20).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO cards").
+		WithArgs(CardTypeBasic, "犬がいる", "dog", 0, 2.5, 0, 0, 0.0, 0.0, nil, 0, CardStateNew, 0, false, 9, 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	mock.ExpectExec("INSERT INTO deck_cards \\(card_id, deck_id\\)\\s+SELECT DISTINCT").WithArgs(21, 9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = UpdateNote(db, Note{ID: 9, Fields: fields})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
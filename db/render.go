@@ -0,0 +1,57 @@
+package db
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// CardHTML holds the sides of a card rendered from Markdown to HTML that is
+// safe to show as it is.
+type CardHTML struct {
+	Front string `json:"front"`
+	Back  string `json:"back"`
+}
+
+// Card text is Markdown, with line breaks kept as they are typed. HTML in
+// the text, such as from note templates, is passed through the sanitiser
+// along with the rendered Markdown, which drops scripts, event handlers,
+// styles, links other than http, https and mailto, and audio from
+// anywhere but the media store.
+var (
+	markdownRenderer = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(goldmarkhtml.WithHardWraps(), goldmarkhtml.WithUnsafe()),
+	)
+	htmlSanitizer = func() *bluemonday.Policy {
+		p := bluemonday.UGCPolicy().AddTargetBlankToFullyQualifiedLinks(true)
+		p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+		// Audio clips can only come from the media store
+		p.AllowElements("audio")
+		p.AllowAttrs("controls").OnElements("audio")
+		p.AllowAttrs("src").Matching(regexp.MustCompile(`^/media/[0-9a-f]{64}$`)).OnElements("audio")
+		return p
+	}()
+)
+
+// RenderMarkdown renders Markdown text to sanitised HTML.
+func RenderMarkdown(text string) string {
+	var buf bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(text), &buf); err != nil {
+		// Writing to a buffer does not fail, but never show the text unescaped
+		return "<p>" + html.EscapeString(text) + "</p>"
+	}
+	return htmlSanitizer.Sanitize(buf.String())
+}
+
+// WithHTML returns the card with its sides rendered to HTML as they are
+// studied, leaving Front and Back as they are.
+func (c Card) WithHTML() Card {
+	c.HTML = c.Rendered().HTML
+	return c
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"Emphasis", "**hablar** is *to speak*", "<p><strong>hablar</strong> is <em>to speak</em></p>\n"},
+		{"Line breaks", "to speak\nto talk", "<p>to speak<br>\nto talk</p>\n"},
+		{"List", "- one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"},
+		{"Code block", "```go\nx := 1 < 2\n```", "<pre><code class=\"language-go\">x := 1 &lt; 2\n</code></pre>\n"},
+		{"Link", "[docs](https://go.dev)", "<p><a href=\"https://go.dev\" rel=\"nofollow noopener\" target=\"_blank\">docs</a></p>\n"},
+		{"Template HTML", "犬<hr>いぬ", "<p>犬<hr>いぬ</p>\n"},
+		{"Script", "hablar<script>alert(1)</script>", "<p>hablar</p>\n"},
+		{"Event handler", "<img src=\"x.png\" onerror=\"alert(1)\">", "<img src=\"x.png\">"},
+		{"Script link", "[click](javascript:alert(1))", "<p>click</p>\n"},
+		{"Media image", "![cat](/media/" + testMediaHash + ")", "<p><img src=\"/media/" + testMediaHash + "\" alt=\"cat\"></p>\n"},
+		{"Media audio", "<audio controls src=\"/media/" + testMediaHash + "\"></audio>", "<p><audio controls=\"\" src=\"/media/" + testMediaHash + "\"></audio></p>\n"},
+		{"Other audio", "<audio controls src=\"https://example.com/a.mp3\"></audio>", "<p><audio controls=\"\"></audio></p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, RenderMarkdown(tt.text))
+		})
+	}
+}
+
+func TestCardWithHTML(t *testing.T) {
+	card := Card{Type: CardTypeCloze, Front: "{{c1::**Madrid**}} is in Spain", Cloze: 1}
+
+	withHTML := card.WithHTML()
+	assert.Equal(t, card.Front, withHTML.Front)
+	assert.Equal(t, &CardHTML{Front: "<p>[...] is in Spain</p>\n", Back: "<p><strong>Madrid</strong> is in Spain</p>\n"}, withHTML.HTML)
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ReviewLog is one entry in the history of ratings given to a card.
+type ReviewLog struct {
+	ID           int       `json:"id"`
+	CardID       int       `json:"cardId"`
+	DeckID       *int      `json:"deckId"`    // nil if the card was studied outside a deck
+	SessionID    *int      `json:"sessionId"` // nil if the card was studied outside a study session
+	Rating       int       `json:"rating"`
+	PrevInterval int       `json:"prevInterval"`
+	NextInterval int       `json:"nextInterval"`
+	LatencyMs    int       `json:"latencyMs"`
+	ReviewedAt   time.Time `json:"reviewedAt"`
+}
+
+var ReviewLogTable = TableSchema{
+	Name: "review_log",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS review_log (
+        id SERIAL PRIMARY KEY,
+        card_id INT NOT NULL,
+        deck_id INT,
+        rating INT NOT NULL,
+        prev_interval INT NOT NULL,
+        next_interval INT NOT NULL,
+        latency_ms INT NOT NULL DEFAULT 0,
+        reviewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        FOREIGN KEY (card_id) REFERENCES cards(id) ON DELETE CASCADE,
+        FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE SET NULL
+    );
+    CREATE INDEX IF NOT EXISTS review_log_card_id_idx ON review_log (card_id, reviewed_at);
+    ALTER TABLE review_log ADD COLUMN IF NOT EXISTS session_id INT REFERENCES study_sessions(id) ON DELETE SET NULL;`,
+}
+
+// reviewLogColumns lists the review_log columns in the order scanReviewLog expects them.
+const reviewLogColumns = "id, card_id, deck_id, session_id, rating, prev_interval, next_interval, latency_ms, reviewed_at"
+
+func scanReviewLog(row rowScanner, entry *ReviewLog) error {
+	return row.Scan(&entry.ID, &entry.CardID, &entry.DeckID, &entry.SessionID, &entry.Rating,
+		&entry.PrevInterval, &entry.NextInterval, &entry.LatencyMs, &entry.ReviewedAt)
+}
+
+func InsertReviewLog(db queryRower, entry ReviewLog) (int, error) {
+	var id int
+	err := db.QueryRow(`INSERT INTO review_log (card_id, deck_id, session_id, rating, prev_interval, next_interval, latency_ms, reviewed_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		entry.CardID, entry.DeckID, entry.SessionID, entry.Rating, entry.PrevInterval, entry.NextInterval, entry.LatencyMs, entry.ReviewedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error logging review for card %d: %w", entry.CardID, err)
+	}
+
+	return id, nil
+}
+
+// GetReviewsForCard returns every review of a card, oldest first.
+func GetReviewsForCard(db *sql.DB, cardID int) (*[]ReviewLog, error) {
+	rows, err := db.Query("SELECT "+reviewLogColumns+" FROM review_log WHERE card_id = $1 ORDER BY reviewed_at, id", cardID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reviews for card: %v", err)
+	}
+	defer rows.Close()
+
+	reviews := []ReviewLog{}
+
+	for rows.Next() {
+		var entry ReviewLog
+		if err := scanReviewLog(rows, &entry); err != nil {
+			return nil, fmt.Errorf("error scanning review: %v", err)
+		}
+		reviews = append(reviews, entry)
+	}
+
+	return &reviews, nil
+}
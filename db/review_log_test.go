@@ -0,0 +1,87 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertReviewLog(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		deckID, sessionID := 3, 9
+		mock.ExpectQuery("INSERT INTO review_log").
+			WithArgs(7, 3, 9, 4, 1, 6, 2000, due).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+
+		id, err := InsertReviewLog(db, ReviewLog{CardID: 7, DeckID: &deckID, SessionID: &sessionID, Rating: 4, PrevInterval: 1, NextInterval: 6, LatencyMs: 2000, ReviewedAt: due})
+		assert.NoError(t, err)
+		assert.Equal(t, 11, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("INSERT INTO review_log").WillReturnError(fmt.Errorf("insert error"))
+
+		_, err = InsertReviewLog(db, ReviewLog{CardID: 7, Rating: 4, ReviewedAt: due})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetReviewsForCard(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT " + reviewLogColumns + " FROM review_log WHERE card_id = $1")
+	columns := []string{"id", "card_id", "deck_id", "session_id", "rating", "prev_interval", "next_interval", "latency_ms", "reviewed_at"}
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		deckID := 3
+		expected := []ReviewLog{
+			{ID: 1, CardID: 7, DeckID: &deckID, Rating: 4, PrevInterval: 0, NextInterval: 1, LatencyMs: 1200, ReviewedAt: due},
+			{ID: 2, CardID: 7, Rating: 2, PrevInterval: 1, NextInterval: 1, LatencyMs: 5400, ReviewedAt: due.AddDate(0, 0, 1)},
+		}
+		rows := sqlmock.NewRows(columns).
+			AddRow(1, 7, 3, nil, 4, 0, 1, 1200, due).
+			AddRow(2, 7, nil, nil, 2, 1, 1, 5400, due.AddDate(0, 0, 1))
+		mock.ExpectQuery(query).WithArgs(7).WillReturnRows(rows)
+
+		reviews, err := GetReviewsForCard(db, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, *reviews)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("QueryError", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(query).WillReturnError(fmt.Errorf("query error"))
+
+		reviews, err := GetReviewsForCard(db, 7)
+		assert.Nil(t, reviews)
+		assert.EqualError(t, err, "error getting reviews for card: query error")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// Names of the available schedulers, as stored in decks.scheduler.
+const (
+	SchedulerSM2  = "sm2"
+	SchedulerFSRS = "fsrs"
+
+	DefaultScheduler = SchedulerSM2
+)
+
+// States a card moves through as it is studied, as stored in cards.state.
+const (
+	CardStateNew        = "new"
+	CardStateLearning   = "learning"
+	CardStateReview     = "review"
+	CardStateRelearning = "relearning" // a review card that was failed
+	CardStateSuspended  = "suspended"  // never shown until unsuspended
+)
+
+// MemoryState is everything a Scheduler knows about how well a card is
+// remembered. Each algorithm only reads and writes the fields it needs.
+type MemoryState struct {
+	EaseFactor  float64    `json:"easeFactor"`
+	Interval    int        `json:"interval"` // days until the next review
+	Repetitions int        `json:"repetitions"`
+	Stability   float64    `json:"stability"`
+	Difficulty  float64    `json:"difficulty"`
+	LastReview  *time.Time `json:"lastReview"`
+	Step        int        `json:"step"` // next learning step, while the card is learning
+	State       string     `json:"state"`
+	Lapses      int        `json:"lapses"` // times the card was failed while in review
+}
+
+// A Scheduler decides when a card should be reviewed again.
+type Scheduler interface {
+	// Schedule takes a card's current memory state and a rating between
+	// MinRating and MaxRating given at time now, and returns the next
+	// memory state and when the card is due.
+	Schedule(state MemoryState, rating int, now time.Time) (MemoryState, time.Time, error)
+}
+
+var (
+	ErrUnknownScheduler = errors.New("unknown scheduler")
+	ErrCardSuspended    = errors.New("card is suspended")
+)
+
+var schedulers = map[string]Scheduler{
+	SchedulerSM2:  SM2{},
+	SchedulerFSRS: NewFSRS(),
+}
+
+// GetScheduler returns the scheduler registered under name. An empty name
+// returns the default scheduler.
+func GetScheduler(name string) (Scheduler, error) {
+	if name == "" {
+		name = DefaultScheduler
+	}
+
+	scheduler, ok := schedulers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownScheduler, name)
+	}
+
+	return scheduler, nil
+}
+
+func validateRating(rating int) error {
+	if rating < MinRating || rating > MaxRating {
+		return fmt.Errorf("rating %d out of range [%d, %d]", rating, MinRating, MaxRating)
+	}
+	return nil
+}
+
+// Review is a single rating given to a card while studying.
+type Review struct {
+	CardID    int
+	DeckID    int // deck the card was studied from, 0 if unknown
+	SessionID int // study session the card was rated in, 0 if none
+	Rating    int
+	Latency   time.Duration // time taken to answer
+}
+
+// GetCardScheduler returns the deck a card is studied from and the name of
+// the scheduler that deck uses. If deckID is 0 the card's oldest deck is
+// used, and cards in no deck get deck 0 with the default scheduler. Studying
+// a deck includes the decks nested in it, so a card in one of those uses its
+// own deck, and a card in none of them returns sql.ErrNoRows.
+func GetCardScheduler(db *sql.DB, cardID int, deckID int) (int, string, error) {
+	var name string
+	err := db.QueryRow(`
+        SELECT d.id, d.scheduler
+        FROM decks d
+        JOIN deck_cards dc ON d.id = dc.deck_id
+        WHERE dc.card_id = $1 AND ($2 = 0 OR d.id IN (`+deckSubtree("$2")+`))
+        ORDER BY d.id <> $2, d.id
+        LIMIT 1
+    `, cardID, deckID).Scan(&deckID, &name)
+	if errors.Is(err, sql.ErrNoRows) && deckID == 0 {
+		return 0, DefaultScheduler, nil
+	} else if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", fmt.Errorf("card %d is not in deck %d: %w", cardID, deckID, err)
+	} else if err != nil {
+		return 0, "", fmt.Errorf("error getting scheduler for card %d: %w", cardID, err)
+	}
+
+	return deckID, name, nil
+}
+
+// ReviewCard applies a review using the scheduler and settings of the deck
+// the card was studied from, stores the card's new schedule and logs the review.
+// Reviews made in a study session are attached to it and take its deck. The
+// card's schedule, its buried siblings, the log entry and the session queue
+// are all saved in one transaction.
+func ReviewCard(db *sql.DB, review Review) (*Card, error) {
+	card, err := GetCardByID(db, review.CardID)
+	if err != nil {
+		return nil, err
+	}
+
+	if review.SessionID != 0 {
+		session, err := GetStudySession(db, review.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session.EndedAt != nil {
+			return nil, fmt.Errorf("%w: session %d", ErrSessionClosed, session.ID)
+		}
+		review.DeckID = session.DeckID
+	}
+
+	deckID, name, err := GetCardScheduler(db, review.CardID, review.DeckID)
+	if err != nil {
+		return nil, err
+	}
+	scheduler, err := GetScheduler(name)
+	if err != nil {
+		return nil, err
+	}
+	settings := DefaultDeckSettings(deckID)
+	if deckID != 0 {
+		if settings, err = GetDeckSettings(db, deckID); err != nil {
+			return nil, err
+		}
+	}
+	scheduler = settings.Scheduler(scheduler)
+
+	prevInterval, prevLapses := card.Interval, card.Lapses
+	card.MemoryState, card.Due, err = scheduler.Schedule(card.MemoryState, review.Rating, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if card.Lapses > prevLapses {
+		settings.checkLeech(card)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error reviewing card %d: %w", card.ID, err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	if err := UpdateCardSchedule(tx, *card); err != nil {
+		return nil, err
+	}
+	if err := burySiblings(tx, *card, *card.LastReview); err != nil {
+		return nil, err
+	}
+
+	entry := ReviewLog{
+		CardID:       card.ID,
+		Rating:       review.Rating,
+		PrevInterval: prevInterval,
+		NextInterval: card.Interval,
+		LatencyMs:    int(review.Latency.Milliseconds()),
+		ReviewedAt:   *card.LastReview,
+	}
+	if deckID != 0 {
+		entry.DeckID = &deckID
+	}
+	if review.SessionID != 0 {
+		entry.SessionID = &review.SessionID
+	}
+	if _, err := InsertReviewLog(tx, entry); err != nil {
+		return nil, err
+	}
+
+	if review.SessionID != 0 {
+		if err := completeSessionCard(tx, review.SessionID, *card); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error reviewing card %d: %w", card.ID, err)
+	}
+
+	return card, nil
+}
@@ -0,0 +1,418 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetScheduler(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		scheduler, err := GetScheduler("")
+		assert.NoError(t, err)
+		assert.Equal(t, SM2{}, scheduler)
+	})
+
+	t.Run("FSRS", func(t *testing.T) {
+		scheduler, err := GetScheduler(SchedulerFSRS)
+		assert.NoError(t, err)
+		assert.IsType(t, FSRS{}, scheduler)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := GetScheduler("leitner")
+		assert.Error(t, err)
+	})
+}
+
+func TestGetCardScheduler(t *testing.T) {
+	t.Run("Deck scheduler", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(3, SchedulerFSRS))
+
+		deckID, name, err := GetCardScheduler(db, 7, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, deckID)
+		assert.Equal(t, SchedulerFSRS, name)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Card in no deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}))
+
+		deckID, name, err := GetCardScheduler(db, 7, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, deckID)
+		assert.Equal(t, DefaultScheduler, name)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Card in a nested deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("(?s)SELECT d.id, d.scheduler.*WITH RECURSIVE subtree").WithArgs(7, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(4, SchedulerFSRS))
+
+		deckID, name, err := GetCardScheduler(db, 7, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, deckID)
+		assert.Equal(t, SchedulerFSRS, name)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Card not in the deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}))
+
+		_, _, err = GetCardScheduler(db, 7, 3)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestReviewCard(t *testing.T) {
+	selectCard := regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards WHERE id = $1")
+	newCard := Card{ID: 7, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5, State: CardStateNew}, Due: due}
+	lastReview := due.AddDate(0, 0, -1)
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(Card{ID: 7, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5, Interval: 1, Repetitions: 1, LastReview: &lastReview, State: CardStateReview}, Due: due}))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(3, SchedulerSM2))
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(3).WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").
+			WithArgs(2.6, 6, 2, 0.0, 0.0, sqlmock.AnyArg(), 0, CardStateReview, 0, false, sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").
+			WithArgs(7, 3, nil, 5, 1, 6, 1500, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		card, err := ReviewCard(db, Review{CardID: 7, DeckID: 3, Rating: 5, Latency: 1500 * time.Millisecond})
+		assert.NoError(t, err)
+		assert.Equal(t, 6, card.Interval)
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, 6), card.Due, time.Second)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Uses the deck's scheduler", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(newCard))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(3, SchedulerFSRS))
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(3).
+			WillReturnRows(sqlmock.NewRows(deckSettingsColumns).AddRow(20, 200, "{}", 1, 36500, "{}", 0, LeechActionTag))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		card, err := ReviewCard(db, Review{CardID: 7, Rating: 4})
+		assert.NoError(t, err)
+		assert.InDelta(t, DefaultFSRSWeights[2], card.Stability, 1e-9)
+		assert.Equal(t, 2.5, card.EaseFactor)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Applies the deck's settings", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		learningCard := Card{ID: 7, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5, LastReview: &lastReview, State: CardStateLearning}, Due: due}
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(learningCard))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(3, SchedulerSM2))
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(3).
+			WillReturnRows(sqlmock.NewRows(deckSettingsColumns).AddRow(20, 200, "{5}", 3, 36500, "{10}", 8, LeechActionTag))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").
+			WithArgs(2.5, 3, 1, 0.0, 0.0, sqlmock.AnyArg(), 0, CardStateReview, 0, false, sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").
+			WithArgs(7, 3, nil, 4, 0, 3, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		card, err := ReviewCard(db, Review{CardID: 7, DeckID: 3, Rating: 4})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, card.Interval)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Suspends leeches", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		reviewCard := Card{ID: 7, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5, Interval: 6, Repetitions: 2, LastReview: &lastReview, State: CardStateReview, Lapses: 3}, Due: due}
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(reviewCard))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(3, SchedulerSM2))
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(3).
+			WillReturnRows(sqlmock.NewRows(deckSettingsColumns).AddRow(20, 200, "{1}", 1, 36500, "{10}", 4, LeechActionSuspend))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").
+			WithArgs(sqlmock.AnyArg(), 0, 0, 0.0, 0.0, sqlmock.AnyArg(), 0, CardStateSuspended, 4, true, sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").
+			WithArgs(7, 3, nil, 1, 6, 0, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		card, err := ReviewCard(db, Review{CardID: 7, DeckID: 3, Rating: 1})
+		assert.NoError(t, err)
+		assert.True(t, card.Leech)
+		assert.Equal(t, CardStateSuspended, card.State)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Card outside any deck is logged without one", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(newCard))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").
+			WithArgs(7, nil, nil, 3, 0, 0, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		_, err = ReviewCard(db, Review{CardID: 7, Rating: 3})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Buries the card's siblings", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		noteID := 7
+		sibling := newCard
+		sibling.NoteID = &noteID
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(sibling))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE cards SET buried_until = \\$3 WHERE note_id = \\$1 AND id <> \\$2").
+			WithArgs(7, 7, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		_, err = ReviewCard(db, Review{CardID: 7, Rating: 3})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Attached to a study session", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(newCard))
+		mock.ExpectQuery("FROM study_sessions s").WithArgs(8).
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(8, 3, due, nil, 5, 5))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(3, SchedulerSM2))
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(3).WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").
+			WithArgs(7, 3, 8, 4, 0, 0, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("UPDATE study_session_cards SET done = TRUE").WithArgs(8, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		// Still learning, so the card comes round again later in the session.
+		mock.ExpectExec("INSERT INTO study_session_cards").WithArgs(8, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		_, err = ReviewCard(db, Review{CardID: 7, SessionID: 8, Rating: 4})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Log error rolls back the schedule", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(newCard))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").WillReturnError(fmt.Errorf("insert error"))
+		mock.ExpectRollback()
+
+		card, err := ReviewCard(db, Review{CardID: 7, Rating: 3})
+		assert.Error(t, err)
+		assert.Nil(t, card)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Card from a nested deck in a session", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		// The session studies deck 3, and the card is in deck 4 nested in it,
+		// so the card is scheduled and logged with deck 4
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(newCard))
+		mock.ExpectQuery("FROM study_sessions s").WithArgs(8).
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(8, 3, due, nil, 5, 5))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(4, SchedulerSM2))
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(4).WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards SET ease_factor").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO review_log").
+			WithArgs(7, 4, 8, 4, 0, 0, 0, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("UPDATE study_session_cards SET done = TRUE").WithArgs(8, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO study_session_cards").WithArgs(8, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		_, err = ReviewCard(db, Review{CardID: 7, SessionID: 8, Rating: 4})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Closed study session", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(newCard))
+		mock.ExpectQuery("FROM study_sessions s").WithArgs(8).
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(8, 3, due, due, 5, 0))
+
+		card, err := ReviewCard(db, Review{CardID: 7, SessionID: 8, Rating: 4})
+		assert.ErrorIs(t, err, ErrSessionClosed)
+		assert.Nil(t, card)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Card not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(selectCard).WithArgs(7).WillReturnRows(cardRows())
+
+		card, err := ReviewCard(db, Review{CardID: 7, Rating: 5})
+		assert.Error(t, err)
+		assert.Nil(t, card)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(selectCard).
+			WithArgs(7).
+			WillReturnRows(cardRows(newCard))
+		mock.ExpectQuery("SELECT d.id, d.scheduler").WithArgs(7, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(3, SchedulerSM2))
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(3).WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE cards").WillReturnError(fmt.Errorf("update error"))
+		mock.ExpectRollback()
+
+		card, err := ReviewCard(db, Review{CardID: 7, Rating: 3})
+		assert.Error(t, err)
+		assert.Nil(t, card)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// SearchResult is a card matching a search, with the parts of its front and
+// back that match. Snippets are HTML with the matching words in <mark>.
+type SearchResult struct {
+	Card         Card    `json:"card"`
+	Rank         float64 `json:"rank"`
+	FrontSnippet string  `json:"frontSnippet"`
+	BackSnippet  string  `json:"backSnippet"`
+	Decks        []Deck  `json:"decks"` // the decks the card is in
+}
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+var ErrInvalidSearch = errors.New("invalid search")
+
+// Matches are marked with characters from the private use area, which card
+// text is not expected to hold, so that the snippets can be escaped before
+// the marks are turned into HTML.
+const (
+	searchMarkStart = "\uE000"
+	searchMarkStop  = "\uE001"
+)
+
+// searchSQL selects the cards matching the query in $1, best matches first.
+// The cards' search column is kept up to date by Postgres, see CardsTable.
+var searchSQL = `
+    SELECT ` + cardColumns + `, ts_rank(c.search, q) AS rank,
+        ts_headline('english', c.front, q, $2), ts_headline('english', c.back, q, $2),
+        COALESCE((SELECT json_agg(json_build_object('id', d.id, 'name', d.name, 'scheduler', d.scheduler, 'parentId', d.parent_id,
+                'description', d.description, 'color', d.color, 'icon', d.icon, 'archived', d.archived,
+                'createdAt', d.created_at, 'updatedAt', d.updated_at) ORDER BY d.name)
+            FROM deck_cards dc JOIN decks d ON d.id = dc.deck_id WHERE dc.card_id = c.id), '[]')
+    FROM cards c, websearch_to_tsquery('english', $1) q
+    WHERE c.search @@ q
+    ORDER BY rank DESC, c.id
+    LIMIT $3 OFFSET $4`
+
+// SearchCards finds the cards whose front or back match a web search style
+// query, such as "goroutine leak" or "channel -buffered". Matches on the
+// front rank above matches on the back.
+func SearchCards(db *sql.DB, query string, limit int, offset int) (*[]SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("%w: query is empty", ErrInvalidSearch)
+	}
+	if limit <= 0 || limit > MaxSearchLimit || offset < 0 {
+		return nil, fmt.Errorf("%w: limit must be from 1 to %d and offset not negative", ErrInvalidSearch, MaxSearchLimit)
+	}
+
+	options := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MinWords=5, MaxWords=20", searchMarkStart, searchMarkStop)
+	rows, err := db.Query(searchSQL, query, options, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error searching cards: %v", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var decks []byte
+		row := extraColumns{rows, []any{&result.Rank, &result.FrontSnippet, &result.BackSnippet, &decks}}
+		if err := scanCard(row, &result.Card); err != nil {
+			return nil, fmt.Errorf("error scanning search result: %v", err)
+		}
+		if err := json.Unmarshal(decks, &result.Decks); err != nil {
+			return nil, fmt.Errorf("error scanning search result: %v", err)
+		}
+		result.FrontSnippet = markSnippet(result.FrontSnippet)
+		result.BackSnippet = markSnippet(result.BackSnippet)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error searching cards: %v", err)
+	}
+
+	return &results, nil
+}
+
+// extraColumns scans the columns selected after a card's into extra.
+type extraColumns struct {
+	row   rowScanner
+	extra []any
+}
+
+func (e extraColumns) Scan(dest ...any) error {
+	return e.row.Scan(append(dest, e.extra...)...)
+}
+
+// markSnippet escapes a snippet from ts_headline and marks its matches.
+func markSnippet(snippet string) string {
+	return strings.NewReplacer(searchMarkStart, "<mark>", searchMarkStop, "</mark>").Replace(html.EscapeString(snippet))
+}
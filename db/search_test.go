@@ -0,0 +1,56 @@
+package db
+
+import (
+	"database/sql/driver"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchCards(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		card := Card{ID: 4, Type: CardTypeBasic, Front: "What does a <b>goroutine</b> leak?", Back: "Memory", MemoryState: MemoryState{State: CardStateNew}, Due: due, Tags: []string{}}
+		columns := slices.Concat(cardRowColumns, []string{"rank", "front_snippet", "back_snippet", "decks"})
+		values := []driver.Value{card.ID, card.Type, card.Front, card.Back, card.Cloze, card.EaseFactor, card.Interval, card.Repetitions,
+			card.Stability, card.Difficulty, card.LastReview, card.Step, card.State, card.Lapses, card.Leech, card.BuriedUntil, card.NoteID, card.Template, card.Due, []byte("{}"),
+			0.6, "What does a <b>goroutine</b> leak?", "Memory", []byte(`[{"id": 2, "name": "Go", "scheduler": "sm2"}]`)}
+		mock.ExpectQuery("FROM cards c, websearch_to_tsquery\\('english', \\$1\\) q\\s+WHERE c.search @@ q").
+			WithArgs("goroutine", sqlmock.AnyArg(), 20, 0).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(values...))
+
+		results, err := SearchCards(db, "goroutine", DefaultSearchLimit, 0)
+		assert.NoError(t, err)
+		assert.Len(t, *results, 1)
+		result := (*results)[0]
+		assert.Equal(t, card, result.Card)
+		assert.Equal(t, 0.6, result.Rank)
+		assert.Equal(t, "What does a &lt;b&gt;<mark>goroutine</mark>&lt;/b&gt; leak?", result.FrontSnippet)
+		assert.Equal(t, "Memory", result.BackSnippet)
+		assert.Equal(t, []Deck{{ID: 2, Name: "Go", Scheduler: "sm2"}}, result.Decks)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		_, err = SearchCards(db, "  ", DefaultSearchLimit, 0)
+		assert.ErrorIs(t, err, ErrInvalidSearch)
+		_, err = SearchCards(db, "goroutine", MaxSearchLimit+1, 0)
+		assert.ErrorIs(t, err, ErrInvalidSearch)
+		_, err = SearchCards(db, "goroutine", DefaultSearchLimit, -1)
+		assert.ErrorIs(t, err, ErrInvalidSearch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// StudySession is a run through a queue of a deck's cards, kept on the
+// server so that studying survives a page refresh.
+type StudySession struct {
+	ID        int        `json:"id"`
+	DeckID    int        `json:"deckId"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt"`
+	Total     int        `json:"total"`     // cards queued, including requeued learning cards
+	Remaining int        `json:"remaining"` // cards still to be studied
+}
+
+// SessionSummary is returned when a study session is closed.
+type SessionSummary struct {
+	SessionID   int         `json:"sessionId"`
+	CardsSeen   int         `json:"cardsSeen"`
+	Reviews     int         `json:"reviews"`
+	Accuracy    float64     `json:"accuracy"` // share of reviews rated 3 or higher
+	TimeSpentMs int64       `json:"timeSpentMs"`
+	Ratings     map[int]int `json:"ratings"` // number of reviews per rating
+}
+
+var StudySessionsTable = TableSchema{
+	Name: "study_sessions",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS study_sessions (
+        id SERIAL PRIMARY KEY,
+        deck_id INT NOT NULL,
+        started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        ended_at TIMESTAMPTZ,
+        FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE CASCADE
+    );`,
+}
+
+var StudySessionCardsTable = TableSchema{
+	Name: "study_session_cards",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS study_session_cards (
+        session_id INT NOT NULL,
+        position INT NOT NULL,
+        card_id INT NOT NULL,
+        done BOOLEAN NOT NULL DEFAULT FALSE,
+        PRIMARY KEY (session_id, position),
+        FOREIGN KEY (session_id) REFERENCES study_sessions(id) ON DELETE CASCADE,
+        FOREIGN KEY (card_id) REFERENCES cards(id) ON DELETE CASCADE
+    );`,
+}
+
+var ErrSessionClosed = errors.New("study session is closed")
+
+// CreateStudySession starts a session for a deck, queueing the cards that
+// are due now within the deck's daily limits. It returns sql.ErrNoRows if
+// there is no such deck.
+func CreateStudySession(db *sql.DB, deckID int, now time.Time) (*StudySession, error) {
+	if err := checkDeckExists(db, deckID); err != nil {
+		return nil, err
+	}
+	limits, err := GetDueLimits(db, deckID, now)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := GetDueCards(db, deckID, limits, now)
+	if err != nil {
+		return nil, err
+	}
+
+	cardIDs := make(pq.Int64Array, len(*cards))
+	for i, card := range *cards {
+		cardIDs[i] = int64(card.ID)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting study session: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	session := StudySession{DeckID: deckID, Total: len(cardIDs), Remaining: len(cardIDs)}
+	err = tx.QueryRow("INSERT INTO study_sessions (deck_id, started_at) VALUES ($1, $2) RETURNING id, started_at", deckID, now).
+		Scan(&session.ID, &session.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating study session: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO study_session_cards (session_id, position, card_id)
+        SELECT $1, t.position, t.card_id FROM unnest($2::int[]) WITH ORDINALITY AS t(card_id, position)`,
+		session.ID, cardIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error queueing cards for study session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error creating study session: %w", err)
+	}
+
+	return &session, nil
+}
+
+func GetStudySession(db *sql.DB, sessionID int) (*StudySession, error) {
+	var session StudySession
+	err := db.QueryRow(`
+        SELECT s.id, s.deck_id, s.started_at, s.ended_at,
+            COUNT(sc.card_id), COUNT(sc.card_id) FILTER (WHERE NOT sc.done)
+        FROM study_sessions s
+        LEFT JOIN study_session_cards sc ON s.id = sc.session_id
+        WHERE s.id = $1
+        GROUP BY s.id
+    `, sessionID).Scan(&session.ID, &session.DeckID, &session.StartedAt, &session.EndedAt, &session.Total, &session.Remaining)
+	if err != nil {
+		return nil, fmt.Errorf("error getting study session %d: %w", sessionID, err)
+	}
+
+	return &session, nil
+}
+
+// NextSessionCard returns the next card still to be studied in a session
+// that is due at now, passing over cards suspended or buried since the
+// session started. When only cards waiting on a learning step remain, it
+// returns no card and when the first of them is due. It returns an error
+// wrapping sql.ErrNoRows once the queue is exhausted.
+func NextSessionCard(db *sql.DB, sessionID int, now time.Time) (*Card, *time.Time, error) {
+	var card Card
+	err := scanCard(db.QueryRow(`
+        SELECT `+cardColumns+`
+        FROM cards c
+        JOIN study_session_cards sc ON c.id = sc.card_id
+        WHERE sc.session_id = $1 AND NOT sc.done AND `+visibleCardsCondition+`
+        ORDER BY c.due > $2, CASE WHEN c.due > $2 THEN c.due END, sc.position
+        LIMIT 1
+    `, sessionID, now), &card)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting next card for study session %d: %w", sessionID, err)
+	}
+	if card.Due.After(now) {
+		return nil, &card.Due, nil
+	}
+
+	return &card, nil, nil
+}
+
+// SkipSessionCard moves a pending card to the back of a session's queue.
+func SkipSessionCard(db *sql.DB, sessionID int, cardID int) error {
+	_, err := db.Exec(`
+        UPDATE study_session_cards
+        SET position = (SELECT MAX(position) + 1 FROM study_session_cards WHERE session_id = $1)
+        WHERE session_id = $1 AND card_id = $2 AND NOT done
+    `, sessionID, cardID)
+	if err != nil {
+		return fmt.Errorf("error skipping card %d in study session %d: %w", cardID, sessionID, err)
+	}
+
+	return nil
+}
+
+// completeSessionCard marks a card as studied in a session. Cards from the
+// session's queue that are still (re)learning afterwards are queued again at
+// the back of the session, to be shown once their learning step is due.
+func completeSessionCard(db execer, sessionID int, card Card) error {
+	result, err := db.Exec("UPDATE study_session_cards SET done = TRUE WHERE session_id = $1 AND card_id = $2 AND NOT done",
+		sessionID, card.ID)
+	if err != nil {
+		return fmt.Errorf("error completing card %d in study session %d: %w", card.ID, sessionID, err)
+	}
+	completed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error completing card %d in study session %d: %w", card.ID, sessionID, err)
+	}
+
+	if completed > 0 && (card.State == CardStateLearning || card.State == CardStateRelearning) {
+		_, err = db.Exec(`
+            INSERT INTO study_session_cards (session_id, position, card_id)
+            SELECT $1, MAX(position) + 1, $2 FROM study_session_cards WHERE session_id = $1
+        `, sessionID, card.ID)
+		if err != nil {
+			return fmt.Errorf("error requeueing card %d in study session %d: %w", card.ID, sessionID, err)
+		}
+	}
+
+	return nil
+}
+
+// CloseStudySession ends a session and summarises the reviews made in it.
+// Closing a session that is already closed just returns its summary.
+func CloseStudySession(db *sql.DB, sessionID int, now time.Time) (*SessionSummary, error) {
+	var startedAt, endedAt time.Time
+	err := db.QueryRow(`
+        UPDATE study_sessions SET ended_at = COALESCE(ended_at, $2)
+        WHERE id = $1
+        RETURNING started_at, ended_at
+    `, sessionID, now).Scan(&startedAt, &endedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error closing study session %d: %w", sessionID, err)
+	}
+
+	rows, err := db.Query("SELECT card_id, rating FROM review_log WHERE session_id = $1", sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reviews for study session %d: %w", sessionID, err)
+	}
+	defer rows.Close()
+
+	summary := SessionSummary{
+		SessionID:   sessionID,
+		TimeSpentMs: endedAt.Sub(startedAt).Milliseconds(),
+		Ratings:     map[int]int{},
+	}
+	seen := map[int]bool{}
+	correct := 0
+
+	for rows.Next() {
+		var cardID, rating int
+		if err := rows.Scan(&cardID, &rating); err != nil {
+			return nil, fmt.Errorf("error scanning review: %v", err)
+		}
+		seen[cardID] = true
+		summary.Reviews++
+		summary.Ratings[rating]++
+		if rating >= 3 {
+			correct++
+		}
+	}
+
+	summary.CardsSeen = len(seen)
+	if summary.Reviews > 0 {
+		summary.Accuracy = float64(correct) / float64(summary.Reviews)
+	}
+
+	return &summary, nil
+}
@@ -0,0 +1,327 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var sessionColumns = []string{"id", "deck_id", "started_at", "ended_at", "total", "remaining"}
+
+func TestCreateStudySession(t *testing.T) {
+	now := time.Date(2024, time.May, 2, 9, 0, 0, 0, time.UTC)
+	deckExists := regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1)")
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(deckExists).WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery("SELECT new_per_day").WithArgs(5).WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+		mock.ExpectQuery("FROM review_log r").WillReturnRows(sqlmock.NewRows([]string{"new", "reviews"}).AddRow(0, 0))
+		mock.ExpectQuery("UNION ALL").WithArgs(5, now, 20, 100, 200).
+			WillReturnRows(cardRows(Card{ID: 3, Due: due}, Card{ID: 1, Due: due}))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO study_sessions").WithArgs(5, now).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "started_at"}).AddRow(8, now))
+		mock.ExpectExec("INSERT INTO study_session_cards").WithArgs(8, pq.Int64Array{3, 1}).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		session, err := CreateStudySession(db, 5, now)
+		assert.NoError(t, err)
+		assert.Equal(t, &StudySession{ID: 8, DeckID: 5, StartedAt: now, Total: 2, Remaining: 2}, session)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(deckExists).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery("SELECT new_per_day").WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+		mock.ExpectQuery("FROM review_log r").WillReturnRows(sqlmock.NewRows([]string{"new", "reviews"}).AddRow(0, 0))
+		mock.ExpectQuery("UNION ALL").WillReturnRows(cardRows(Card{ID: 3, Due: due}))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO study_sessions").WillReturnRows(sqlmock.NewRows([]string{"id", "started_at"}).AddRow(8, now))
+		mock.ExpectExec("INSERT INTO study_session_cards").WillReturnError(fmt.Errorf("insert error"))
+		mock.ExpectRollback()
+
+		session, err := CreateStudySession(db, 5, now)
+		assert.Error(t, err)
+		assert.Nil(t, session)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Deck not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(deckExists).WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		_, err = CreateStudySession(db, 5, now)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetStudySession(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("FROM study_sessions s").WithArgs(8).
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(8, 5, due, nil, 12, 4))
+
+		session, err := GetStudySession(db, 8)
+		assert.NoError(t, err)
+		assert.Equal(t, &StudySession{ID: 8, DeckID: 5, StartedAt: due, Total: 12, Remaining: 4}, session)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("FROM study_sessions s").WithArgs(8).WillReturnRows(sqlmock.NewRows(sessionColumns))
+
+		_, err = GetStudySession(db, 8)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestNextSessionCard(t *testing.T) {
+	now := due.Add(time.Minute)
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		expected := Card{ID: 3, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5}, Due: due}
+		mock.ExpectQuery("JOIN study_session_cards sc").WithArgs(8, now).WillReturnRows(cardRows(expected))
+
+		card, waitUntil, err := NextSessionCard(db, 8, now)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, *card)
+		assert.Nil(t, waitUntil)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Learning step not due yet", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		learning := Card{ID: 3, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5, State: CardStateLearning}, Due: now.Add(10 * time.Minute)}
+		mock.ExpectQuery("JOIN study_session_cards sc").WithArgs(8, now).WillReturnRows(cardRows(learning))
+
+		card, waitUntil, err := NextSessionCard(db, 8, now)
+		assert.NoError(t, err)
+		assert.Nil(t, card)
+		assert.Equal(t, learning.Due, *waitUntil)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Queue exhausted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("JOIN study_session_cards sc").WithArgs(8, now).WillReturnRows(cardRows())
+
+		card, _, err := NextSessionCard(db, 8, now)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Nil(t, card)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCompleteSessionCard(t *testing.T) {
+	learning := Card{ID: 7, MemoryState: MemoryState{State: CardStateLearning}, Due: due}
+
+	t.Run("Requeues learning cards", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE study_session_cards SET done = TRUE").WithArgs(8, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO study_session_cards").WithArgs(8, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, completeSessionCard(db, 8, learning))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Card not in the session", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE study_session_cards SET done = TRUE").WithArgs(8, 7).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.NoError(t, completeSessionCard(db, 8, learning))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TestStudyNestedDeck follows a card in a deck nested in the studied deck
+// through a whole session, from queueing it to reviewing it.
+func TestStudyNestedDeck(t *testing.T) {
+	now := due.Add(time.Minute)
+	nested := Card{ID: 7, Front: "Front", Back: "Back", MemoryState: MemoryState{EaseFactor: 2.5, State: CardStateNew}, Due: due}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	// Deck 3's limits count reviews in the decks nested in it, and its due
+	// cards include card 7 from deck 4
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM decks WHERE id = $1)")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT new_per_day").WithArgs(3).WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+	mock.ExpectQuery("(?s)FROM review_log r.*WHERE r.deck_id IN \\(WITH RECURSIVE subtree").WithArgs(3, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"new", "reviews"}).AddRow(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(inDeckSubtree)).WithArgs(3, now, 20, 100, 200).
+		WillReturnRows(cardRows(nested))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO study_sessions").WithArgs(3, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "started_at"}).AddRow(8, now))
+	mock.ExpectExec("INSERT INTO study_session_cards").WithArgs(8, pq.Int64Array{7}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	session, err := CreateStudySession(db, 3, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, session.Total)
+
+	mock.ExpectQuery("JOIN study_session_cards sc").WithArgs(8, now).WillReturnRows(cardRows(nested))
+
+	card, _, err := NextSessionCard(db, session.ID, now)
+	assert.NoError(t, err)
+	assert.Equal(t, nested, *card)
+
+	// The review uses deck 4's scheduler and settings and is logged with it
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + cardColumns + " FROM cards WHERE id = $1")).WithArgs(7).
+		WillReturnRows(cardRows(nested))
+	mock.ExpectQuery("FROM study_sessions s").WithArgs(8).
+		WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(8, 3, now, nil, 1, 1))
+	mock.ExpectQuery("(?s)SELECT d.id, d.scheduler.*WITH RECURSIVE subtree").WithArgs(7, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "scheduler"}).AddRow(4, SchedulerSM2))
+	mock.ExpectQuery("SELECT new_per_day").WithArgs(4).WillReturnRows(sqlmock.NewRows(deckSettingsColumns))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE cards SET ease_factor").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO review_log").WithArgs(7, 4, 8, 4, 0, 0, 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE study_session_cards SET done = TRUE").WithArgs(8, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO study_session_cards").WithArgs(8, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = ReviewCard(db, Review{CardID: card.ID, SessionID: session.ID, Rating: 4})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloseStudySession(t *testing.T) {
+	now := due.Add(15 * time.Minute)
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("UPDATE study_sessions SET ended_at").WithArgs(8, now).
+			WillReturnRows(sqlmock.NewRows([]string{"started_at", "ended_at"}).AddRow(due, now))
+		mock.ExpectQuery("SELECT card_id, rating FROM review_log").WithArgs(8).
+			WillReturnRows(sqlmock.NewRows([]string{"card_id", "rating"}).
+				AddRow(3, 2).
+				AddRow(1, 4).
+				AddRow(3, 4).
+				AddRow(2, 5))
+
+		summary, err := CloseStudySession(db, 8, now)
+		assert.NoError(t, err)
+		assert.Equal(t, &SessionSummary{
+			SessionID:   8,
+			CardsSeen:   3,
+			Reviews:     4,
+			Accuracy:    0.75,
+			TimeSpentMs: (15 * time.Minute).Milliseconds(),
+			Ratings:     map[int]int{2: 1, 4: 2, 5: 1},
+		}, summary)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No reviews", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("UPDATE study_sessions SET ended_at").
+			WillReturnRows(sqlmock.NewRows([]string{"started_at", "ended_at"}).AddRow(due, now))
+		mock.ExpectQuery("SELECT card_id, rating FROM review_log").WillReturnRows(sqlmock.NewRows([]string{"card_id", "rating"}))
+
+		summary, err := CloseStudySession(db, 8, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, summary.CardsSeen)
+		assert.Equal(t, 0.0, summary.Accuracy)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("UPDATE study_sessions SET ended_at").
+			WillReturnRows(sqlmock.NewRows([]string{"started_at", "ended_at"}))
+
+		_, err = CloseStudySession(db, 8, now)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
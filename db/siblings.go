@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// InsertCardWithReverse inserts card along with a reverse card that has its
+// front and back swapped. The two are siblings on a "Basic (and reversed
+// card)" note, so they are never studied on the same day. It returns both
+// IDs.
+func InsertCardWithReverse(db *sql.DB, card Card) ([]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error inserting card: %w", err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	ids, err := insertCardWithReverse(tx, card)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error inserting card: %w", err)
+	}
+
+	return ids, nil
+}
+
+func insertCardWithReverse(tx *sql.Tx, card Card) ([]int, error) {
+	noteID, err := insertBuiltinNote(tx, NoteTypeReversed, map[string]string{"Front": card.Front, "Back": card.Back})
+	if err != nil {
+		return nil, err
+	}
+
+	card.Type, card.NoteID, card.Template = CardTypeBasic, &noteID, 0
+	reverse := card
+	reverse.Front, reverse.Back, reverse.Template = card.Back, card.Front, 1
+
+	var ids []int
+	for _, c := range []Card{card, reverse} {
+		id, err := insertCard(tx, c)
+		if err != nil {
+			return nil, fmt.Errorf("error inserting card: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// burySiblings hides the other cards from a card's note until the day after
+// now, once the card has been reviewed.
+func burySiblings(db execer, card Card, now time.Time) error {
+	if card.NoteID == nil {
+		return nil
+	}
+
+	_, err := db.Exec("UPDATE cards SET buried_until = $3 WHERE note_id = $1 AND id <> $2",
+		*card.NoteID, card.ID, startOfNextDay(now))
+	if err != nil {
+		return fmt.Errorf("error burying siblings of card %d: %w", card.ID, err)
+	}
+
+	return nil
+}
+
+// withoutSiblings drops every card that has a sibling earlier in cards.
+func withoutSiblings(cards []Card) []Card {
+	notes := map[int]bool{}
+	kept := cards[:0]
+	for _, card := range cards {
+		if card.NoteID != nil {
+			if notes[*card.NoteID] {
+				continue
+			}
+			notes[*card.NoteID] = true
+		}
+		kept = append(kept, card)
+	}
+	return kept
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertCardWithReverse(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		expectBuiltinNote(mock, NoteTypeReversed, 30)
+		mock.ExpectQuery("INSERT INTO cards").
+			WithArgs(CardTypeBasic, "hablar", "to speak", 0, 2.5, 0, 0, 0.0, 0.0, nil, 0, CardStateNew, 0, false, 30, 0, due).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectQuery("INSERT INTO cards").
+			WithArgs(CardTypeBasic, "to speak", "hablar", 0, 2.5, 0, 0, 0.0, 0.0, nil, 0, CardStateNew, 0, false, 30, 1, due).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectCommit()
+
+		card := Card{Front: "hablar", Back: "to speak", MemoryState: MemoryState{EaseFactor: 2.5}, Due: due}
+		ids, err := InsertCardWithReverse(db, card)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{7, 8}, ids)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		expectBuiltinNote(mock, NoteTypeReversed, 30)
+		mock.ExpectQuery("INSERT INTO cards").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectQuery("INSERT INTO cards").WillReturnError(fmt.Errorf("insert error"))
+		mock.ExpectRollback()
+
+		_, err = InsertCardWithReverse(db, Card{Front: "hablar", Back: "to speak"})
+		assert.EqualError(t, err, "error inserting card: insert error")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetDueCardsSkipsSiblings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	noteID := 7
+	now := time.Date(2024, time.May, 2, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT .* FROM \\(").WillReturnRows(cardRows(
+		Card{ID: 7, Front: "hablar", Back: "to speak", NoteID: &noteID, Due: due},
+		Card{ID: 8, Front: "to speak", Back: "hablar", NoteID: &noteID, Due: due},
+		Card{ID: 9, Front: "comer", Back: "to eat", Due: due},
+	))
+
+	cards, err := GetDueCards(db, 1, DefaultDueLimits, now)
+	assert.NoError(t, err)
+	assert.Len(t, *cards, 2)
+	assert.Equal(t, 7, (*cards)[0].ID)
+	assert.Equal(t, 9, (*cards)[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// DefaultEaseFactor is the ease factor every new card starts with.
+	DefaultEaseFactor = 2.5
+	// MinEaseFactor is the floor SM-2 never lets the ease factor drop below.
+	MinEaseFactor = 1.3
+)
+
+// SM2 is the classic SuperMemo-2 scheduler. Ratings map directly onto
+// SM-2's quality scale, with 1 a complete blackout and 5 perfect recall.
+type SM2 struct{}
+
+func (SM2) Schedule(state MemoryState, rating int, now time.Time) (MemoryState, time.Time, error) {
+	if err := validateRating(rating); err != nil {
+		return state, time.Time{}, err
+	}
+
+	if state.EaseFactor == 0 {
+		state.EaseFactor = DefaultEaseFactor
+	}
+
+	// Anything below 3 counts as a failed recall and restarts the repetitions.
+	if rating < 3 {
+		state.Repetitions = 0
+		state.Interval = 1
+	} else {
+		switch state.Repetitions {
+		case 0:
+			state.Interval = 1
+		case 1:
+			state.Interval = 6
+		default:
+			state.Interval = int(math.Round(float64(state.Interval) * state.EaseFactor))
+		}
+		state.Repetitions++
+	}
+
+	q := float64(MaxRating - rating)
+	state.EaseFactor = math.Max(MinEaseFactor, state.EaseFactor+0.1-q*(0.08+q*0.02))
+	state.LastReview = &now
+
+	return state, now.AddDate(0, 0, state.Interval), nil
+}
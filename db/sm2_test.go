@@ -0,0 +1,54 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSM2Schedule(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("First successful review", func(t *testing.T) {
+		state, due, err := SM2{}.Schedule(MemoryState{EaseFactor: DefaultEaseFactor}, 4, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, state.Interval)
+		assert.Equal(t, 1, state.Repetitions)
+		assert.InDelta(t, 2.5, state.EaseFactor, 1e-9)
+		assert.Equal(t, now, *state.LastReview)
+		assert.Equal(t, now.AddDate(0, 0, 1), due)
+	})
+
+	t.Run("Second successful review", func(t *testing.T) {
+		state, _, err := SM2{}.Schedule(MemoryState{EaseFactor: DefaultEaseFactor, Interval: 1, Repetitions: 1}, 5, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, state.Interval)
+		assert.Equal(t, 2, state.Repetitions)
+		assert.InDelta(t, 2.6, state.EaseFactor, 1e-9)
+	})
+
+	t.Run("Later reviews multiply by ease factor", func(t *testing.T) {
+		state, due, err := SM2{}.Schedule(MemoryState{EaseFactor: 2.5, Interval: 6, Repetitions: 2}, 3, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 15, state.Interval)
+		assert.Equal(t, 3, state.Repetitions)
+		assert.InDelta(t, 2.36, state.EaseFactor, 1e-9)
+		assert.Equal(t, now.AddDate(0, 0, 15), due)
+	})
+
+	t.Run("Failed review restarts repetitions", func(t *testing.T) {
+		state, _, err := SM2{}.Schedule(MemoryState{EaseFactor: 1.4, Interval: 30, Repetitions: 5}, 1, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, state.Interval)
+		assert.Equal(t, 0, state.Repetitions)
+		assert.Equal(t, MinEaseFactor, state.EaseFactor)
+	})
+
+	t.Run("Rating out of range", func(t *testing.T) {
+		_, _, err := SM2{}.Schedule(MemoryState{}, 6, now)
+		assert.Error(t, err)
+		_, _, err = SM2{}.Schedule(MemoryState{}, 0, now)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DeckPathSeparator separates the names of nested decks in a deck path, such
+// as "Go::Concurrency::Channels", as Anki does.
+const DeckPathSeparator = "::"
+
+var ErrInvalidParentDeck = errors.New("invalid parent deck")
+
+// deckSubtree returns a query selecting the ID of the deck at param and the
+// IDs of every deck nested in it, however deep.
+func deckSubtree(param string) string {
+	return `WITH RECURSIVE subtree (id) AS (
+            SELECT id FROM decks WHERE id = ` + param + `
+            UNION SELECT d.id FROM decks d JOIN subtree s ON d.parent_id = s.id
+        ) SELECT id FROM subtree`
+}
+
+// InsertSubdeck adds a deck nested in another.
+func InsertSubdeck(db *sql.DB, deckName string, parentID int) (int64, error) {
+	var id int64
+	err := db.QueryRow("INSERT INTO decks (name, parent_id) VALUES ($1, $2) RETURNING id", deckName, parentID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating deck %q in deck %d: %w", deckName, parentID, err)
+	}
+	return id, nil
+}
+
+// MoveDeck nests a deck, along with the decks nested in it, in another deck,
+// or moves it to the top level when parentID is nil. A deck cannot be moved
+// into itself or into a deck nested in it.
+func MoveDeck(db *sql.DB, deckID int, parentID *int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error moving deck %d: %w", deckID, err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	if parentID != nil {
+		var exists, nested bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM decks WHERE id = $2), $2 IN ("+deckSubtree("$1")+")", deckID, *parentID).Scan(&exists, &nested)
+		if err != nil {
+			return fmt.Errorf("error moving deck %d: %w", deckID, err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: deck %d does not exist", ErrInvalidParentDeck, *parentID)
+		}
+		if nested {
+			return fmt.Errorf("%w: deck %d cannot be moved into itself or a deck nested in it", ErrInvalidParentDeck, deckID)
+		}
+	}
+
+	res, err := tx.Exec("UPDATE decks SET parent_id = $1 WHERE id = $2", parentID, deckID)
+	if err != nil {
+		return fmt.Errorf("error moving deck %d: %w", deckID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("error moving deck %d: %w", deckID, sql.ErrNoRows)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error moving deck %d: %w", deckID, err)
+	}
+
+	return nil
+}
+
+// buildDeckTree nests decks in their parents, keeping their order. Decks
+// whose parent is not in the list are put at the top level.
+func buildDeckTree(decks []Deck) []Deck {
+	ids := map[int]bool{}
+	for _, deck := range decks {
+		ids[deck.ID] = true
+	}
+
+	roots := []Deck{}
+	children := map[int][]Deck{}
+	for _, deck := range decks {
+		if deck.ParentID != nil && ids[*deck.ParentID] {
+			children[*deck.ParentID] = append(children[*deck.ParentID], deck)
+		} else {
+			roots = append(roots, deck)
+		}
+	}
+
+	var build func(deck Deck) Deck
+	build = func(deck Deck) Deck {
+		for _, child := range children[deck.ID] {
+			deck.Children = append(deck.Children, build(child))
+		}
+		return deck
+	}
+	for i, deck := range roots {
+		roots[i] = build(deck)
+	}
+
+	return roots
+}
+
+// deckPaths returns the path of each deck in a list by ID, such as
+// "Go::Concurrency" for a deck named Concurrency nested in one named Go.
+func deckPaths(decks []Deck) map[int]string {
+	byID := map[int]Deck{}
+	for _, deck := range decks {
+		byID[deck.ID] = deck
+	}
+
+	paths := map[int]string{}
+	var path func(deck Deck, depth int) string
+	path = func(deck Deck, depth int) string {
+		if p, ok := paths[deck.ID]; ok {
+			return p
+		}
+		p := deck.Name
+		// A deck can be nested no deeper than there are decks
+		if deck.ParentID != nil && depth < len(decks) {
+			if parent, ok := byID[*deck.ParentID]; ok {
+				p = path(parent, depth+1) + DeckPathSeparator + deck.Name
+			}
+		}
+		paths[deck.ID] = p
+		return p
+	}
+	for _, deck := range decks {
+		path(deck, 0)
+	}
+
+	return paths
+}
+
+// deckForPath returns the ID of the deck at a deck path, creating the decks
+// along it that do not exist yet. deckIDs maps the paths of the decks that
+// exist to their IDs, and has the created decks added to it.
+func deckForPath(db *sql.DB, path string, deckIDs map[string]int) (int, error) {
+	if id, ok := deckIDs[path]; ok {
+		return id, nil
+	}
+
+	var id int64
+	var err error
+	parentPath, name, nested := cutLastDeckName(path)
+	if nested {
+		parentID, err := deckForPath(db, parentPath, deckIDs)
+		if err != nil {
+			return 0, err
+		}
+		id, err = InsertSubdeck(db, name, parentID)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		id, err = InsertDeck(db, path)
+		if err != nil {
+			return 0, fmt.Errorf("error creating deck %q: %w", path, err)
+		}
+	}
+
+	deckIDs[path] = int(id)
+	return int(id), nil
+}
+
+// cutLastDeckName splits the last deck name off a deck path, reporting
+// whether the path was nested. Empty names, as in "Go::", are not split off.
+func cutLastDeckName(path string) (parent string, name string, nested bool) {
+	i := strings.LastIndex(path, DeckPathSeparator)
+	if i <= 0 || i+len(DeckPathSeparator) == len(path) {
+		return "", path, false
+	}
+	return path[:i], path[i+len(DeckPathSeparator):], true
+}
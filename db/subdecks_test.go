@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDecksDataNested(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	goID, concurrencyID := 1, 3
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deckColumns + " FROM decks ORDER BY name, id")).WillReturnRows(deckRows(
+		Deck{ID: 4, Name: "Channels", Scheduler: SchedulerSM2, ParentID: &concurrencyID},
+		Deck{ID: 3, Name: "Concurrency", Scheduler: SchedulerSM2, ParentID: &goID},
+		Deck{ID: 1, Name: "Go", Scheduler: SchedulerSM2},
+		Deck{ID: 5, Name: "Mutexes", Scheduler: SchedulerSM2, ParentID: &concurrencyID},
+		Deck{ID: 2, Name: "Spanish", Scheduler: SchedulerFSRS},
+	))
+
+	decks, err := GetDecksData(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []Deck{
+		{ID: 1, Name: "Go", Scheduler: SchedulerSM2, Children: []Deck{
+			{ID: 3, Name: "Concurrency", Scheduler: SchedulerSM2, ParentID: &goID, Children: []Deck{
+				{ID: 4, Name: "Channels", Scheduler: SchedulerSM2, ParentID: &concurrencyID},
+				{ID: 5, Name: "Mutexes", Scheduler: SchedulerSM2, ParentID: &concurrencyID},
+			}},
+		}},
+		{ID: 2, Name: "Spanish", Scheduler: SchedulerFSRS},
+	}, *decks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeckPaths(t *testing.T) {
+	goID, concurrencyID, loopID := 1, 3, 6
+	paths := deckPaths([]Deck{
+		{ID: 4, Name: "Channels", ParentID: &concurrencyID},
+		{ID: 3, Name: "Concurrency", ParentID: &goID},
+		{ID: 1, Name: "Go"},
+		{ID: 6, Name: "Loop", ParentID: &loopID},
+	})
+	assert.Equal(t, "Go::Concurrency::Channels", paths[4])
+	assert.Equal(t, "Go::Concurrency", paths[3])
+	assert.Equal(t, "Go", paths[1])
+	assert.Contains(t, paths[6], "Loop")
+}
+
+func TestDeckForPath(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	// Go exists, Concurrency and Channels are created in it
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO decks (name, parent_id)")).WithArgs("Concurrency", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO decks (name, parent_id)")).WithArgs("Channels", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	deckIDs := map[string]int{"Go": 1}
+	id, err := deckForPath(db, "Go::Concurrency::Channels", deckIDs)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, id)
+	assert.Equal(t, map[string]int{"Go": 1, "Go::Concurrency": 3, "Go::Concurrency::Channels": 4}, deckIDs)
+
+	id, err = deckForPath(db, "Go::Concurrency", deckIDs)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveDeck(t *testing.T) {
+	checkQuery := regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM decks WHERE id = $2), $2 IN (" + deckSubtree("$1") + ")")
+	updateQuery := regexp.QuoteMeta("UPDATE decks SET parent_id = $1 WHERE id = $2")
+	parentID := 1
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(checkQuery).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows([]string{"exists", "nested"}).AddRow(true, false))
+		mock.ExpectExec(updateQuery).WithArgs(1, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = MoveDeck(db, 3, &parentID)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("To the top level", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(updateQuery).WithArgs(nil, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = MoveDeck(db, 3, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Into a nested deck", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(checkQuery).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows([]string{"exists", "nested"}).AddRow(true, true))
+		mock.ExpectRollback()
+
+		err = MoveDeck(db, 3, &parentID)
+		assert.ErrorIs(t, err, ErrInvalidParentDeck)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing parent", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(checkQuery).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows([]string{"exists", "nested"}).AddRow(false, false))
+		mock.ExpectRollback()
+
+		err = MoveDeck(db, 3, &parentID)
+		assert.ErrorIs(t, err, ErrInvalidParentDeck)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Deck not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(updateQuery).WithArgs(nil, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err = MoveDeck(db, 3, nil)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// visibleCardsCondition matches the cards c that may be shown for study:
+// those that are not suspended and not buried.
+const visibleCardsCondition = "c.state <> 'suspended' AND (c.buried_until IS NULL OR c.buried_until <= NOW())"
+
+// SuspendCard takes a card out of study until it is unsuspended. Its
+// schedule is kept so it carries on where it left off, along with its state,
+// such as learning and the step it is on, in suspended_state.
+func SuspendCard(db *sql.DB, cardID int) error {
+	return updateCardVisibility(db, cardID, "suspending", `
+        UPDATE cards SET state = 'suspended',
+            suspended_state = CASE WHEN state = 'suspended' THEN suspended_state ELSE state END
+        WHERE id = $1`)
+}
+
+// UnsuspendCard returns a suspended card to the state it was suspended in.
+// Cards suspended without one being saved, such as leeches and imported
+// cards, get the state their schedule implies. Cards that are not suspended
+// are left as they are.
+func UnsuspendCard(db *sql.DB, cardID int) error {
+	return updateCardVisibility(db, cardID, "unsuspending", `
+        UPDATE cards SET state = CASE
+            WHEN state <> 'suspended' THEN state
+            WHEN suspended_state IS NOT NULL THEN suspended_state
+            WHEN last_review IS NULL THEN 'new'
+            WHEN interval_days = 0 AND lapses > 0 THEN 'relearning'
+            WHEN interval_days = 0 THEN 'learning'
+            ELSE 'review'
+        END, suspended_state = NULL
+        WHERE id = $1`)
+}
+
+// BuryCard hides a card from study until the start of the day after now.
+func BuryCard(db *sql.DB, cardID int, now time.Time) error {
+	return updateCardVisibility(db, cardID, "burying", "UPDATE cards SET buried_until = $2 WHERE id = $1", startOfNextDay(now))
+}
+
+func startOfNextDay(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+}
+
+func UnburyCard(db *sql.DB, cardID int) error {
+	return updateCardVisibility(db, cardID, "unburying", "UPDATE cards SET buried_until = NULL WHERE id = $1")
+}
+
+func updateCardVisibility(db *sql.DB, cardID int, action string, query string, args ...any) error {
+	res, err := db.Exec(query, append([]any{cardID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("error %s card %d: %w", action, cardID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("error %s card %d: %w", action, cardID, sql.ErrNoRows)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuspendCard(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		// The card's state is saved to be restored when it is unsuspended
+		mock.ExpectExec("UPDATE cards SET state = 'suspended',\\s+suspended_state = CASE WHEN state = 'suspended' THEN suspended_state ELSE state END").
+			WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, SuspendCard(db, 7))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Card not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE cards SET state = 'suspended'").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, SuspendCard(db, 7), sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE cards SET state").WillReturnError(fmt.Errorf("update error"))
+
+		assert.EqualError(t, SuspendCard(db, 7), "error suspending card 7: update error")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUnsuspendCard(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("(?s)UPDATE cards SET state = CASE.*WHEN suspended_state IS NOT NULL THEN suspended_state.*suspended_state = NULL").
+		WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, UnsuspendCard(db, 7))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuryCard(t *testing.T) {
+	t.Run("Hidden until tomorrow", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		now := time.Date(2024, time.May, 31, 18, 45, 0, 0, time.UTC)
+		mock.ExpectExec("UPDATE cards SET buried_until").
+			WithArgs(7, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, BuryCard(db, 7, now))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unbury", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("UPDATE cards SET buried_until = NULL").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, UnburyCard(db, 7))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,332 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Tag is a label on cards, listed with the number of cards that have it.
+type Tag struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Cards int    `json:"cards"`
+}
+
+var (
+	ErrInvalidTag       = errors.New("invalid tag")
+	ErrInvalidTagFilter = errors.New("invalid tag filter")
+)
+
+const maxTagLength = 64
+
+var TagsTable = TableSchema{
+	Name: "tags",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS tags (
+        id SERIAL PRIMARY KEY,
+        name TEXT NOT NULL UNIQUE
+    );`,
+}
+
+// CardTagsTable also defines card_tag_names, which cardColumns uses to read
+// a card's tags along with the card.
+var CardTagsTable = TableSchema{
+	Name: "card_tags",
+	CreateSQL: `CREATE TABLE IF NOT EXISTS card_tags (
+        card_id INT NOT NULL,
+        tag_id INT NOT NULL,
+        PRIMARY KEY (card_id, tag_id),
+        FOREIGN KEY (card_id) REFERENCES cards(id) ON DELETE CASCADE,
+        FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+    );
+    CREATE INDEX IF NOT EXISTS card_tags_tag_id_idx ON card_tags (tag_id);
+    CREATE OR REPLACE FUNCTION card_tag_names(INT) RETURNS TEXT[] AS $$
+        SELECT COALESCE(array_agg(t.name ORDER BY t.name), '{}')
+        FROM card_tags ct JOIN tags t ON t.id = ct.tag_id WHERE ct.card_id = $1
+    $$ LANGUAGE SQL STABLE;`,
+}
+
+// NormalizeTag returns a tag name in the form it is stored in. Tags are
+// lowercase, and cannot hold spaces or parentheses, which separate tags in
+// imports and tag filters.
+func NormalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("%w: tag is empty", ErrInvalidTag)
+	}
+	if len(name) > maxTagLength {
+		return "", fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidTag, name, maxTagLength)
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == '(' || r == ')' }) {
+		return "", fmt.Errorf("%w: %q has spaces or parentheses", ErrInvalidTag, name)
+	}
+	return name, nil
+}
+
+// GetTags returns every tag with the number of cards that have it, by name.
+func GetTags(db *sql.DB) (*[]Tag, error) {
+	rows, err := db.Query(`SELECT t.id, t.name, COUNT(ct.card_id) FROM tags t
+        LEFT JOIN card_tags ct ON ct.tag_id = t.id
+        GROUP BY t.id, t.name ORDER BY t.name`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Cards); err != nil {
+			return nil, fmt.Errorf("error scanning tag: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting tags: %v", err)
+	}
+
+	return &tags, nil
+}
+
+// AddCardTags tags a card, creating tags that do not exist yet. Tags the
+// card already has are left as they are.
+func AddCardTags(db *sql.DB, cardID int, tags []string) error {
+	names, err := normalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	return addCardTags(db, cardID, names)
+}
+
+// SetCardTags replaces a card's tags.
+func SetCardTags(db *sql.DB, cardID int, tags []string) error {
+	names, err := normalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error setting tags for card %d: %w", cardID, err)
+	}
+	defer tx.Rollback() // trunk-ignore(golangci-lint/errcheck)
+
+	if _, err := tx.Exec("DELETE FROM card_tags WHERE card_id = $1", cardID); err != nil {
+		return fmt.Errorf("error setting tags for card %d: %w", cardID, err)
+	}
+	if err := addCardTags(tx, cardID, names); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error setting tags for card %d: %w", cardID, err)
+	}
+
+	return nil
+}
+
+// RemoveCardTag takes a tag off a card. The tag itself is kept, even when no
+// cards are left with it.
+func RemoveCardTag(db *sql.DB, cardID int, tag string) error {
+	name, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM card_tags WHERE card_id = $1 AND tag_id = (SELECT id FROM tags WHERE name = $2)", cardID, name)
+	if err != nil {
+		return fmt.Errorf("error removing tag %q from card %d: %w", name, cardID, err)
+	}
+	return nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		name, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// addCardTags tags a card with already normalised tag names.
+func addCardTags(db execer, cardID int, names []string) error {
+	for _, name := range names {
+		_, err := db.Exec(`WITH tag AS (
+            INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id
+        )
+        INSERT INTO card_tags (card_id, tag_id) SELECT $2, id FROM tag ON CONFLICT DO NOTHING`, name, cardID)
+		if err != nil {
+			return fmt.Errorf("error tagging card %d with %q: %w", cardID, name, err)
+		}
+	}
+	return nil
+}
+
+// A TagFilter selects cards by their tags. Filters are written like
+//
+//	irregular-verbs AND NOT (mastered OR leech)
+//
+// where AND, OR and NOT must be in capitals, AND binds tighter than OR, and
+// tags next to each other without an operator are ANDed.
+type TagFilter struct {
+	expr tagExpr
+}
+
+type tagExpr interface {
+	// sql returns the condition selecting the cards c the expression
+	// matches, adding its arguments to args.
+	sql(args *[]any) string
+}
+
+type (
+	tagName string
+	tagNot  struct{ x tagExpr }
+	tagAnd  struct{ x, y tagExpr }
+	tagOr   struct{ x, y tagExpr }
+)
+
+func (t tagName) sql(args *[]any) string {
+	*args = append(*args, string(t))
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM card_tags ct JOIN tags t ON t.id = ct.tag_id WHERE ct.card_id = c.id AND t.name = $%d)", len(*args))
+}
+
+func (t tagNot) sql(args *[]any) string {
+	return "NOT " + t.x.sql(args)
+}
+
+func (t tagAnd) sql(args *[]any) string {
+	return "(" + t.x.sql(args) + " AND " + t.y.sql(args) + ")"
+}
+
+func (t tagOr) sql(args *[]any) string {
+	return "(" + t.x.sql(args) + " OR " + t.y.sql(args) + ")"
+}
+
+// ParseTagFilter parses a tag filter. Several filters, such as from repeated
+// query parameters, must all match.
+func ParseTagFilter(filters ...string) (*TagFilter, error) {
+	var expr tagExpr
+	for _, filter := range filters {
+		p := tagParser{tokens: tokenizeTagFilter(filter)}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.tokens) {
+			return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidTagFilter, p.tokens[p.pos])
+		}
+		if expr == nil {
+			expr = x
+		} else {
+			expr = tagAnd{expr, x}
+		}
+	}
+	if expr == nil {
+		return nil, fmt.Errorf("%w: filter is empty", ErrInvalidTagFilter)
+	}
+
+	return &TagFilter{expr: expr}, nil
+}
+
+// condition returns the SQL condition selecting the cards c the filter
+// matches, adding its arguments to args.
+func (f TagFilter) condition(args *[]any) string {
+	return f.expr.sql(args)
+}
+
+func tokenizeTagFilter(filter string) []string {
+	filter = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(filter)
+	return strings.Fields(filter)
+}
+
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *tagParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagParser) parseOr() (tagExpr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = tagOr{x, y}
+	}
+	return x, nil
+}
+
+func (p *tagParser) parseAnd() (tagExpr, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case "AND":
+			p.pos++
+		case "", "OR", ")":
+			return x, nil
+		}
+		y, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		x = tagAnd{x, y}
+	}
+}
+
+func (p *tagParser) parseNot() (tagExpr, error) {
+	token := p.peek()
+	p.pos++
+	switch token {
+	case "NOT":
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{x}, nil
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("%w: missing )", ErrInvalidTagFilter)
+		}
+		p.pos++
+		return x, nil
+	case "", "AND", "OR", ")":
+		return nil, fmt.Errorf("%w: expected a tag, got %q", ErrInvalidTagFilter, token)
+	}
+
+	name, err := NormalizeTag(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTagFilter, err)
+	}
+	return tagName(name), nil
+}
+
+// WithTags only returns cards matching a tag filter.
+func WithTags(filter *TagFilter) CardOption {
+	return func(opts *cardOptions) {
+		opts.tags = filter
+	}
+}
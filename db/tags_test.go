@@ -0,0 +1,165 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	name, err := NormalizeTag(" Irregular-Verbs ")
+	assert.NoError(t, err)
+	assert.Equal(t, "irregular-verbs", name)
+
+	for _, tag := range []string{"", "  ", "two words", "verbs(ar)", string(make([]byte, maxTagLength+1))} {
+		_, err := NormalizeTag(tag)
+		assert.ErrorIs(t, err, ErrInvalidTag, tag)
+	}
+}
+
+// tagCondition is the condition a tag filter gives for the tag at $n.
+func tagCondition(n int) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM card_tags ct JOIN tags t ON t.id = ct.tag_id WHERE ct.card_id = c.id AND t.name = $%d)", n)
+}
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		filters   []string
+		condition string
+		args      []any
+	}{
+		{"Tag", []string{"Irregular-Verbs"}, tagCondition(2), []any{"irregular-verbs"}},
+		{"AND", []string{"verbs AND irregular"}, "(" + tagCondition(2) + " AND " + tagCondition(3) + ")", []any{"verbs", "irregular"}},
+		{"Implicit AND", []string{"verbs irregular"}, "(" + tagCondition(2) + " AND " + tagCondition(3) + ")", []any{"verbs", "irregular"}},
+		{"OR", []string{"ar OR er"}, "(" + tagCondition(2) + " OR " + tagCondition(3) + ")", []any{"ar", "er"}},
+		{"NOT", []string{"NOT mastered"}, "NOT " + tagCondition(2), []any{"mastered"}},
+		{
+			"AND binds tighter than OR",
+			[]string{"ar OR er AND irregular"},
+			"(" + tagCondition(2) + " OR (" + tagCondition(3) + " AND " + tagCondition(4) + "))",
+			[]any{"ar", "er", "irregular"},
+		},
+		{
+			"Parentheses",
+			[]string{"(ar OR er) AND NOT(mastered)"},
+			"((" + tagCondition(2) + " OR " + tagCondition(3) + ") AND NOT " + tagCondition(4) + ")",
+			[]any{"ar", "er", "mastered"},
+		},
+		{"Several filters", []string{"verbs", "NOT ar"}, "(" + tagCondition(2) + " AND NOT " + tagCondition(3) + ")", []any{"verbs", "ar"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseTagFilter(tt.filters...)
+			assert.NoError(t, err)
+
+			args := []any{1}
+			assert.Equal(t, tt.condition, filter.condition(&args))
+			assert.Equal(t, append([]any{1}, tt.args...), args)
+		})
+	}
+
+	for _, filter := range []string{"", "verbs AND", "OR verbs", "NOT", "(verbs", "verbs)", "verbs AND AND ar"} {
+		_, err := ParseTagFilter(filter)
+		assert.ErrorIs(t, err, ErrInvalidTagFilter, filter)
+	}
+}
+
+func TestGetCardsFromDeckWithTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	filter, err := ParseTagFilter("irregular-verbs")
+	assert.NoError(t, err)
+
+	mock.ExpectQuery("WHERE dc.deck_id = \\$1\\s+AND EXISTS \\(SELECT 1 FROM card_tags").WithArgs(1, "irregular-verbs").
+		WillReturnRows(cardRows(Card{ID: 5, Front: "ser", Back: "to be", Due: due, Tags: []string{"irregular-verbs", "verbs"}}))
+
+	cards, err := GetCardsFromDeck(db, 1, WithTags(filter))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"irregular-verbs", "verbs"}, (*cards)[0].Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT t.id, t.name, COUNT\\(ct.card_id\\) FROM tags t").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "count"}).AddRow(2, "irregular-verbs", 12).AddRow(1, "verbs", 40))
+
+	tags, err := GetTags(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []Tag{{ID: 2, Name: "irregular-verbs", Cards: 12}, {ID: 1, Name: "verbs", Cards: 40}}, *tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddCardTags(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("INSERT INTO tags \\(name\\) VALUES \\(\\$1\\) ON CONFLICT").WithArgs("verbs", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO card_tags").WithArgs("irregular-verbs", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err = AddCardTags(db, 5, []string{"verbs", "Irregular-Verbs"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid tag", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating mock database: %v", err)
+		}
+		defer db.Close()
+
+		err = AddCardTags(db, 5, []string{"verbs", "two words"})
+		assert.ErrorIs(t, err, ErrInvalidTag)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSetCardTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM card_tags WHERE card_id = $1")).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO card_tags").WithArgs("verbs", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = SetCardTags(db, 5, []string{"verbs"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveCardTag(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM card_tags WHERE card_id = $1 AND tag_id = (SELECT id FROM tags WHERE name = $2)")).
+		WithArgs(5, "verbs").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = RemoveCardTag(db, 5, "Verbs")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -112,6 +112,16 @@ func main() {
 
 	_ = db.CreateAllTables(database, db.CurrentTables)
 
+	// Uploaded images and audio are kept under MEDIA_DIR, ./media by default
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	media, err := db.NewMediaStore(mediaDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	http.Handle("/projects/gol", templ.Handler(components.GOLPage()))
 	http.Handle("/home", templ.Handler(components.Home()))
 	http.Handle("/projects/flashcard", templ.Handler(components.Decks()))
@@ -130,12 +140,50 @@ func main() {
 	http.HandleFunc("/api/flashcard", handlers.RandomFlashcardHandler(database))
 	http.HandleFunc("/api/flashcard/rate", handlers.RateFlashcardHandler(database))
 	http.HandleFunc("/api/flashcard/cards/", handlers.GetCardsForDeckHandler(database))
+	http.HandleFunc("/api/flashcard/cards/{id}/reviews", handlers.CardReviewsHandler(database))
+	http.HandleFunc("/api/flashcard/cards/{id}/tags", handlers.CardTagsHandler(database))
+	http.HandleFunc("/api/flashcard/cards/{id}/tags/{tag}", handlers.CardTagsHandler(database))
+	http.HandleFunc("/api/flashcard/cards/{id}/decks", handlers.CardDecksHandler(database))
+	http.HandleFunc("/api/flashcard/cards/{id}/decks/{deckId}", handlers.CardDecksHandler(database))
+	http.HandleFunc("/api/flashcard/cards/move", handlers.MoveCardsHandler(database))
+	http.HandleFunc("/api/flashcard/cards/copy", handlers.MoveCardsHandler(database))
+	http.HandleFunc("/api/flashcard/cards/batch", handlers.CardBatchHandler(database, media))
+	http.HandleFunc("/api/flashcard/tags", handlers.TagsHandler(database))
+	http.HandleFunc("/api/flashcard/search", handlers.SearchHandler(database))
 	http.HandleFunc("/api/flashcard/decks", handlers.GetDecksHandler(database))
 	http.HandleFunc("/api/flashcard/decks/", handlers.DeckHandler(database))
-	http.HandleFunc("/api/flashcard/cards", handlers.CardHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/scheduler", handlers.DeckSchedulerHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/parent", handlers.DeckParentHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/cards", handlers.DeckCardsHandler(database, media))
+	http.HandleFunc("/api/flashcard/decks/{id}/cards/{cardID}", handlers.DeckCardsHandler(database, media))
+	http.HandleFunc("/api/flashcard/decks/{id}/due", handlers.DueCardsHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/import", handlers.ImportDeckHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/export.csv", handlers.ExportDeckHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/export.md", handlers.ExportMarkdownHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/leeches", handlers.LeechesHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/duplicates", handlers.DuplicatesHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/sessions", handlers.StudySessionsHandler(database))
+	http.HandleFunc("/api/flashcard/decks/{id}/sessions/", handlers.StudySessionHandler(database))
+	http.HandleFunc("/api/flashcard/cards", handlers.CardHandler(database, media))
+	http.HandleFunc("/api/flashcard/cards/{id}/suspend", handlers.CardHandler(database, media))
+	http.HandleFunc("/api/flashcard/cards/{id}/unsuspend", handlers.CardHandler(database, media))
+	http.HandleFunc("/api/flashcard/cards/{id}/bury", handlers.CardHandler(database, media))
+	http.HandleFunc("/api/flashcard/cards/{id}/unbury", handlers.CardHandler(database, media))
+	http.HandleFunc("/api/flashcard/media", handlers.MediaUploadHandler(database, media))
+	http.HandleFunc("/api/flashcard/preview", handlers.PreviewHandler)
+	http.HandleFunc("/api/flashcard/note-types", handlers.NoteTypesHandler(database))
+	http.HandleFunc("/api/flashcard/note-types/{id}", handlers.NoteTypeHandler(database))
+	http.HandleFunc("/api/flashcard/notes", handlers.NotesHandler(database))
+	http.HandleFunc("/api/flashcard/notes/{id}", handlers.NoteHandler(database, media))
+	http.HandleFunc("/api/flashcard/import/apkg", handlers.ImportAPKGHandler(database))
+	http.HandleFunc("/api/flashcard/import/markdown", handlers.ImportMarkdownHandler(database))
+	http.HandleFunc("/api/admin/backup", handlers.BackupHandler(database))
+	http.HandleFunc("/api/admin/restore", handlers.RestoreHandler(database))
 	http.HandleFunc("/api/gol/patterns", ListPatternFiles)
 	http.HandleFunc("/api/gol/patterns/", GetFileContents)
 
+	http.HandleFunc("/media/{hash}", handlers.MediaHandler(database, media))
+
 	fs := http.FileServer(http.Dir("static"))
 	http.Handle("/", fs)
 	http.Handle("/static/", setHeaderMiddleware(http.StripPrefix("/static/", fs)))